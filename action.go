@@ -0,0 +1,64 @@
+package fakegameserver
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/antiphp/fakegameserver/internal/exiterror"
+)
+
+// Action is an action to take on a failure, either `continue`, `stop-health`, `code:<code>` or `signal:<signal>`.
+type Action string
+
+const (
+	// ActionContinue continues as if nothing happened.
+	ActionContinue Action = "continue"
+
+	// ActionStopHealth stops the health reports to Agones, so Agones marks the game server unhealthy.
+	ActionStopHealth Action = "stop-health"
+)
+
+// ParseAction parses an action.
+func ParseAction(s string) (Action, error) {
+	switch Action(s) {
+	case ActionContinue, ActionStopHealth:
+		return Action(s), nil
+	}
+	if _, err := exiterror.Parse(s); err != nil {
+		return "", errors.New("invalid action " + strconv.Quote(s) + ", expected continue, stop-health, code:<code> or signal:<signal>")
+	}
+	return Action(s), nil
+}
+
+// messageType returns the message type of the message that takes the action.
+func (a Action) messageType() MessageType {
+	return a.message("", nil).Type
+}
+
+// message returns the message that takes the action because of the given failure.
+func (a Action) message(desc string, cause error) Message {
+	switch a {
+	case ActionContinue, "":
+		return Message{
+			Type:        MessageTypeInfo,
+			Description: desc + ", continuing",
+			Error:       cause,
+		}
+	case ActionStopHealth:
+		return Message{
+			Type:        MessageTypeAgonesStopHealth,
+			Description: desc + ", stopping health reports",
+			Error:       cause,
+		}
+	}
+
+	msg := Message{
+		Type:        MessageTypeExit,
+		Description: desc + ", exiting",
+		Error:       cause,
+	}
+	if exitErr, err := exiterror.Parse(string(a)); err == nil {
+		msg.Error = exitErr
+	}
+	return msg
+}
@@ -2,13 +2,18 @@ package fakegameserver
 
 import (
 	"context"
+	"errors"
 	"slices"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
 	"github.com/antiphp/fakegameserver/internal/exiterror"
+	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
 	"k8s.io/utils/ptr"
 )
 
@@ -24,9 +29,51 @@ const (
 
 	// MessageTypeAgonesRequestUpdate is the message type for Agones state update requests.
 	MessageTypeAgonesRequestUpdate MessageType = "agonesRequestUpdate"
+
+	// MessageTypeAgonesSync is the message type for the reconciliation of the watched with the polled game server.
+	MessageTypeAgonesSync MessageType = "agonesSync"
+
+	// MessageTypeAgonesTransition is the message type for unexpected Agones state transitions.
+	MessageTypeAgonesTransition MessageType = "agonesTransition"
+
+	// MessageTypeAgonesStopHealth is the message type to stop the Agones health reports.
+	MessageTypeAgonesStopHealth MessageType = "agonesStopHealth"
+
+	// MessageTypeAgonesUpdateAttempt is the message type for failed Agones state update attempts.
+	MessageTypeAgonesUpdateAttempt MessageType = "agonesUpdateAttempt"
+
+	// MessageTypeAgonesGameServer is the message type for Agones game server changes.
+	MessageTypeAgonesGameServer MessageType = "agonesGameServer"
+)
+
+var (
+	// EventAgonesUpdate is the event type for Agones game server updates, with the Agones state as payload.
+	EventAgonesUpdate = NewEventType[agones.State](MessageTypeAgonesUpdate)
+
+	// EventAgonesConnection is the event type for Agones connectivity updates, with the connectivity as payload.
+	EventAgonesConnection = NewEventType[bool](MessageTypeAgonesConnection)
+
+	// EventAgonesReportHealth is the event type for health status reports, with the report success as payload.
+	EventAgonesReportHealth = NewEventType[bool](MessageTypeAgonesReportHealth)
+
+	// EventAgonesRequestUpdate is the event type for Agones state update requests, with the requested state as payload.
+	EventAgonesRequestUpdate = NewEventType[agones.State](MessageTypeAgonesRequestUpdate)
+
+	// EventAgonesSync is the event type for the reconciliation of the watched with the polled game server, with the
+	// sync status as payload.
+	EventAgonesSync = NewEventType[bool](MessageTypeAgonesSync)
+
+	// EventAgonesTransition is the event type for unexpected Agones state transitions, with the transition as payload.
+	EventAgonesTransition = NewEventType[agones.Transition](MessageTypeAgonesTransition)
+
+	// EventAgonesUpdateAttempt is the event type for failed Agones state update attempts, with the state as payload.
+	EventAgonesUpdateAttempt = NewEventType[agones.State](MessageTypeAgonesUpdateAttempt)
+
+	// EventAgonesGameServer is the event type for Agones game server changes, with the game server as payload.
+	EventAgonesGameServer = NewEventType[agones.GameServer](MessageTypeAgonesGameServer)
 )
 
-var _ Producer = (*AgonesWatcher)(nil)
+var _ Publisher = (*AgonesWatcher)(nil)
 
 // AgonesWatcher produces messages for any Agones connection or state update.
 type AgonesWatcher struct {
@@ -40,203 +87,508 @@ func NewAgonesWatcher(client *agones.Client) *AgonesWatcher {
 	}
 }
 
+// Publications returns the message types the Agones watcher produces.
+func (w *AgonesWatcher) Publications() []MessageType {
+	return []MessageType{
+		MessageTypeAgonesConnection, MessageTypeAgonesUpdate, MessageTypeAgonesTransition, MessageTypeAgonesSync, MessageTypeAgonesGameServer,
+	}
+}
+
 // Run runs the Agones watcher.
 func (w *AgonesWatcher) Run(ctx context.Context, queue Queue) {
+	clk := clock.FromContext(ctx)
+
 	first := true
+	clock.Hold(clk)
 	go w.client.WatchConnection(ctx, func(err error) {
 		switch {
 		case first && err != nil:
-			queue.Add(Message{
-				Type:        MessageTypeAgonesConnection,
-				Description: "Agones connecting",
-				Error:       err,
-				Payload:     false, // Connected.
-			})
+			queue.Add(EventAgonesConnection.Message("Agones connecting", err, false /* Connected. */))
 		case err != nil:
-			queue.Add(Message{
-				Type:        MessageTypeAgonesConnection,
-				Description: "Agones connection lost",
-				Error:       err,
-				Payload:     false,
-			})
+			queue.Add(EventAgonesConnection.Message("Agones connection lost ("+strconv.FormatInt(w.client.Drops(), 10)+" drops)", err, false))
 		default:
-			queue.Add(Message{
-				Type:        MessageTypeAgonesConnection,
-				Description: "Agones connection established",
-				Payload:     true,
-			})
+			queue.Add(EventAgonesConnection.Message("Agones connection established", nil, true))
 			first = false
 		}
 	})
+	var prev agones.State
+	clock.Hold(clk)
 	go w.client.WatchState(ctx, func(state agones.State) {
-		queue.Add(Message{
-			Type:        MessageTypeAgonesUpdate,
-			Description: "Agones state change received for " + string(state),
-			Payload:     state,
-		})
+		msg := EventAgonesUpdate.Message("Agones state change received for "+string(state), nil, state)
+		if !state.IsKnown() {
+			msg.Error = errors.New("unknown Agones state " + string(state))
+		}
+		queue.Add(msg)
+
+		trans := agones.Transition{From: prev, To: state}
+		prev = state
+		if trans.IsExpected() {
+			return
+		}
+		queue.Add(EventAgonesTransition.Message(
+			"Unexpected Agones state transition "+trans.String(),
+			errors.New("unexpected Agones state transition "+trans.String()),
+			trans,
+		))
 	})
+	clock.Hold(clk)
+	go w.client.WatchGameServer(ctx, func(gs agones.GameServer) {
+		queue.Add(EventAgonesGameServer.Message("Agones game server change received", nil, gs))
+	})
+	clock.Hold(clk)
+	go w.client.WatchSync(ctx, func(err error) {
+		if err != nil {
+			queue.Add(EventAgonesSync.Message("Agones watch out of sync with polled game server", err, false /* In sync. */))
+			return
+		}
+		queue.Add(EventAgonesSync.Message("Agones watch back in sync with polled game server", nil, true))
+	})
+
+	clock.Release(clk)
 	<-ctx.Done()
 }
 
 var (
-	_ Producer = (*AgonesStateUpdater)(nil)
-	_ Consumer = (*AgonesStateUpdater)(nil)
+	_ Publisher  = (*AgonesStateUpdater)(nil)
+	_ Subscriber = (*AgonesStateUpdater)(nil)
 )
 
 // AgonesStateUpdater updates the Agones state when requested.
+//
+// Failed updates are retried with backoff, until the maximum number of attempts or the deadline is reached, or a
+// newer update is requested. Then, the give-up action is taken. Only an update without retries, i.e. with a single
+// attempt and without deadline, reports its failure as failed Agones update, as the give-up action decides about the
+// consequences of a retried update.
+//
+// All messages of an update are replies to its request message.
 type AgonesStateUpdater struct {
-	client  *agones.Client
-	stateCh chan agones.State
+	client      *agones.Client
+	maxAttempts int
+	deadline    time.Duration
+	giveUp      Action
+	handlers    Handlers
+
+	reqs *mailbox[Message]
 }
 
 // NewAgonesStateUpdater returns a new Agones state updater.
-func NewAgonesStateUpdater(client *agones.Client) *AgonesStateUpdater {
-	return &AgonesStateUpdater{
-		client:  client,
-		stateCh: make(chan agones.State, 1),
+//
+// A deadline of zero disables the deadline.
+func NewAgonesStateUpdater(client *agones.Client, maxAttempts int, deadline time.Duration, giveUp Action) *AgonesStateUpdater {
+	u := &AgonesStateUpdater{
+		client:      client,
+		maxAttempts: max(maxAttempts, 1),
+		deadline:    deadline,
+		giveUp:      giveUp,
+		handlers:    Handlers{},
+		reqs:        newMailbox[Message](),
 	}
+	HandleEvent(u.handlers, EventAgonesRequestUpdate, u.request)
+	return u
+}
+
+// Publications returns the message types the Agones state updater produces.
+func (u *AgonesStateUpdater) Publications() []MessageType {
+	return []MessageType{MessageTypeAgonesUpdate, MessageTypeAgonesUpdateAttempt, MessageTypeInfo, u.giveUp.messageType()}
 }
 
 // Run runs the Agones state updater.
 func (u *AgonesStateUpdater) Run(ctx context.Context, queue Queue) {
+	defer u.reqs.close()
+
+	clk := clock.FromContext(ctx)
+
+	var (
+		req     Message
+		pending bool
+	)
 	for {
-		var state agones.State
-		select {
-		case <-ctx.Done():
-			return
-		case state = <-u.stateCh:
+		if !pending {
+			clock.Release(clk)
+			select {
+			case <-ctx.Done():
+				return
+			case req = <-u.reqs.ch:
+			}
 		}
 
-		err := u.client.UpdateState(ctx, state)
-		if err != nil {
-			queue.Add(Message{
-				Type:        MessageTypeAgonesUpdate,
-				Description: "Agones state update failed",
-				Error:       err,
-				Payload:     state,
-			})
+		req, pending = u.update(ctx, queue, req)
+	}
+}
+
+// update updates the Agones state with retries. It returns the next request, if a newer update was requested while
+// retrying.
+func (u *AgonesStateUpdater) update(ctx context.Context, queue Queue, req Message) (Message, bool) {
+	state, _ := EventAgonesRequestUpdate.Payload(req)
+	clk := clock.FromContext(ctx)
+
+	updateCtx := ctx
+	if u.deadline > 0 {
+		var cancel context.CancelFunc
+		updateCtx, cancel = context.WithCancel(ctx)
+		defer cancel()
+
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+
+		// The deadline is on the clock of the game server, and holds it until the update gave up.
+		t := clk.AfterFunc(u.deadline, func() {
+			cancel()
+			<-doneCh
+		})
+		defer t.Stop()
+	}
+
+	bo := backoff.NewExponentialBackOff()
+	bo.MaxElapsedTime = 0
+	bo.Clock = clk
+
+	var (
+		err     error
+		attempt int
+	)
+	for attempt = 1; ; attempt++ {
+		err = u.client.UpdateState(updateCtx, state)
+		if err == nil {
+			queue.Add(req.Reply(EventAgonesUpdate.Message("Agones state updated", nil, state)))
+			return Message{}, false
+		}
+
+		queue.Add(req.Reply(EventAgonesUpdateAttempt.Message("Agones state update to "+string(state)+" failed in attempt "+u.attempts(attempt), err, state)))
+		if attempt >= u.maxAttempts {
+			break
+		}
+
+		retry := clk.NewTimer(bo.NextBackOff())
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			retry.Stop()
+			return Message{}, false
+		case <-updateCtx.Done():
+			retry.Stop()
+			clock.Hold(clk) // Taking over from the deadline.
+		case next := <-u.reqs.ch:
+			retry.Stop()
+			nextState, _ := EventAgonesRequestUpdate.Payload(next)
+			queue.Add(req.Reply(Message{
+				Type:        MessageTypeInfo,
+				Description: "Agones state update to " + string(state) + " superseded by update to " + string(nextState),
+			}))
+			return next, true
+		case <-retry.C():
 			continue
 		}
+		break
+	}
 
-		queue.Add(Message{
-			Type:        MessageTypeAgonesUpdate,
-			Description: "Agones state updated",
-			Payload:     state,
-		})
+	if u.maxAttempts == 1 && u.deadline == 0 {
+		queue.Add(req.Reply(EventAgonesUpdate.Message("Agones state update failed", err, state)))
 	}
+	queue.Add(req.Reply(u.giveUp.message("Agones state update to "+string(state)+" given up after "+strconv.Itoa(attempt)+" attempts", err)))
+	return Message{}, false
+}
+
+func (u *AgonesStateUpdater) attempts(attempt int) string {
+	return strconv.Itoa(attempt) + "/" + strconv.Itoa(u.maxAttempts)
+}
+
+// Subscriptions returns the message types the Agones state updater consumes.
+func (u *AgonesStateUpdater) Subscriptions() []MessageType {
+	return u.handlers.Subscriptions()
 }
 
 // Consume consumes Agones state update requests.
 func (u *AgonesStateUpdater) Consume(msg Message) {
-	if msg.Type != MessageTypeAgonesRequestUpdate {
-		return
-	}
+	u.handlers.Consume(msg)
+}
 
-	state, _ := msg.Payload.(agones.State)
-	u.stateCh <- state
+// request requests an Agones state update, replacing a pending request.
+func (u *AgonesStateUpdater) request(ev Event[agones.State]) {
+	u.reqs.put(ev.Message)
+}
+
+func (u *AgonesStateUpdater) setClock(c clock.Clock) {
+	u.reqs.setClock(c)
 }
 
 var (
-	_ Producer = (*AgonesHealthReporter)(nil)
-	_ Consumer = (*AgonesHealthReporter)(nil)
+	_ Publisher  = (*AgonesHealthReporter)(nil)
+	_ Subscriber = (*AgonesHealthReporter)(nil)
 )
 
+// minHealthInterval is the minimum interval of health reports, also the interval failed health reports are retried at.
+const minHealthInterval = time.Second
+
 // AgonesHealthReporter reports the health of the game server to Agones by consuming health status messages.
 type AgonesHealthReporter struct {
 	client    *agones.Client
 	initDelay time.Duration
 	intvl     time.Duration
+	handlers  Handlers
 
-	ch chan bool
+	clock    clock.Clock
+	ch       chan bool
+	stopOnce sync.Once
+	stopCh   chan struct{}
+	doneCh   chan struct{}
 }
 
 // NewAgonesHealthReporter returns a new Agones health reporter.
 func NewAgonesHealthReporter(client *agones.Client, initDelay, intvl time.Duration) *AgonesHealthReporter {
-	return &AgonesHealthReporter{
+	r := &AgonesHealthReporter{
 		client:    client,
 		initDelay: initDelay,
 		intvl:     intvl,
-		ch:        make(chan bool, 1),
+		clock:     clock.Real(),
+		ch:        make(chan bool),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
 	}
+	r.handlers = Handlers{
+		MessageTypeAgonesStopHealth: func(Message) {
+			r.stopOnce.Do(func() {
+				clock.Hold(r.clock) // Until stopped.
+				close(r.stopCh)
+			})
+		},
+	}
+	HandleEvent(r.handlers, EventHealthStatus, func(ev Event[bool]) {
+		clock.Hold(r.clock) // Until the health is reported.
+		select {
+		case r.ch <- ev.Payload:
+		case <-r.stopCh:
+			clock.Release(r.clock)
+		case <-r.doneCh: // Not running anymore.
+			clock.Release(r.clock)
+		}
+	})
+	return r
+}
+
+// Publications returns the message types the Agones health reporter produces.
+func (r *AgonesHealthReporter) Publications() []MessageType {
+	return []MessageType{MessageTypeAgonesReportHealth}
 }
 
 // Run runs the Agones health reporter.
 func (r *AgonesHealthReporter) Run(ctx context.Context, queue Queue) {
-	start := time.Now()
+	defer close(r.doneCh)
 
-	// For simplicity we are polling every second to determine what to do, but a reactive system would be nicer.
-	t := time.NewTicker(time.Second)
-	defer t.Stop()
+	clk := clock.FromContext(ctx)
+	wake := newWakeQueue(clk)
+	defer wake.close()
+	sched := scheduler(ctx, wake)
 
 	var (
-		healthy  bool
-		lastSent time.Time
+		healthy bool
+		next    = clk.Now().Add(r.initDelay)
+		due     Handle
 	)
+	defer func() {
+		if due != nil {
+			due.Cancel()
+		}
+	}()
 	for {
+		clock.Release(clk)
 		select {
 		case <-ctx.Done():
 			return
-		case <-t.C:
+		case <-r.stopCh:
+			if due != nil {
+				due.Cancel()
+			}
+			clock.Release(clk)
+			return
+		case <-wake.ch:
 		case healthy = <-r.ch:
 		}
 
-		if time.Since(lastSent) < r.intvl || !healthy || time.Since(start) < r.initDelay {
+		if due != nil {
+			due.Cancel()
+			due = nil
+		}
+		if !healthy {
+			continue
+		}
+		if clk.Now().Before(next) {
+			due = sched.AddAt(Message{}, next)
 			continue
 		}
 
 		err := r.client.Health(ctx)
 		switch {
 		case err != nil:
-			queue.Add(Message{
-				Type:        MessageTypeAgonesReportHealth,
-				Description: "Health report failed",
-				Error:       err,
-				Payload:     false,
-			})
+			queue.Add(EventAgonesReportHealth.Message("Health report failed", err, false))
+
+			next = clk.Now().Add(minHealthInterval)
 		default:
-			queue.Add(Message{
-				Type:        MessageTypeAgonesReportHealth,
-				Description: "Health reported",
-				Payload:     true,
-			})
+			queue.Add(EventAgonesReportHealth.Message("Health reported", nil, true))
 
-			lastSent = time.Now()
+			next = clk.Now().Add(max(r.intvl, minHealthInterval))
 		}
+		due = sched.AddAt(Message{}, next)
 	}
 }
 
-// Consume consumes health status messages.
+// Subscriptions returns the message types the Agones health reporter consumes.
+func (r *AgonesHealthReporter) Subscriptions() []MessageType {
+	return r.handlers.Subscriptions()
+}
+
+// Consume consumes health status messages and messages to stop the health reports.
 func (r *AgonesHealthReporter) Consume(msg Message) {
-	if msg.Type != MessageTypeHealthStatus {
-		return
+	r.handlers.Consume(msg)
+}
+
+func (r *AgonesHealthReporter) setClock(c clock.Clock) {
+	r.clock = c
+}
+
+var (
+	_ Publisher  = (*AgonesDisconnect)(nil)
+	_ Subscriber = (*AgonesDisconnect)(nil)
+)
+
+// AgonesDisconnect takes an action when the Agones connection has been lost for longer than a timeout.
+type AgonesDisconnect struct {
+	client  *agones.Client
+	timeout time.Duration
+	action  Action
+
+	handlers Handlers
+	clock    clock.Clock
+	ch       chan bool
+	doneCh   chan struct{}
+}
+
+// NewAgonesDisconnect returns a new Agones disconnect handler.
+func NewAgonesDisconnect(client *agones.Client, timeout time.Duration, action Action) *AgonesDisconnect {
+	d := &AgonesDisconnect{
+		client:   client,
+		timeout:  timeout,
+		action:   action,
+		handlers: Handlers{},
+		clock:    clock.Real(),
+		ch:       make(chan bool),
+		doneCh:   make(chan struct{}),
 	}
+	HandleEvent(d.handlers, EventAgonesConnection, func(ev Event[bool]) {
+		clock.Hold(d.clock) // Until handled.
+		select {
+		case d.ch <- ev.Payload:
+		case <-d.doneCh: // Not running anymore.
+			clock.Release(d.clock)
+		}
+	})
+	return d
+}
 
-	healthy, _ := msg.Payload.(bool)
-	r.ch <- healthy
+// Publications returns the message types the Agones disconnect handler produces.
+func (d *AgonesDisconnect) Publications() []MessageType {
+	return []MessageType{d.action.messageType()}
+}
+
+// Run runs the Agones disconnect handler.
+func (d *AgonesDisconnect) Run(ctx context.Context, queue Queue) {
+	defer close(d.doneCh)
+
+	clk := clock.FromContext(ctx)
+
+	var (
+		timer  clock.Timer
+		timeCh <-chan time.Time
+	)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case connected := <-d.ch:
+			switch {
+			case connected && timer != nil:
+				timer.Stop()
+				timer, timeCh = nil, nil
+			case !connected && timer == nil:
+				timer = clk.NewTimer(d.timeout)
+				timeCh = timer.C()
+			}
+		case <-timeCh:
+			timer, timeCh = nil, nil
+
+			queue.Add(d.action.message(
+				"Agones connection lost for "+d.timeout.String()+" ("+strconv.FormatInt(d.client.Drops(), 10)+" drops)",
+				errors.New("agones connection lost"),
+			))
+		}
+	}
+}
+
+// Subscriptions returns the message types the Agones disconnect handler consumes.
+func (d *AgonesDisconnect) Subscriptions() []MessageType {
+	return d.handlers.Subscriptions()
+}
+
+// Consume consumes Agones connection messages.
+func (d *AgonesDisconnect) Consume(msg Message) {
+	d.handlers.Consume(msg)
+}
+
+func (d *AgonesDisconnect) setClock(c clock.Clock) {
+	d.clock = c
 }
 
 var (
-	_ Producer = (*AgonesStateTimer)(nil)
-	_ Consumer = (*AgonesStateTimer)(nil)
+	_ Publisher  = (*AgonesStateTimer)(nil)
+	_ Subscriber = (*AgonesStateTimer)(nil)
 )
 
 // AgonesStateTimer requests Agones state updates after configurable durations.
+//
+// It awaits the outcome of each requested update before the duration of the next state starts, so that the durations
+// count from the actual state changes. It stops once a game session started from the allocation, which then ends the
+// game server.
 type AgonesStateTimer struct {
-	states []agones.State
-	durs   []time.Duration
-
-	mu    sync.Mutex
-	state agones.State
-
-	once   sync.Once
-	waitCh chan struct{}
+	proj     *Projection
+	states   []agones.State
+	durs     []time.Duration
+	handlers Handlers
+	corr     *Correlator
+
+	connected *mailbox[struct{}]
+	stop      *mailbox[struct{}]
 }
 
-// NewAgonesStateTimer returns a new Agones state timer.
-func NewAgonesStateTimer() *AgonesStateTimer {
-	return &AgonesStateTimer{
-		waitCh: make(chan struct{}),
+// NewAgonesStateTimer returns a new Agones state timer, that reads the current Agones state from the projection.
+func NewAgonesStateTimer(proj *Projection) *AgonesStateTimer {
+	u := &AgonesStateTimer{
+		proj:      proj,
+		corr:      NewCorrelator(),
+		connected: newMailbox[struct{}](),
+		stop:      newMailbox[struct{}](),
+	}
+	// The outcome of a requested update is a state update, or a superseded or given up update.
+	u.handlers = Handlers{
+		MessageTypeAgonesUpdate:     u.corr.Consume,
+		MessageTypeInfo:             u.corr.Consume,
+		MessageTypeAgonesStopHealth: u.corr.Consume,
 	}
+	HandleEvent(u.handlers, EventAgonesConnection, func(ev Event[bool]) {
+		if ev.Payload {
+			u.connected.put(struct{}{})
+		}
+	})
+	HandleEvent(u.handlers, EventAgonesSession, func(Event[Session]) {
+		u.stop.put(struct{}{})
+	})
+	return u
 }
 
 // AddState adds a state and duration to the timer.
@@ -245,74 +597,125 @@ func (u *AgonesStateTimer) AddState(state agones.State, dur time.Duration) {
 	u.durs = append(u.durs, dur)
 }
 
+// Publications returns the message types the Agones state timer produces.
+func (u *AgonesStateTimer) Publications() []MessageType {
+	return []MessageType{MessageTypeAgonesRequestUpdate, MessageTypeInfo}
+}
+
 // Run runs the Agones state timer.
 func (u *AgonesStateTimer) Run(ctx context.Context, queue Queue) {
+	defer u.connected.close()
+	defer u.stop.close()
+
+	clk := clock.FromContext(ctx)
+	clock.Release(clk)
 	select {
 	case <-ctx.Done():
 		return
-	case <-u.waitCh:
+	case <-u.stop.ch:
+		u.stopped(queue)
+		clock.Release(clk)
+		return
+	case <-u.connected.ch:
 	}
+	u.connected.close() // Re-connects do not restart the timer.
 
 	states := slices.Clone(u.states)
 	durs := slices.Clone(u.durs)
+	wake := newWakeQueue(clk)
+	defer wake.close()
 	var (
 		state agones.State
 		dur   time.Duration
 	)
 	for {
 		if len(states) == 0 {
+			clock.Release(clk)
 			return
 		}
 
 		state, states = shift(states)
 		dur, durs = shift(durs)
 
-		if u.getState() == state {
+		if u.proj.State() == state {
 			continue
 		}
 
+		h := scheduler(ctx, wake).AddAfter(Message{}, dur)
+		clock.Release(clk)
 		select {
 		case <-ctx.Done():
+			h.Cancel()
+			return
+		case <-u.stop.ch:
+			h.Cancel()
+			u.stopped(queue)
+			clock.Release(clk)
 			return
-		case <-time.After(dur):
+		case <-wake.ch:
 		}
 
-		queue.Add(Message{
-			Type:        MessageTypeAgonesRequestUpdate,
-			Description: "Requesting Agones state update to " + string(state),
-			Payload:     state,
-		})
-	}
-}
-
-// Consume consumes Agones connection and state update messages.
-func (u *AgonesStateTimer) Consume(msg Message) {
-	switch {
-	case msg.Type == MessageTypeAgonesConnection:
-		if val, _ := msg.Payload.(bool); val {
-			u.once.Do(func() { // Handle re-connects.
-				close(u.waitCh)
+		if curr := u.proj.State(); curr.IsFinal() {
+			queue.Add(Message{
+				Type:        MessageTypeInfo,
+				Description: "Agones state timer stopped, the Agones state " + string(curr) + " is final",
 			})
+			clock.Release(clk)
+			return
 		}
-	case msg.Type == MessageTypeAgonesUpdate:
-		if state, ok := msg.Payload.(agones.State); ok {
-			u.setState(state)
+
+		if !u.request(ctx, queue, state) {
+			return
 		}
 	}
 }
 
-func (u *AgonesStateTimer) setState(state agones.State) {
-	u.mu.Lock()
-	defer u.mu.Unlock()
+// request requests an Agones state update and awaits its outcome. It returns false once the timer stopped meanwhile.
+func (u *AgonesStateTimer) request(ctx context.Context, queue Queue, state agones.State) bool {
+	req := EventAgonesRequestUpdate.Message("Requesting Agones state update to "+string(state), nil, state)
+	req.ID = uuid.NewString()
+
+	// Awaits the outcome along with the stop of the timer, unlike Correlator.Request.
+	w, cancel := u.corr.watch(ctx, req.ID, []MessageType{MessageTypeAgonesUpdate, MessageTypeInfo, MessageTypeAgonesStopHealth})
+	defer cancel()
 
-	u.state = state
+	queue.Add(req)
+
+	clk := clock.FromContext(ctx)
+	clock.Release(clk)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-u.stop.ch:
+		u.stopped(queue)
+		clock.Release(clk)
+		return false
+	case <-w.ch:
+		return true
+	}
 }
 
-func (u *AgonesStateTimer) getState() agones.State {
-	u.mu.Lock()
-	defer u.mu.Unlock()
+// stopped reports that the timer stopped for a session.
+func (u *AgonesStateTimer) stopped(queue Queue) {
+	queue.Add(Message{
+		Type:        MessageTypeInfo,
+		Description: "Agones state timer stopped, the session started from the allocation takes precedence",
+	})
+}
+
+// Subscriptions returns the message types the Agones state timer consumes.
+func (u *AgonesStateTimer) Subscriptions() []MessageType {
+	return u.handlers.Subscriptions()
+}
+
+// Consume consumes Agones connection and session messages, and the outcomes of requested updates.
+func (u *AgonesStateTimer) Consume(msg Message) {
+	u.handlers.Consume(msg)
+}
 
-	return u.state
+func (u *AgonesStateTimer) setClock(c clock.Clock) {
+	u.connected.setClock(c)
+	u.stop.setClock(c)
 }
 
 func shift[T any](s []T) (T, []T) {
@@ -323,50 +726,102 @@ func shift[T any](s []T) (T, []T) {
 	return s[0], s[1:]
 }
 
-// Shutdown is a shutdown handler that exits the game server when Agones state changes.
+// Shutdown is a shutdown handler that exits the game server when Agones state changes to a final state.
 type Shutdown struct {
+	proj      *Projection
 	enabledFn func() bool
-	once      sync.Once
-	waitCh    chan struct{}
+	handlers  Handlers
+	final     *mailbox[struct{}]
 }
 
-// NewAgonesShutdown returns a new Agones shutdown handler.
-func NewAgonesShutdown(enabledFn func() bool) *Shutdown {
-	return &Shutdown{
+// NewAgonesShutdown returns a new Agones shutdown handler, that reads the current Agones state from the projection.
+func NewAgonesShutdown(proj *Projection, enabledFn func() bool) *Shutdown {
+	s := &Shutdown{
+		proj:      proj,
 		enabledFn: enabledFn,
-		waitCh:    make(chan struct{}),
+		final:     newMailbox[struct{}](),
 	}
+	s.handlers = Handlers{
+		MessageTypeAgonesUpdate:     s.signal,
+		MessageTypeAgonesGameServer: s.signal,
+	}
+	return s
+}
+
+// Publications returns the message types the shutdown handler produces.
+func (s *Shutdown) Publications() []MessageType {
+	return []MessageType{MessageTypeExit}
 }
 
 // Run runs the shutdown handler.
 func (s *Shutdown) Run(ctx context.Context, q Queue) {
+	defer s.final.close()
+
+	clk := clock.FromContext(ctx)
+	clock.Release(clk)
 	select {
 	case <-ctx.Done():
 		return
-	case <-s.waitCh:
+	case <-s.final.ch:
 	}
+	defer clock.Release(clk)
 
 	if !s.enabledFn() {
 		return
 	}
 
 	q.Add(Message{
-		Type:        MessageTypeExit,
-		Description: "Agones state changed to Shutdown, emulating the behavior of Agones in a non-local development environment with SIGTERM",
-		Error:       exiterror.New(nil, ptr.To[int](int(syscall.SIGTERM))),
+		Type: MessageTypeExit,
+		Description: "Agones state changed to " + string(s.proj.State()) + ", emulating the behavior of Agones in a non-local development " +
+			"environment with SIGTERM",
+		Error: exiterror.New(nil, ptr.To[int](int(syscall.SIGTERM))),
 	})
 }
 
-// Consume consumes Agones state update messages.
+// Subscriptions returns the message types the shutdown handler consumes.
+func (s *Shutdown) Subscriptions() []MessageType {
+	return s.handlers.Subscriptions()
+}
+
+// Consume consumes Agones state update and game server messages.
 func (s *Shutdown) Consume(msg Message) {
-	if msg.Type != MessageTypeAgonesUpdate {
+	s.handlers.Consume(msg)
+}
+
+func (s *Shutdown) signal(Message) {
+	if !s.proj.State().IsFinal() {
 		return
 	}
-	if state, ok := msg.Payload.(agones.State); ok && state != agones.StateShutdown {
-		return
+
+	s.final.put(struct{}{})
+}
+
+func (s *Shutdown) setClock(c clock.Clock) {
+	s.final.setClock(c)
+}
+
+var _ ExitHook = (*AgonesExitShutdown)(nil)
+
+// AgonesExitShutdown transitions to the Agones state Shutdown when the game server exits, unless the Agones state is
+// already final.
+type AgonesExitShutdown struct {
+	client *agones.Client
+	proj   *Projection
+}
+
+// NewAgonesExitShutdown returns a new Agones exit shutdown handler, that reads the current Agones state from the
+// projection.
+func NewAgonesExitShutdown(client *agones.Client, proj *Projection) *AgonesExitShutdown {
+	return &AgonesExitShutdown{
+		client: client,
+		proj:   proj,
 	}
+}
 
-	s.once.Do(func() {
-		close(s.waitCh)
-	})
+// OnExit transitions to the Agones state Shutdown.
+func (s *AgonesExitShutdown) OnExit(ctx context.Context, _ string, _ error) {
+	if s.proj.State().IsFinal() {
+		return
+	}
+	_ = s.client.UpdateState(ctx, agones.StateShutdown) // The game server exits anyway.
 }
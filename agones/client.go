@@ -5,51 +5,207 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"agones.dev/agones/pkg/sdk"
+	"agones.dev/agones/pkg/sdk/alpha"
+	"agones.dev/agones/pkg/sdk/beta"
+	"github.com/antiphp/fakegameserver/clock"
 	"github.com/cenkalti/backoff/v4"
+	"github.com/google/uuid"
 	"github.com/grpc-ecosystem/go-grpc-middleware/v2/interceptors/timeout"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-// State represents an Agones state.
-type State string
+// defaultPollInterval is the poll interval used when polling replaces the watch without a configured interval.
+const defaultPollInterval = time.Second
 
-const (
-	// StateReady is the Agones state Ready.
-	// The state indicates that the game server is ready to receive traffic.
-	StateReady State = "Ready"
+// callTimeout is the timeout of calls to the SDK server. It is on the wall clock rather than the clock of the client,
+// as the calls take real time, even when the clock is scaled or virtual.
+const callTimeout = 5 * time.Second
 
-	// StateAllocated is the Agones state Allocated.
-	// The state indicates that the game server hosts a game session.
-	StateAllocated State = "Allocated"
+// GameServer is a snapshot of an Agones game server.
+type GameServer struct {
+	Name        string
+	State       State
+	Labels      map[string]string
+	Annotations map[string]string
+	Counters    map[string]Counter
+	Players     int64
 
-	// StateShutdown is the Agones state Shutdown.
-	// The state indicates that the game server is shutting down.
-	StateShutdown State = "Shutdown"
-)
+	// version is the resource version of the game server, or zero if it is unknown.
+	version uint64
+}
+
+// Counter is an Agones counter.
+type Counter struct {
+	Count    int64
+	Capacity int64
+}
+
+func newGameServer(raw *sdk.GameServer) GameServer {
+	var counters map[string]Counter
+	for name, counter := range raw.GetStatus().GetCounters() {
+		if counters == nil {
+			counters = make(map[string]Counter)
+		}
+		counters[name] = Counter{
+			Count:    counter.GetCount(),
+			Capacity: counter.GetCapacity(),
+		}
+	}
+
+	version, _ := strconv.ParseUint(raw.GetObjectMeta().GetResourceVersion(), 10, 64)
+	return GameServer{
+		Name:        raw.GetObjectMeta().GetName(),
+		State:       State(raw.GetStatus().GetState()),
+		Labels:      maps.Clone(raw.GetObjectMeta().GetLabels()),
+		Annotations: maps.Clone(raw.GetObjectMeta().GetAnnotations()),
+		Counters:    counters,
+		Players:     raw.GetStatus().GetPlayers().GetCount(),
+		version:     version,
+	}
+}
+
+// olderThan determines if the game server is known to be older than the other game server, by their resource versions.
+func (g GameServer) olderThan(other GameServer) bool {
+	return g.version != 0 && other.version != 0 && g.version < other.version
+}
+
+// diff returns a description of the differences between two game servers, or an empty string if there are none.
+func (g GameServer) diff(other GameServer) string {
+	var diffs []string
+	if g.State != other.State {
+		diffs = append(diffs, "state "+string(g.State)+" != "+string(other.State))
+	}
+	if !maps.Equal(g.Labels, other.Labels) {
+		diffs = append(diffs, "labels differ")
+	}
+	if !maps.Equal(g.Annotations, other.Annotations) {
+		diffs = append(diffs, "annotations differ")
+	}
+	if !maps.Equal(g.Counters, other.Counters) {
+		diffs = append(diffs, "counters differ")
+	}
+	if g.Players != other.Players {
+		diffs = append(diffs, "players "+strconv.FormatInt(g.Players, 10)+" != "+strconv.FormatInt(other.Players, 10))
+	}
+	return strings.Join(diffs, ", ")
+}
+
+// Option is a client option.
+type Option func(*Client)
+
+// WithPollInterval polls the game server in the given interval.
+//
+// While watching, the polled game server is reconciled with the watched game server. When watching is disabled or
+// not supported by the SDK server, polling replaces the watch.
+func WithPollInterval(intvl time.Duration) Option {
+	return func(c *Client) {
+		c.pollIntvl = intvl
+	}
+}
+
+// WithoutWatch disables watching the game server, polling replaces the watch.
+func WithoutWatch() Option {
+	return func(c *Client) {
+		c.watchDisabled = true
+	}
+}
+
+// WithBetaSDK enables the beta features of the Agones SDK, such as counters.
+func WithBetaSDK(beta beta.SDKClient) Option {
+	return func(c *Client) {
+		c.beta = beta
+	}
+}
+
+// WithAlphaSDK enables the alpha features of the Agones SDK, such as player tracking.
+func WithAlphaSDK(alpha alpha.SDKClient) Option {
+	return func(c *Client) {
+		c.alpha = alpha
+	}
+}
+
+// WithClock sets the clock to back off and poll on, e.g. a scaled clock to run a lifecycle faster.
+//
+// On a virtual clock, the client releases the clock whenever it waits, so Run and the watch methods must be started
+// with the clock held, see clock.Hold.
+func WithClock(clk clock.Clock) Option {
+	return func(c *Client) {
+		c.clock = clk
+	}
+}
 
 // Client is the Agones client.
 type Client struct {
 	client sdk.SDKClient
+	alpha  alpha.SDKClient
+	beta   beta.SDKClient
 	health sdk.SDK_HealthClient
 
+	pollIntvl     time.Duration
+	watchDisabled bool
+	clock         clock.Clock
+
 	isLocal atomic.Bool
+	polling atomic.Bool
+	drops   atomic.Int64
 
 	mu            sync.Mutex
 	stateWatchers []func(State)
+	gsWatchers    []func(GameServer)
 	connWatchers  []func(error)
+	syncWatchers  []func(error)
 
+	gs      GameServer
+	watchGS GameServer
+	polled  *GameServer
 	state   State
 	connErr *error
+	syncErr error
 }
 
 // NewSDKClient returns a new Agones SDK client.
 func NewSDKClient(addr string) (sdk.SDKClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdk.NewSDKClient(conn), nil
+}
+
+// NewAlphaSDKClient returns a new Agones alpha SDK client.
+func NewAlphaSDKClient(addr string) (alpha.SDKClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return alpha.NewSDKClient(conn), nil
+}
+
+// NewBetaSDKClient returns a new Agones beta SDK client.
+func NewBetaSDKClient(addr string) (beta.SDKClient, error) {
+	conn, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return beta.NewSDKClient(conn), nil
+}
+
+func dial(addr string) (*grpc.ClientConn, error) {
 	conn, err := grpc.NewClient(
 		addr,
 		grpc.WithChainUnaryInterceptor(
@@ -60,15 +216,19 @@ func NewSDKClient(addr string) (sdk.SDKClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("dialing Agones %s: %w", addr, err)
 	}
-
-	return sdk.NewSDKClient(conn), nil
+	return conn, nil
 }
 
 // NewClient returns a new Agones client.
-func NewClient(sdk sdk.SDKClient) *Client {
-	return &Client{
+func NewClient(sdk sdk.SDKClient, opts ...Option) *Client {
+	c := &Client{
 		client: sdk,
+		clock:  clock.Real(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 // IsLocal determines if the SDK server runs in local development mode.
@@ -76,6 +236,11 @@ func (c *Client) IsLocal() bool {
 	return c.isLocal.Load()
 }
 
+// Drops returns how many times the established connection to the SDK server dropped.
+func (c *Client) Drops() int64 {
+	return c.drops.Load()
+}
+
 // Health sends a health report.
 func (c *Client) Health(ctx context.Context) error {
 	if c.health == nil {
@@ -94,7 +259,7 @@ func (c *Client) Health(ctx context.Context) error {
 
 // UpdateState updates the state.
 func (c *Client) UpdateState(ctx context.Context, st State) error {
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
 	defer cancel()
 
 	switch st {
@@ -119,11 +284,134 @@ func (c *Client) UpdateState(ctx context.Context, st State) error {
 	return nil
 }
 
+// Reserve reserves the game server for the duration, after which Agones moves it back to Ready. A duration of zero
+// reserves it until the next state change.
+func (c *Client) Reserve(ctx context.Context, dur time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	if _, err := c.client.Reserve(ctx, &sdk.Duration{Seconds: int64(dur.Seconds())}); err != nil {
+		return fmt.Errorf("reserving for %s: %w", dur, err)
+	}
+	return nil
+}
+
+// SetLabel sets a label of the game server. Agones prefixes the key with `agones.dev/sdk-`.
+func (c *Client) SetLabel(ctx context.Context, key, value string) error {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	if _, err := c.client.SetLabel(ctx, &sdk.KeyValue{Key: key, Value: value}); err != nil {
+		return fmt.Errorf("setting label %s: %w", key, err)
+	}
+	return nil
+}
+
+// GameServer returns the game server, as polled from the SDK server.
+func (c *Client) GameServer(ctx context.Context) (GameServer, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	raw, err := c.client.GetGameServer(ctx, &sdk.Empty{})
+	if err != nil {
+		return GameServer{}, fmt.Errorf("getting game server: %w", err)
+	}
+	return newGameServer(raw), nil
+}
+
+// Counter returns the counter with the given name.
+func (c *Client) Counter(ctx context.Context, name string) (Counter, error) {
+	if c.beta == nil {
+		return Counter{}, errors.New("counters require the beta SDK")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	counter, err := c.beta.GetCounter(ctx, &beta.GetCounterRequest{Name: name})
+	if err != nil {
+		return Counter{}, fmt.Errorf("getting counter %s: %w", name, err)
+	}
+	return Counter{Count: counter.GetCount(), Capacity: counter.GetCapacity()}, nil
+}
+
+// UpdateCounter changes the count of the counter with the given name by the given difference.
+func (c *Client) UpdateCounter(ctx context.Context, name string, diff int64) (Counter, error) {
+	if c.beta == nil {
+		return Counter{}, errors.New("counters require the beta SDK")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	counter, err := c.beta.UpdateCounter(ctx, &beta.UpdateCounterRequest{
+		CounterUpdateRequest: &beta.CounterUpdateRequest{
+			Name:      name,
+			CountDiff: diff,
+		},
+	})
+	if err != nil {
+		return Counter{}, fmt.Errorf("updating counter %s: %w", name, err)
+	}
+	return Counter{Count: counter.GetCount(), Capacity: counter.GetCapacity()}, nil
+}
+
+// SetCounter sets the count of the counter with the given name.
+func (c *Client) SetCounter(ctx context.Context, name string, count int64) (Counter, error) {
+	if c.beta == nil {
+		return Counter{}, errors.New("counters require the beta SDK")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	counter, err := c.beta.UpdateCounter(ctx, &beta.UpdateCounterRequest{
+		CounterUpdateRequest: &beta.CounterUpdateRequest{
+			Name:  name,
+			Count: wrapperspb.Int64(count),
+		},
+	})
+	if err != nil {
+		return Counter{}, fmt.Errorf("setting counter %s: %w", name, err)
+	}
+	return Counter{Count: counter.GetCount(), Capacity: counter.GetCapacity()}, nil
+}
+
+// SetPlayers connects or disconnects players until the given number of players is connected.
+func (c *Client) SetPlayers(ctx context.Context, count int64) error {
+	if c.alpha == nil {
+		return errors.New("player tracking requires the alpha SDK")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+
+	list, err := c.alpha.GetConnectedPlayers(ctx, &alpha.Empty{})
+	if err != nil {
+		return fmt.Errorf("getting connected players: %w", err)
+	}
+	ids := list.GetList()
+	count = max(count, 0)
+
+	for i := int64(len(ids)); i < count; i++ {
+		if _, err = c.alpha.PlayerConnect(ctx, &alpha.PlayerID{PlayerID: "fakegs-" + uuid.NewString()}); err != nil {
+			return fmt.Errorf("connecting player: %w", err)
+		}
+	}
+	for _, id := range ids[min(count, int64(len(ids))):] {
+		if _, err = c.alpha.PlayerDisconnect(ctx, &alpha.PlayerID{PlayerID: id}); err != nil {
+			return fmt.Errorf("disconnecting player %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
 // WatchConnection calls the given function when the Agones connectivity changes.
 func (c *Client) WatchConnection(ctx context.Context, fn func(error)) {
 	idx := c.subConnWatcher(fn)
 	defer c.unsubConnWatcher(idx)
 
+	clock.Release(c.clock)
 	<-ctx.Done()
 }
 
@@ -132,23 +420,70 @@ func (c *Client) WatchState(ctx context.Context, fn func(State)) {
 	idx := c.subStateWatcher(fn)
 	defer c.unsubStateWatcher(idx)
 
+	clock.Release(c.clock)
+	<-ctx.Done()
+}
+
+// WatchGameServer calls the given function when the game server changes.
+func (c *Client) WatchGameServer(ctx context.Context, fn func(GameServer)) {
+	idx := c.subGameServerWatcher(fn)
+	defer c.unsubGameServerWatcher(idx)
+
+	clock.Release(c.clock)
+	<-ctx.Done()
+}
+
+// WatchSync calls the given function when the watched game server gets out of sync with the polled game server, or
+// back in sync.
+func (c *Client) WatchSync(ctx context.Context, fn func(error)) {
+	idx := c.subSyncWatcher(fn)
+	defer c.unsubSyncWatcher(idx)
+
+	clock.Release(c.clock)
 	<-ctx.Done()
 }
 
 // Run runs the Agones client.
+//
+// The game server is watched, and reconciled by polling if a poll interval is set. Polling replaces the watch if
+// watching is disabled or not supported by the SDK server.
 func (c *Client) Run(ctx context.Context) {
+	if !c.watchDisabled {
+		if c.pollIntvl > 0 {
+			clock.Hold(c.clock)
+			go c.reconcile(ctx)
+		}
+		if !c.watch(ctx) {
+			return
+		}
+	}
+
+	c.polling.Store(true)
+	c.poll(ctx)
+}
+
+// watch watches the game server until the context is done. It returns false if the context is done, or true if
+// the SDK server does not support watching.
+func (c *Client) watch(ctx context.Context) bool {
 	bo := backoff.NewExponentialBackOff()
 	bo.MaxElapsedTime = 0
 	bo.MaxInterval = 5 * time.Second
+	bo.Clock = c.clock
 
 	for {
+		t := c.clock.NewTimer(bo.NextBackOff())
+		clock.Release(c.clock)
 		select {
 		case <-ctx.Done():
-			return
-		case <-time.After(bo.NextBackOff()):
+			t.Stop()
+			return false
+		case <-t.C():
 		}
 
 		conn, err := c.client.WatchGameServer(ctx, &sdk.Empty{})
+		if status.Code(err) == codes.Unimplemented {
+			return true
+		}
 		if err != nil {
 			c.notifyConnWatchers(err)
 			continue
@@ -159,22 +494,152 @@ func (c *Client) Run(ctx context.Context) {
 
 		var raw *sdk.GameServer
 		for {
+			clock.Release(c.clock)
 			raw, err = conn.Recv()
+			if status.Code(err) == codes.Unimplemented {
+				return true
+			}
 			if err != nil {
 				c.notifyConnWatchers(err)
 				break
 			}
 
-			c.notifyStateWatchers(State(raw.GetStatus().GetState()))
-			c.isLocal.Store(raw.GetObjectMeta().GetLabels()["islocal"] == "true")
+			c.receive(newGameServer(raw))
 		}
 	}
 }
 
+// poll polls the game server until the context is done.
+func (c *Client) poll(ctx context.Context) {
+	intvl := c.pollIntvl
+	if intvl <= 0 {
+		intvl = defaultPollInterval
+	}
+
+	t := c.clock.NewTicker(intvl)
+	defer t.Stop()
+
+	for {
+		clock.Release(c.clock)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		raw, err := c.client.GetGameServer(ctx, &sdk.Empty{})
+		if err != nil {
+			c.notifyConnWatchers(err)
+			continue
+		}
+
+		c.notifyConnWatchers(nil)
+		c.update(newGameServer(raw))
+	}
+}
+
+// reconcile polls the game server and compares it with the game server last delivered by the watch.
+//
+// A difference is only reported if it persists for two consecutive polls, as the watch may just lag behind. The
+// polled game server then replaces the watched game server, until the watch delivers a game server that matches it.
+func (c *Client) reconcile(ctx context.Context) {
+	t := c.clock.NewTicker(c.pollIntvl)
+	defer t.Stop()
+
+	var lastDiff string
+	for {
+		clock.Release(c.clock)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		if c.polling.Load() {
+			clock.Release(c.clock)
+			return
+		}
+
+		raw, err := c.client.GetGameServer(ctx, &sdk.Empty{})
+		if err != nil {
+			continue // Connection errors are reported by the watch.
+		}
+		polled := newGameServer(raw)
+
+		diff := c.watched().diff(polled)
+		switch {
+		case diff == "":
+			c.resync()
+		case diff == lastDiff:
+			c.replace(polled, errors.New("watched game server differs from polled game server: "+diff))
+		}
+		lastDiff = diff
+	}
+}
+
+// receive receives a game server from the watch.
+//
+// While the polled game server replaces the watched game server, a received game server only brings the watch back in
+// sync if it matches the polled game server or is newer. One that is older is ignored.
+func (c *Client) receive(gs GameServer) {
+	c.mu.Lock()
+	polled := c.polled
+	if polled != nil && gs.olderThan(*polled) {
+		c.mu.Unlock()
+		return
+	}
+	c.watchGS = gs
+	c.mu.Unlock()
+
+	if polled != nil && gs.diff(*polled) != "" && !polled.olderThan(gs) {
+		return
+	}
+	c.resync()
+}
+
+// replace replaces the watched game server with the polled game server, as the watch is out of sync.
+func (c *Client) replace(polled GameServer, err error) {
+	c.mu.Lock()
+	c.polled = &polled
+	c.mu.Unlock()
+
+	c.notifySyncWatchers(err)
+	c.update(polled)
+}
+
+// resync uses the game server last delivered by the watch, as the watch is in sync.
+func (c *Client) resync() {
+	c.mu.Lock()
+	c.polled = nil
+	gs := c.watchGS
+	c.mu.Unlock()
+
+	c.notifySyncWatchers(nil)
+	c.update(gs)
+}
+
+func (c *Client) update(gs GameServer) {
+	c.isLocal.Store(gs.Labels["islocal"] == "true")
+
+	c.notifyGameServerWatchers(gs)
+	c.notifyStateWatchers(gs.State)
+}
+
+// watched returns the game server last delivered by the watch.
+func (c *Client) watched() GameServer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.watchGS
+}
+
 func (c *Client) notifyConnWatchers(err error) {
 	if c.connErr != nil && errors.Is(*c.connErr, err) {
 		return
 	}
+	if c.connErr != nil && *c.connErr == nil {
+		c.drops.Add(1)
+	}
 	c.connErr = &err
 
 	c.mu.Lock()
@@ -186,16 +651,44 @@ func (c *Client) notifyConnWatchers(err error) {
 }
 
 func (c *Client) notifyStateWatchers(state State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	if c.state == state {
 		return
 	}
 	c.state = state
 
+	for _, fn := range c.stateWatchers {
+		fn(state)
+	}
+}
+
+func (c *Client) notifyGameServerWatchers(gs GameServer) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	for _, fn := range c.stateWatchers {
-		fn(state)
+	if c.gs.Name == gs.Name && c.gs.diff(gs) == "" {
+		return
+	}
+	c.gs = gs
+
+	for _, fn := range c.gsWatchers {
+		fn(gs)
+	}
+}
+
+func (c *Client) notifySyncWatchers(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if (c.syncErr == nil) == (err == nil) {
+		return
+	}
+	c.syncErr = err
+
+	for _, fn := range c.syncWatchers {
+		fn(err)
 	}
 }
 
@@ -230,3 +723,35 @@ func (c *Client) unsubStateWatcher(idx int) {
 	// Overwrite instead of delete to keep indexes valid.
 	c.stateWatchers[idx] = func(State) {}
 }
+
+func (c *Client) subGameServerWatcher(fn func(GameServer)) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gsWatchers = append(c.gsWatchers, fn)
+	return len(c.gsWatchers) - 1
+}
+
+func (c *Client) unsubGameServerWatcher(idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Overwrite instead of delete to keep indexes valid.
+	c.gsWatchers[idx] = func(GameServer) {}
+}
+
+func (c *Client) subSyncWatcher(fn func(error)) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.syncWatchers = append(c.syncWatchers, fn)
+	return len(c.syncWatchers) - 1
+}
+
+func (c *Client) unsubSyncWatcher(idx int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Overwrite instead of delete to keep indexes valid.
+	c.syncWatchers[idx] = func(error) {}
+}
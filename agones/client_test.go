@@ -5,6 +5,7 @@ import (
 	"errors"
 	"sync"
 	"testing"
+	"time"
 
 	"agones.dev/agones/pkg/sdk"
 	"agones.dev/agones/pkg/sdkserver"
@@ -13,6 +14,8 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestClient_UpdateStateReady(t *testing.T) {
@@ -117,6 +120,134 @@ func TestClient_WatchGameServer(t *testing.T) {
 	}, states)
 }
 
+func TestClient_RunPolls(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []agones.Option
+		watchErr error
+	}{
+		{
+			name:     "handles watch not supported",
+			opts:     []agones.Option{agones.WithPollInterval(10 * time.Millisecond)},
+			watchErr: status.Error(codes.Unimplemented, "test"),
+		},
+		{
+			name: "handles watch disabled",
+			opts: []agones.Option{agones.WithPollInterval(10 * time.Millisecond), agones.WithoutWatch()},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &mockSDK{}
+			if test.watchErr != nil {
+				m.On("WatchGameServer", &sdk.Empty{}).Return(nil, test.watchErr).Once()
+			}
+			m.On("GetGameServer", &sdk.Empty{}).Return(&sdk.GameServer{Status: &sdk.GameServer_Status{State: "Ready"}}, nil)
+
+			client := agones.NewClient(m, test.opts...)
+
+			stateCh := make(chan agones.State, 1)
+			go client.WatchState(t.Context(), func(state agones.State) {
+				stateCh <- state
+			})
+			go client.Run(t.Context())
+
+			select {
+			case state := <-stateCh:
+				assert.Equal(t, agones.StateReady, state)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timeout waiting for polled state")
+			}
+		})
+	}
+}
+
+func TestClient_RunReconciles(t *testing.T) {
+	stream := &mockWatchStream{ctx: t.Context(), ch: make(chan *sdk.GameServer, 1)}
+	stream.ch <- newSDKGameServer("Ready", "4")
+
+	m := &mockSDK{}
+	m.On("WatchGameServer", &sdk.Empty{}).Return(stream, nil)
+	m.On("GetGameServer", &sdk.Empty{}).Return(newSDKGameServer("Allocated", "5"), nil)
+
+	client := agones.NewClient(m, agones.WithPollInterval(10*time.Millisecond))
+
+	syncCh := make(chan error, 100)
+	go client.WatchSync(t.Context(), func(err error) {
+		syncCh <- err
+	})
+	stateCh := make(chan agones.State, 100)
+	go client.WatchState(t.Context(), func(state agones.State) {
+		stateCh <- state
+	})
+	go client.Run(t.Context())
+
+	// Until the watch connects, the polled game server differs from the empty watched game server as well.
+	timeoutCh := time.After(5 * time.Second)
+	select {
+	case err := <-syncCh:
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "watched game server differs from polled game server: state ")
+	case <-timeoutCh:
+		require.Fail(t, "timeout waiting for sync error")
+	}
+	for found := false; !found; {
+		select {
+		case state := <-stateCh:
+			found = state == agones.StateAllocated
+		case <-timeoutCh:
+			require.Fail(t, "timeout waiting for polled state")
+		}
+	}
+
+	// The watch stays out of sync while it does not deliver the polled game server, and older game servers are ignored.
+	stream.ch <- newSDKGameServer("Ready", "3")
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, syncCh)
+	assert.Empty(t, stateCh)
+
+	stream.ch <- newSDKGameServer("Allocated", "5")
+	select {
+	case err := <-syncCh:
+		assert.NoError(t, err)
+	case <-timeoutCh:
+		require.Fail(t, "timeout waiting for sync")
+	}
+	assert.Empty(t, stateCh)
+}
+
+func newSDKGameServer(state, version string) *sdk.GameServer {
+	return &sdk.GameServer{
+		ObjectMeta: &sdk.GameServer_ObjectMeta{ResourceVersion: version},
+		Status:     &sdk.GameServer_Status{State: state},
+	}
+}
+
+func TestClient_Reserve(t *testing.T) {
+	m := &mockSDK{}
+	m.On("Reserve", &sdk.Duration{Seconds: 5}).Return(&sdk.Empty{}, nil).Once()
+
+	client := agones.NewClient(m)
+	err := client.Reserve(t.Context(), 5*time.Second)
+
+	require.NoError(t, err)
+	m.AssertExpectations(t)
+}
+
+func TestClient_SetLabel(t *testing.T) {
+	m := &mockSDK{}
+	m.On("SetLabel", &sdk.KeyValue{Key: "foo", Value: "bar"}).Return(&sdk.Empty{}, errors.New("test")).Once()
+
+	client := agones.NewClient(m)
+	err := client.SetLabel(t.Context(), "foo", "bar")
+
+	require.EqualError(t, err, "setting label foo: test")
+	m.AssertExpectations(t)
+}
+
 type mockSDK struct {
 	mock.Mock
 	mockSDKUnimplemented
@@ -137,6 +268,43 @@ func (m *mockSDK) Shutdown(_ context.Context, in *sdk.Empty, _ ...grpc.CallOptio
 	return args.Get(0).(*sdk.Empty), args.Error(1)
 }
 
+func (m *mockSDK) Reserve(_ context.Context, in *sdk.Duration, _ ...grpc.CallOption) (*sdk.Empty, error) {
+	args := m.Called(in)
+	return args.Get(0).(*sdk.Empty), args.Error(1)
+}
+
+func (m *mockSDK) SetLabel(_ context.Context, in *sdk.KeyValue, _ ...grpc.CallOption) (*sdk.Empty, error) {
+	args := m.Called(in)
+	return args.Get(0).(*sdk.Empty), args.Error(1)
+}
+
+func (m *mockSDK) GetGameServer(_ context.Context, in *sdk.Empty, _ ...grpc.CallOption) (*sdk.GameServer, error) {
+	args := m.Called(in)
+	return args.Get(0).(*sdk.GameServer), args.Error(1)
+}
+
+func (m *mockSDK) WatchGameServer(_ context.Context, in *sdk.Empty, _ ...grpc.CallOption) (sdk.SDK_WatchGameServerClient, error) {
+	args := m.Called(in)
+	stream, _ := args.Get(0).(sdk.SDK_WatchGameServerClient)
+	return stream, args.Error(1)
+}
+
+type mockWatchStream struct {
+	grpc.ClientStream
+
+	ctx context.Context //nolint:containedctx // Ends the stream.
+	ch  chan *sdk.GameServer
+}
+
+func (s *mockWatchStream) Recv() (*sdk.GameServer, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	case gs := <-s.ch:
+		return gs, nil
+	}
+}
+
 type mockSDKUnimplemented struct{}
 
 func (m *mockSDKUnimplemented) Ready(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.Empty, error) {
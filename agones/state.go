@@ -0,0 +1,116 @@
+package agones
+
+import (
+	"errors"
+	"slices"
+)
+
+// State represents an Agones state.
+type State string
+
+const (
+	// StatePortAllocation is the Agones state PortAllocation.
+	// The state indicates that the game server has dynamic ports that are being allocated.
+	StatePortAllocation State = "PortAllocation"
+
+	// StateCreating is the Agones state Creating.
+	// The state indicates that the pod of the game server is being created.
+	StateCreating State = "Creating"
+
+	// StateStarting is the Agones state Starting.
+	// The state indicates that the pod of the game server has been created, but is not yet scheduled.
+	StateStarting State = "Starting"
+
+	// StateScheduled is the Agones state Scheduled.
+	// The state indicates that the pod of the game server has been scheduled onto a node.
+	StateScheduled State = "Scheduled"
+
+	// StateRequestReady is the Agones state RequestReady.
+	// The state indicates that the game server requested to become Ready.
+	StateRequestReady State = "RequestReady"
+
+	// StateReady is the Agones state Ready.
+	// The state indicates that the game server is ready to receive traffic.
+	StateReady State = "Ready"
+
+	// StateReserved is the Agones state Reserved.
+	// The state indicates that the game server is reserved and can not be deleted, but may still be allocated.
+	StateReserved State = "Reserved"
+
+	// StateAllocated is the Agones state Allocated.
+	// The state indicates that the game server hosts a game session.
+	StateAllocated State = "Allocated"
+
+	// StateShutdown is the Agones state Shutdown.
+	// The state indicates that the game server is shutting down.
+	StateShutdown State = "Shutdown"
+
+	// StateUnhealthy is the Agones state Unhealthy.
+	// The state indicates that the game server failed its health checks and is going to be deleted.
+	StateUnhealthy State = "Unhealthy"
+
+	// StateError is the Agones state Error.
+	// The state indicates that the game server could not be created and is going to be deleted.
+	StateError State = "Error"
+)
+
+// states are all known Agones states.
+var states = []State{
+	StatePortAllocation, StateCreating, StateStarting, StateScheduled, StateRequestReady, StateReady, StateReserved,
+	StateAllocated, StateShutdown, StateUnhealthy, StateError,
+}
+
+// transitions are the expected transitions from one Agones state to others.
+//
+// Transitions to Unhealthy and Error are never expected, they are caused by Agones.
+var transitions = map[State][]State{
+	StatePortAllocation: {StateCreating},
+	StateCreating:       {StateStarting},
+	StateStarting:       {StateScheduled},
+	StateScheduled:      {StateRequestReady, StateReady, StateReserved, StateShutdown},
+	StateRequestReady:   {StateReady, StateShutdown},
+	StateReady:          {StateReserved, StateAllocated, StateShutdown},
+	StateReserved:       {StateRequestReady, StateReady, StateAllocated, StateShutdown},
+	StateAllocated:      {StateRequestReady, StateReady, StateShutdown},
+	StateUnhealthy:      {StateShutdown},
+	StateError:          {StateShutdown},
+}
+
+// ParseState parses an Agones state.
+func ParseState(s string) (State, error) {
+	state := State(s)
+	if !state.IsKnown() {
+		return state, errors.New("unknown state: " + s)
+	}
+	return state, nil
+}
+
+// IsKnown determines if the state is a known Agones state.
+func (s State) IsKnown() bool {
+	return slices.Contains(states, s)
+}
+
+// IsFinal determines if the state is final, meaning that the game server is going to be deleted.
+func (s State) IsFinal() bool {
+	return s == StateShutdown || s == StateUnhealthy || s == StateError
+}
+
+// Transition is a transition from one Agones state to another.
+type Transition struct {
+	From State
+	To   State
+}
+
+// IsExpected determines if the transition is expected.
+//
+// The first transition, from no state, is always expected.
+func (t Transition) IsExpected() bool {
+	if t.From == "" {
+		return true
+	}
+	return slices.Contains(transitions[t.From], t.To)
+}
+
+func (t Transition) String() string {
+	return string(t.From) + " → " + string(t.To)
+}
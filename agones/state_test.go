@@ -0,0 +1,89 @@
+package agones_test
+
+import (
+	"testing"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseState(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string
+		want    agones.State
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles known state",
+			state:   "Unhealthy",
+			want:    agones.StateUnhealthy,
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles unknown state",
+			state:   "Foo",
+			want:    "Foo",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles empty state",
+			state:   "",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := agones.ParseState(test.state)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestTransition_IsExpected(t *testing.T) {
+	tests := []struct {
+		name  string
+		trans agones.Transition
+		want  bool
+	}{
+		{
+			name:  "handles first state",
+			trans: agones.Transition{To: agones.StateAllocated},
+			want:  true,
+		},
+		{
+			name:  "handles allocation",
+			trans: agones.Transition{From: agones.StateReady, To: agones.StateAllocated},
+			want:  true,
+		},
+		{
+			name:  "handles re-ready",
+			trans: agones.Transition{From: agones.StateAllocated, To: agones.StateRequestReady},
+			want:  true,
+		},
+		{
+			name:  "handles becoming unhealthy",
+			trans: agones.Transition{From: agones.StateAllocated, To: agones.StateUnhealthy},
+			want:  false,
+		},
+		{
+			name:  "handles leaving shutdown",
+			trans: agones.Transition{From: agones.StateShutdown, To: agones.StateReady},
+			want:  false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.want, test.trans.IsExpected())
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package fakegameserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgonesDisconnect(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		wantType fakegameserver.MessageType
+		wantErr  string
+	}{
+		{
+			name:     "handles continue",
+			action:   "continue",
+			wantType: fakegameserver.MessageTypeInfo,
+			wantErr:  "agones connection lost",
+		},
+		{
+			name:     "handles stop health",
+			action:   "stop-health",
+			wantType: fakegameserver.MessageTypeAgonesStopHealth,
+			wantErr:  "agones connection lost",
+		},
+		{
+			name:     "handles exit",
+			action:   "signal:9",
+			wantType: fakegameserver.MessageTypeExit,
+			wantErr:  "signal 9",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			q := queue.NewFifo[fakegameserver.Message]()
+			t.Cleanup(q.Shutdown)
+
+			action, err := fakegameserver.ParseAction(test.action)
+			require.NoError(t, err)
+
+			disconnect := fakegameserver.NewAgonesDisconnect(agones.NewClient(nil), 10*time.Millisecond, action)
+			go disconnect.Run(t.Context(), q)
+
+			disconnect.Consume(fakegameserver.Message{Type: fakegameserver.MessageTypeAgonesConnection, Payload: false})
+
+			msg, shutdown := q.Get()
+
+			require.False(t, shutdown)
+			assert.Equal(t, test.wantType, msg.Type)
+			assert.EqualError(t, msg.Error, test.wantErr)
+		})
+	}
+}
+
+func TestAgonesStateUpdater_GivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		want     []fakegameserver.MessageType
+	}{
+		{
+			name:     "single attempt reports the failed update",
+			attempts: 1,
+			want: []fakegameserver.MessageType{
+				fakegameserver.MessageTypeAgonesUpdateAttempt,
+				fakegameserver.MessageTypeAgonesUpdate,
+				fakegameserver.MessageTypeExit,
+			},
+		},
+		{
+			name:     "retried update is only reported by the action",
+			attempts: 2,
+			want: []fakegameserver.MessageType{
+				fakegameserver.MessageTypeAgonesUpdateAttempt,
+				fakegameserver.MessageTypeAgonesUpdateAttempt,
+				fakegameserver.MessageTypeExit,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := queue.NewFifo[fakegameserver.Message]()
+			t.Cleanup(q.Shutdown)
+
+			action, err := fakegameserver.ParseAction("code:3")
+			require.NoError(t, err)
+
+			// The state Reserved can not be requested, so every attempt fails.
+			updater := fakegameserver.NewAgonesStateUpdater(agones.NewClient(nil), test.attempts, 0, action)
+			go updater.Run(t.Context(), q)
+
+			updater.Consume(fakegameserver.Message{ID: "req", Type: fakegameserver.MessageTypeAgonesRequestUpdate, Payload: agones.StateReserved})
+
+			var got []fakegameserver.MessageType
+			for range test.want {
+				msg, shutdown := q.Get()
+				require.False(t, shutdown)
+				assert.Equal(t, "req", msg.CausationID)
+				got = append(got, msg.Type)
+			}
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestAgonesStateTimer_AwaitsUpdates(t *testing.T) {
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	timer := fakegameserver.NewAgonesStateTimer(fakegameserver.NewProjection())
+	timer.AddState(agones.StateReady, 0)
+	timer.AddState(agones.StateAllocated, 0)
+	go timer.Run(t.Context(), q)
+
+	timer.Consume(fakegameserver.EventAgonesConnection.Message("test", nil, true))
+
+	req, shutdown := q.Get()
+	require.False(t, shutdown)
+	state, _ := fakegameserver.EventAgonesRequestUpdate.Payload(req)
+	assert.Equal(t, agones.StateReady, state)
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, q.Len(), "The next state must only be requested once the update is done.")
+
+	timer.Consume(req.Reply(fakegameserver.EventAgonesUpdate.Message("test", nil, agones.StateReady)))
+
+	req, shutdown = q.Get()
+	require.False(t, shutdown)
+	state, _ = fakegameserver.EventAgonesRequestUpdate.Payload(req)
+	assert.Equal(t, agones.StateAllocated, state)
+}
+
+func TestShutdown(t *testing.T) {
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	proj := fakegameserver.NewProjection()
+	shutdown := fakegameserver.NewAgonesShutdown(proj, func() bool { return true })
+	go shutdown.Run(t.Context(), q)
+
+	req := fakegameserver.EventAgonesRequestUpdate.Message("", nil, agones.StateShutdown)
+	req.ID = "req"
+	msgs := []fakegameserver.Message{
+		fakegameserver.EventAgonesUpdate.Message("", nil, agones.StateReady),
+		req.Reply(fakegameserver.EventAgonesUpdate.Message("", nil, agones.StateShutdown)),
+	}
+	for _, msg := range msgs {
+		proj.Apply(msg) // As the game server does before dispatching.
+		shutdown.Consume(msg)
+	}
+
+	// Only the watched state counts, the requested state alone does not exit.
+	assert.Never(t, func() bool { return q.Len() > 0 }, 50*time.Millisecond, 5*time.Millisecond)
+
+	msg := fakegameserver.EventAgonesGameServer.Message("", nil, agones.GameServer{State: agones.StateShutdown})
+	proj.Apply(msg)
+	shutdown.Consume(msg)
+
+	got, closed := q.Get()
+
+	require.False(t, closed)
+	assert.Equal(t, fakegameserver.MessageTypeExit, got.Type)
+	assert.Contains(t, got.Description, "Agones state changed to Shutdown")
+	assert.EqualError(t, got.Error, "signal 15")
+}
+
+func TestParseAction(t *testing.T) {
+	for _, action := range []string{"continue", "stop-health", "code:3", "signal:11"} {
+		_, err := fakegameserver.ParseAction(action)
+
+		assert.NoError(t, err, action)
+	}
+
+	_, err := fakegameserver.ParseAction("crash")
+
+	assert.Error(t, err)
+}
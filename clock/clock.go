@@ -0,0 +1,92 @@
+// Package clock provides clocks to measure and wait for time, either on the wall clock, on an accelerated clock, or on
+// a virtual clock that only advances when told to.
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells and waits for time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+
+	// NewTimer creates a new timer that sends the current time on its channel after the duration.
+	NewTimer(d time.Duration) Timer
+
+	// NewTicker creates a new ticker that sends the current time on its channel after each period.
+	NewTicker(d time.Duration) Ticker
+
+	// AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event timer, as time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on. It is nil for timers created by AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing. It returns false if the timer already fired or was stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after the duration. It returns false if the timer already fired or was stopped.
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a periodic timer, as time.Ticker.
+type Ticker interface {
+	// C returns the channel the ticks are sent on.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker.
+	Stop()
+
+	// Reset stops the ticker and resets its period to the duration.
+	Reset(d time.Duration)
+}
+
+type ctxKey struct{}
+
+// NewContext returns a context carrying the clock.
+func NewContext(ctx context.Context, c Clock) context.Context {
+	return context.WithValue(ctx, ctxKey{}, c)
+}
+
+// FromContext returns the clock of the context, or the real clock if the context carries none.
+func FromContext(ctx context.Context) Clock {
+	if c, ok := ctx.Value(ctxKey{}).(Clock); ok {
+		return c
+	}
+	return Real()
+}
+
+// Hold holds a virtual clock while the code under test is busy, until Release is called as often, see Virtual.Idle.
+// It is a no-op on other clocks.
+//
+// Code that wakes up a goroutine holds the clock before, e.g. before it starts the goroutine or sends it a value, and
+// the goroutine releases the clock once it is done and waits again. Ticks of timers and tickers on a virtual clock are
+// held this way, as are the calls of AfterFunc.
+func Hold(c Clock) {
+	if h, ok := c.(holder); ok {
+		h.Hold()
+	}
+}
+
+// Release releases a hold of a virtual clock, see Hold. It is a no-op on other clocks.
+func Release(c Clock) {
+	if h, ok := c.(holder); ok {
+		h.Release()
+	}
+}
+
+type holder interface {
+	Hold()
+	Release()
+}
@@ -0,0 +1,141 @@
+package clock_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVirtual_Advance(t *testing.T) {
+	v := clock.NewVirtual(start)
+	timer := v.NewTimer(2 * time.Second)
+	ticker := v.NewTicker(time.Second)
+	stopped := v.NewTimer(time.Second)
+	require.True(t, stopped.Stop())
+
+	v.Advance(1500 * time.Millisecond)
+
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+	assert.Empty(t, timer.C())
+	assert.Empty(t, stopped.C())
+	assert.Equal(t, start.Add(1500*time.Millisecond), v.Now())
+
+	v.Advance(time.Second)
+
+	assert.Equal(t, start.Add(2*time.Second), <-timer.C())
+	assert.Equal(t, start.Add(2*time.Second), <-ticker.C())
+	assert.False(t, timer.Stop())
+	assert.Equal(t, 1, v.Timers())
+}
+
+func TestVirtual_AdvanceToNext(t *testing.T) {
+	v := clock.NewVirtual(start)
+	fired := make(chan time.Time, 1)
+	v.AfterFunc(time.Minute, func() { fired <- v.Now() })
+
+	d, ok := v.AdvanceToNext()
+
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+	assert.Equal(t, start.Add(time.Minute), <-fired)
+
+	_, ok = v.AdvanceToNext()
+
+	assert.False(t, ok)
+}
+
+func TestVirtual_FireNext(t *testing.T) {
+	v := clock.NewVirtual(start)
+	first, second := v.After(time.Minute), v.After(time.Minute)
+
+	ok := v.FireNext()
+
+	require.True(t, ok)
+	assert.Equal(t, start.Add(time.Minute), <-first)
+	assert.Empty(t, second)
+	assert.Equal(t, 1, v.Timers())
+
+	require.True(t, v.FireNext())
+	assert.Equal(t, start.Add(time.Minute), <-second)
+	assert.False(t, v.FireNext())
+}
+
+func TestVirtual_WaitTimers(t *testing.T) {
+	v := clock.NewVirtual(start)
+	go func() {
+		<-v.After(time.Second)
+	}()
+
+	err := v.WaitTimers(t.Context(), 1)
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, v.Timers())
+}
+
+func TestVirtual_Hold(t *testing.T) {
+	v := clock.NewVirtual(start)
+	assert.True(t, isClosed(v.Idle()), "initially")
+
+	v.Hold()
+	v.Hold()
+	v.Release()
+	assert.False(t, isClosed(v.Idle()), "held")
+
+	v.Release()
+	v.Release()
+	assert.True(t, isClosed(v.Idle()), "released")
+}
+
+func TestVirtual_HoldsTicks(t *testing.T) {
+	v := clock.NewVirtual(start)
+	timer := v.NewTimer(time.Second)
+	stopped := v.NewTimer(time.Second)
+	called := make(chan struct{})
+	v.AfterFunc(time.Second, func() { <-called })
+
+	v.Advance(time.Second)
+	assert.False(t, isClosed(v.Idle()), "fired")
+
+	<-timer.C()
+	v.Release()
+	stopped.Stop()
+	assert.False(t, isClosed(v.Idle()), "function running")
+
+	close(called)
+	<-v.Idle()
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestScaled(t *testing.T) {
+	v := clock.NewVirtual(start)
+	s := clock.NewScaled(v, 60)
+	timer := s.NewTimer(time.Minute)
+
+	v.Advance(time.Second)
+
+	assert.Equal(t, start.Add(time.Minute), <-timer.C())
+	assert.Equal(t, time.Minute, s.Since(start))
+}
+
+func TestFromContext(t *testing.T) {
+	v := clock.NewVirtual(start)
+
+	ctx := clock.NewContext(context.Background(), v)
+
+	assert.Same(t, v, clock.FromContext(ctx))
+	assert.Equal(t, clock.Real(), clock.FromContext(context.Background()))
+}
@@ -0,0 +1,139 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+var _ Clock = (*Scaled)(nil)
+
+// Scaled is a clock that runs faster (or slower) than its base clock by a factor, keeping the relative timing.
+//
+// With a factor of 60, a timer of 30 minutes fires after 30 seconds on the base clock, and the scaled clock tells 30
+// minutes later.
+type Scaled struct {
+	base   Clock
+	factor float64
+	origin time.Time
+}
+
+// NewScaled returns a new clock, scaled by the factor from now on. A factor of zero or less is treated as 1.
+func NewScaled(base Clock, factor float64) *Scaled {
+	if factor <= 0 {
+		factor = 1
+	}
+
+	return &Scaled{
+		base:   base,
+		factor: factor,
+		origin: base.Now(),
+	}
+}
+
+// Factor returns the factor of the clock.
+func (s *Scaled) Factor() float64 {
+	return s.factor
+}
+
+// Now returns the current scaled time.
+func (s *Scaled) Now() time.Time {
+	return s.origin.Add(time.Duration(float64(s.base.Since(s.origin)) * s.factor))
+}
+
+// Since returns the scaled time elapsed since t.
+func (s *Scaled) Since(t time.Time) time.Duration {
+	return s.Now().Sub(t)
+}
+
+// After waits for the scaled duration to elapse and then sends the current time on the returned channel.
+func (s *Scaled) After(d time.Duration) <-chan time.Time {
+	return s.NewTimer(d).C()
+}
+
+// NewTimer creates a new timer that sends the current time on its channel after the scaled duration.
+func (s *Scaled) NewTimer(d time.Duration) Timer {
+	t := &scaledTimer{ch: make(chan time.Time, 1), s: s}
+	t.Timer = s.base.AfterFunc(s.unscale(d), func() {
+		select {
+		case t.ch <- s.Now():
+		default:
+		}
+	})
+	return t
+}
+
+// NewTicker creates a new ticker that sends the current time on its channel after each scaled period.
+func (s *Scaled) NewTicker(d time.Duration) Ticker {
+	t := &scaledTicker{
+		Ticker: s.base.NewTicker(s.unscale(d)),
+		ch:     make(chan time.Time, 1),
+		s:      s,
+		stopCh: make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// AfterFunc waits for the scaled duration to elapse and then calls f in its own goroutine.
+func (s *Scaled) AfterFunc(d time.Duration, f func()) Timer {
+	return &scaledTimer{Timer: s.base.AfterFunc(s.unscale(d), f), s: s}
+}
+
+// unscale returns the duration on the base clock.
+func (s *Scaled) unscale(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return max(time.Duration(float64(d)/s.factor), 1)
+}
+
+type scaledTimer struct {
+	Timer
+
+	ch chan time.Time
+	s  *Scaled
+}
+
+func (t *scaledTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *scaledTimer) Reset(d time.Duration) bool {
+	return t.Timer.Reset(t.s.unscale(d))
+}
+
+type scaledTicker struct {
+	Ticker
+
+	ch     chan time.Time
+	s      *Scaled
+	once   sync.Once
+	stopCh chan struct{}
+}
+
+func (t *scaledTicker) run() {
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-t.Ticker.C():
+			select {
+			case t.ch <- t.s.Now():
+			default: // Drop ticks for slow receivers, as time.Ticker does.
+			}
+		}
+	}
+}
+
+func (t *scaledTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *scaledTicker) Stop() {
+	t.Ticker.Stop()
+	t.once.Do(func() { close(t.stopCh) })
+}
+
+func (t *scaledTicker) Reset(d time.Duration) {
+	t.Ticker.Reset(t.s.unscale(d))
+}
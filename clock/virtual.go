@@ -0,0 +1,364 @@
+package clock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+var _ Clock = (*Virtual)(nil)
+
+// Virtual is a clock that only advances when told to, firing the timers that are due on the way in order.
+//
+// Timers and tickers created on it only fire by Advance, so tests do not need to wait for real time to pass.
+//
+// The code under test holds the clock while it is busy, see Hold, so a driver can advance the clock once the code
+// only waits for the clock, see Idle.
+type Virtual struct {
+	mu      sync.Mutex
+	now     time.Time
+	seq     uint64
+	timers  []*virtualTimer
+	changed chan struct{}
+	holds   int
+	idle    chan struct{}
+}
+
+// NewVirtual returns a new virtual clock at the start time.
+func NewVirtual(start time.Time) *Virtual {
+	idle := make(chan struct{})
+	close(idle)
+
+	return &Virtual{
+		now:     start,
+		changed: make(chan struct{}),
+		idle:    idle,
+	}
+}
+
+// Now returns the current virtual time.
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.now
+}
+
+// Since returns the virtual time elapsed since t.
+func (v *Virtual) Since(t time.Time) time.Duration {
+	return v.Now().Sub(t)
+}
+
+// After sends the virtual time on the returned channel, once the clock advanced by the duration.
+func (v *Virtual) After(d time.Duration) <-chan time.Time {
+	return v.NewTimer(d).C()
+}
+
+// NewTimer creates a new timer that sends the virtual time on its channel, once the clock advanced by the duration.
+func (v *Virtual) NewTimer(d time.Duration) Timer {
+	return v.add(d, 0, make(chan time.Time, 1), nil)
+}
+
+// NewTicker creates a new ticker that sends the virtual time on its channel, each time the clock advanced by the
+// period.
+func (v *Virtual) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+	return virtualTicker{v.add(d, d, make(chan time.Time, 1), nil)}
+}
+
+// AfterFunc calls f in its own goroutine, once the clock advanced by the duration.
+func (v *Virtual) AfterFunc(d time.Duration, f func()) Timer {
+	return v.add(d, 0, nil, f)
+}
+
+// Advance advances the clock by the duration, firing all timers that are due on the way in order.
+func (v *Virtual) Advance(d time.Duration) {
+	v.mu.Lock()
+	target := v.now.Add(d)
+	v.mu.Unlock()
+
+	for {
+		if !v.fireNext(target) {
+			return
+		}
+	}
+}
+
+// AdvanceToNext advances the clock to the next timer and fires all timers due at that time. It returns the advanced
+// duration, and false if there is no timer.
+func (v *Virtual) AdvanceToNext() (time.Duration, bool) {
+	v.mu.Lock()
+	t := v.next()
+	if t == nil {
+		v.mu.Unlock()
+		return 0, false
+	}
+	at, now := t.at, v.now
+	v.mu.Unlock()
+
+	v.Advance(at.Sub(now))
+	return at.Sub(now), true
+}
+
+// FireNext advances the clock to the next timer and fires only that timer, even if more timers are due at that time,
+// e.g. to let the code under test react to each timer in turn. It returns false if there is no timer.
+func (v *Virtual) FireNext() bool {
+	v.mu.Lock()
+	t := v.next()
+	if t == nil {
+		v.mu.Unlock()
+		return false
+	}
+	at := t.at
+	v.mu.Unlock()
+
+	return v.fireNext(at)
+}
+
+// Next returns the time of the next timer, and false if there is no timer.
+func (v *Virtual) Next() (time.Time, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t := v.next()
+	if t == nil {
+		return time.Time{}, false
+	}
+	return t.at, true
+}
+
+// Timers returns the number of pending timers and tickers.
+func (v *Virtual) Timers() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return len(v.timers)
+}
+
+// WaitTimers waits until at least n timers and tickers are pending, e.g. until the code under test armed its timers
+// before advancing the clock.
+func (v *Virtual) WaitTimers(ctx context.Context, n int) error {
+	for {
+		v.mu.Lock()
+		pending, changed := len(v.timers), v.changed
+		v.mu.Unlock()
+
+		if pending >= n {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Hold holds the clock while the code under test is busy, see the package function Hold.
+func (v *Virtual) Hold() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.hold()
+}
+
+// Release releases a hold of the clock, see the package function Hold. Releasing a clock that is not held is a no-op.
+func (v *Virtual) Release() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.release()
+}
+
+// Idle returns a channel that is closed while the clock is not held, i.e. once the code under test only waits for the
+// clock, e.g. to advance the clock only then.
+func (v *Virtual) Idle() <-chan struct{} {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	return v.idle
+}
+
+// fireNext fires the next timer that is due at the target, or advances the clock to the target. It returns false
+// once no timer is due.
+func (v *Virtual) fireNext(target time.Time) bool {
+	v.mu.Lock()
+
+	t := v.next()
+	if t == nil || t.at.After(target) {
+		if target.After(v.now) {
+			v.now = target
+		}
+		v.mu.Unlock()
+		return false
+	}
+
+	if t.at.After(v.now) {
+		v.now = t.at
+	}
+	now := v.now
+	if t.period > 0 {
+		t.at = t.at.Add(t.period)
+		t.seq = v.nextSeq()
+	} else {
+		v.remove(t)
+	}
+	v.notify()
+
+	if t.fn != nil {
+		v.hold() // Until the function returned.
+		v.mu.Unlock()
+
+		go func() {
+			defer v.Release()
+			t.fn()
+		}()
+		return true
+	}
+	select {
+	case t.ch <- now:
+		v.hold() // Until the receiver is done and waits again.
+	default: // Drop ticks for slow receivers, as time.Ticker does.
+	}
+	v.mu.Unlock()
+	return true
+}
+
+func (v *Virtual) add(d, period time.Duration, ch chan time.Time, fn func()) *virtualTimer {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t := &virtualTimer{v: v, period: period, ch: ch, fn: fn}
+	v.schedule(t, d)
+	return t
+}
+
+// schedule schedules the timer after the duration. It must be called with the lock held.
+func (v *Virtual) schedule(t *virtualTimer, d time.Duration) {
+	t.at = v.now.Add(d)
+	t.seq = v.nextSeq()
+	v.timers = append(v.timers, t)
+	v.notify()
+}
+
+// next returns the next timer to fire, in the order of their time and creation. It must be called with the lock held.
+func (v *Virtual) next() *virtualTimer {
+	var next *virtualTimer
+	for _, t := range v.timers {
+		if next == nil || t.at.Before(next.at) || (t.at.Equal(next.at) && t.seq < next.seq) {
+			next = t
+		}
+	}
+	return next
+}
+
+// remove removes the timer. It returns false if the timer is not pending. It must be called with the lock held.
+func (v *Virtual) remove(t *virtualTimer) bool {
+	for i, pending := range v.timers {
+		if pending == t {
+			v.timers = append(v.timers[:i], v.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// hold holds the clock. It must be called with the lock held.
+func (v *Virtual) hold() {
+	if v.holds == 0 {
+		v.idle = make(chan struct{})
+	}
+	v.holds++
+}
+
+// release releases a hold of the clock. It must be called with the lock held.
+func (v *Virtual) release() {
+	if v.holds == 0 {
+		return
+	}
+	v.holds--
+	if v.holds == 0 {
+		close(v.idle)
+	}
+}
+
+// drain drops a tick of the timer that was not received, releasing its hold. It must be called with the lock held.
+func (v *Virtual) drain(t *virtualTimer) {
+	select {
+	case <-t.ch:
+		v.release()
+	default:
+	}
+}
+
+// notify notifies the waiters on a change of the pending timers. It must be called with the lock held.
+func (v *Virtual) notify() {
+	close(v.changed)
+	v.changed = make(chan struct{})
+}
+
+func (v *Virtual) nextSeq() uint64 {
+	v.seq++
+	return v.seq
+}
+
+type virtualTimer struct {
+	v      *Virtual
+	at     time.Time
+	seq    uint64
+	period time.Duration
+	ch     chan time.Time
+	fn     func()
+}
+
+func (t *virtualTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *virtualTimer) Stop() bool {
+	t.v.mu.Lock()
+	defer t.v.mu.Unlock()
+
+	t.v.drain(t)
+	if !t.v.remove(t) {
+		return false
+	}
+	t.v.notify()
+	return true
+}
+
+func (t *virtualTimer) Reset(d time.Duration) bool {
+	t.v.mu.Lock()
+	defer t.v.mu.Unlock()
+
+	if t.period > 0 {
+		t.period = d
+	}
+
+	t.v.drain(t)
+	pending := t.v.remove(t)
+	t.v.schedule(t, d)
+	return pending
+}
+
+type virtualTicker struct {
+	t *virtualTimer
+}
+
+func (t virtualTicker) C() <-chan time.Time {
+	return t.t.ch
+}
+
+func (t virtualTicker) Stop() {
+	t.t.Stop()
+}
+
+func (t virtualTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.t.Reset(d)
+}
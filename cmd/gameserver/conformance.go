@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/conformance"
+	"github.com/antiphp/fakegameserver/internal/junit"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagConformanceTimeout       = "timeout"
+	flagConformanceReserve       = "reserve"
+	flagConformanceCounter       = "counter"
+	flagConformanceHealthTimeout = "health-timeout"
+)
+
+var conformanceCommand = &cli.Command{
+	Name: "conformance",
+	Usage: "Check the Agones SDK server at --agones-addr against the documented behavior of the Agones SDK. The checks " +
+		"leave the game server Allocated or Unhealthy, so run them against a game server that is not in use.",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  flagConformanceTimeout,
+			Usage: "The `duration` a change may take to show up in the watch stream.",
+			Value: 10 * time.Second,
+		},
+		&cli.DurationFlag{
+			Name:  flagConformanceReserve,
+			Usage: "The `duration` to reserve the game server for, in whole seconds.",
+			Value: 5 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  flagConformanceCounter,
+			Usage: "Check that the counter with the `name` stays within its capacity. The counter check is skipped if empty.",
+		},
+		&cli.DurationFlag{
+			Name:  flagConformanceHealthTimeout,
+			Usage: "The `duration` the game server may take to become Unhealthy once the health reports stop.",
+			Value: time.Minute,
+		},
+		&cli.StringFlag{
+			Name:  flagJUnit,
+			Usage: "Write the results as JUnit XML to the `path`.",
+		},
+	},
+	Action: checkConformance,
+}
+
+func checkConformance(c *cli.Context) error {
+	client, err := newAgonesClient(c, clock.Real())
+	if err != nil {
+		return err
+	}
+
+	started := time.Now()
+	results := conformance.Run(c.Context, client, conformance.Config{
+		Timeout:        c.Duration(flagConformanceTimeout),
+		Reserve:        c.Duration(flagConformanceReserve),
+		Counter:        c.String(flagConformanceCounter),
+		HealthInterval: c.Duration(flagHealthReportInterval),
+		HealthTimeout:  c.Duration(flagConformanceHealthTimeout),
+	})
+
+	suite, err := printConformance(c.App.Writer, results)
+	if err != nil {
+		return err
+	}
+	suite.Time = junit.Seconds(time.Since(started))
+
+	if path := c.String(flagJUnit); path != "" {
+		if err = writeJUnit(path, []junit.Suite{suite}); err != nil {
+			return err
+		}
+	}
+
+	if suite.Failures > 0 {
+		return fmt.Errorf("%d of %d conformance checks failed", suite.Failures, len(results))
+	}
+	return nil
+}
+
+// printConformance prints the results as matrix, and returns them as test suite.
+func printConformance(w io.Writer, results []conformance.Result) (junit.Suite, error) {
+	cases := make([]junit.Case, 0, len(results))
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CHECK\tRESULT\tDETAIL")
+	for _, res := range results {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", res.Check, res.Status, res.Detail)
+
+		tc := junit.Case{Name: res.Check, Classname: "conformance", Time: junit.Seconds(res.Duration)}
+		switch res.Status {
+		case conformance.StatusFail:
+			tc.Failure = &junit.Failure{Message: res.Detail}
+		case conformance.StatusSkip:
+			tc.Skipped = &junit.Skipped{Message: res.Detail}
+		}
+		cases = append(cases, tc)
+	}
+	return junit.NewSuite("conformance", 0, cases), tw.Flush()
+}
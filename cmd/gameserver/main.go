@@ -4,9 +4,11 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
+	"github.com/antiphp/fakegameserver"
 	"github.com/antiphp/fakegameserver/internal/exiterror"
 	"github.com/ettle/strcase"
 	"github.com/hamba/cmd/v2"
@@ -17,17 +19,52 @@ const (
 	flagExitCode             = "exit-code"
 	flagExitSignal           = "exit-signal"
 	flagExitAfter            = "exit-after"
+	flagExitSchedule         = "exit-schedule"
 	flagAgonesDisabled       = "agones-disabled"
 	flagAgonesAddr           = "agones-addr"
+	flagAgonesPollInterval   = "agones-poll-interval"
+	flagAgonesWatchDisabled  = "agones-watch-disabled"
+	flagDisconnectTimeout    = "agones-disconnect-timeout"
+	flagDisconnectAction     = "agones-disconnect-action"
+	flagUpdateAttempts       = "agones-update-attempts"
+	flagUpdateDeadline       = "agones-update-deadline"
+	flagUpdateGiveUp         = "agones-update-give-up"
 	flagReadyAfter           = "ready-after"
 	flagAllocatedAfter       = "allocated-after"
 	flagShutdownAfter        = "shutdown-after"
 	flagExitOnShutdown       = "shutdown-causes-exit"
 	flagHealthReportDelay    = "health-report-delay"
 	flagHealthReportInterval = "health-report-interval"
+	flagRoomsCounter         = "rooms-counter"
+	flagRoomDurationMin      = "room-duration-min"
+	flagRoomDurationMax      = "room-duration-max"
+	flagRoomsIdleTimeout     = "rooms-idle-timeout"
+	flagPopulationCurve      = "population-curve"
+	flagPopulationTarget     = "population-target"
+	flagPopulationTimeScale  = "population-time-scale"
+	flagPopulationInterval   = "population-interval"
+	flagQueueSize            = "queue-size"
+	flagQueuePolicy          = "queue-policy"
+	flagInboxRingSize        = "inbox-ring-size"
+	flagConsumerTimeout      = "consumer-timeout"
+	flagPrintTopology        = "print-topology"
+	flagStopTimeout          = "stop-timeout"
+	flagShutdownOnExit       = "agones-shutdown-on-exit"
+	flagChaosDrop            = "chaos-drop"
+	flagChaosDelay           = "chaos-delay"
+	flagChaosDuplicate       = "chaos-duplicate"
+	flagTimeScale            = "time-scale"
+	flagProbeExpect          = "probe-expect"
+	flagProbeReport          = "probe-report"
 
 	catExit   = "Exit behavior"
 	catAgones = "Agones integration"
+	catRooms  = "Agones rooms (multiple game sessions)"
+	catPop    = "Agones population"
+	catQueue  = "Message queue"
+	catChaos  = "Chaos"
+	catTime   = "Time"
+	catProbe  = "Probe"
 )
 
 var version = "<unknown>"
@@ -51,6 +88,12 @@ var flags = cmd.Flags{
 		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagExitAfter))},
 		Category: catExit,
 	},
+	&cli.StringFlag{
+		Name:     flagExitSchedule,
+		Usage:    "Schedule at which to exit, either a cron expression like `0 4 * * *`, a macro like `@daily` or an interval like `@every 10m`.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagExitSchedule))},
+		Category: catExit,
+	},
 	&cli.BoolFlag{
 		Name:     flagAgonesDisabled,
 		Usage:    "Flag whether to disable the Agones integration.",
@@ -64,6 +107,60 @@ var flags = cmd.Flags{
 		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagAgonesAddr))},
 		Category: catAgones,
 	},
+	&cli.DurationFlag{
+		Name: flagAgonesPollInterval,
+		Usage: "Interval to poll the Agones game server and reconcile it with the watched game server. Polling replaces the watch, if " +
+			"watching is disabled or not supported.",
+		EnvVars:     []string{strcase.ToSNAKE(prefixEnv(flagAgonesPollInterval))},
+		DefaultText: "disabled",
+		Category:    catAgones,
+	},
+	&cli.BoolFlag{
+		Name:     flagAgonesWatchDisabled,
+		Usage:    "Flag whether to disable watching the Agones game server and poll instead.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagAgonesWatchDisabled))},
+		Category: catAgones,
+	},
+	&cli.DurationFlag{
+		Name:        flagDisconnectTimeout,
+		Usage:       "Duration of a lost Agones connection after which to take the disconnect action.",
+		EnvVars:     []string{strcase.ToSNAKE(prefixEnv(flagDisconnectTimeout))},
+		DefaultText: "disabled",
+		Category:    catAgones,
+	},
+	&cli.StringFlag{
+		Name:     flagDisconnectAction,
+		Usage:    "Action to take when the Agones connection is lost for too long, either `continue`, `stop-health`, `code:<code>` or `signal:<signal>`.",
+		Value:    string(fakegameserver.ActionContinue),
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagDisconnectAction))},
+		Category: catAgones,
+	},
+	&cli.IntFlag{
+		Name:     flagUpdateAttempts,
+		Usage:    "Maximum number of attempts to update the Agones state.",
+		Value:    1,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagUpdateAttempts))},
+		Category: catAgones,
+	},
+	&cli.DurationFlag{
+		Name:     flagUpdateDeadline,
+		Usage:    "Deadline of an Agones state update, including all attempts.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagUpdateDeadline))},
+		Category: catAgones,
+	},
+	&cli.StringFlag{
+		Name:     flagUpdateGiveUp,
+		Usage:    "Action to take when an Agones state update is given up, either `continue`, `stop-health`, `code:<code>` or `signal:<signal>`.",
+		Value:    string(fakegameserver.ActionContinue),
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagUpdateGiveUp))},
+		Category: catAgones,
+	},
+	&cli.BoolFlag{
+		Name:     flagShutdownOnExit,
+		Usage:    "Flag whether to transition to Agones state `Shutdown` when the game server exits, unless the state is already final.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagShutdownOnExit))},
+		Category: catAgones,
+	},
 	&cli.DurationFlag{
 		Name:     flagReadyAfter,
 		Usage:    "Duration after which to transition to Agones state `Ready`.",
@@ -105,6 +202,141 @@ var flags = cmd.Flags{
 		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagHealthReportInterval))},
 		Category: catAgones,
 	},
+	&cli.StringFlag{
+		Name:     flagRoomsCounter,
+		Usage:    "Name of the Agones counter that tracks the rooms. Enables hosting multiple game sessions (rooms) at once.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagRoomsCounter))},
+		Category: catRooms,
+	},
+	&cli.DurationFlag{
+		Name:     flagRoomDurationMin,
+		Usage:    "Minimum duration of a room.",
+		Value:    time.Minute,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagRoomDurationMin))},
+		Category: catRooms,
+	},
+	&cli.DurationFlag{
+		Name:     flagRoomDurationMax,
+		Usage:    "Maximum duration of a room.",
+		Value:    5 * time.Minute,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagRoomDurationMax))},
+		Category: catRooms,
+	},
+	&cli.DurationFlag{
+		Name:     flagRoomsIdleTimeout,
+		Usage:    "Duration after the last room ended, after which to transition to Agones state `Shutdown`.",
+		Value:    5 * time.Minute,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagRoomsIdleTimeout))},
+		Category: catRooms,
+	},
+	&cli.StringFlag{
+		Name: flagPopulationCurve,
+		Usage: "Population curve to apply, either `sine:<min>:<max>:<period>`, `step:<after>=<value>,...`, " +
+			"`spike:<base>:<peak>:<every>:<length>` or `csv:<path>`.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagPopulationCurve))},
+		Category: catPop,
+	},
+	&cli.StringFlag{
+		Name:     flagPopulationTarget,
+		Usage:    "Target to apply the population to, either `players` or `counter:<name>`.",
+		Value:    "players",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagPopulationTarget))},
+		Category: catPop,
+	},
+	&cli.Float64Flag{
+		Name:     flagPopulationTimeScale,
+		Usage:    "Factor by which to compress the population curve, e.g. `1440` runs a daily curve within a minute.",
+		Value:    1,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagPopulationTimeScale))},
+		Category: catPop,
+	},
+	&cli.DurationFlag{
+		Name:     flagPopulationInterval,
+		Usage:    "Interval in which to apply the population.",
+		Value:    time.Second,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagPopulationInterval))},
+		Category: catPop,
+	},
+	&cli.IntFlag{
+		Name:        flagQueueSize,
+		Usage:       "Maximum number of queued messages.",
+		EnvVars:     []string{strcase.ToSNAKE(prefixEnv(flagQueueSize))},
+		DefaultText: "unbounded",
+		Category:    catQueue,
+	},
+	&cli.StringFlag{
+		Name:     flagQueuePolicy,
+		Usage:    "Policy when the message queue is full, either `block`, `drop-oldest`, `drop-newest` or `coalesce`.",
+		Value:    "block",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagQueuePolicy))},
+		Category: catQueue,
+	},
+	&cli.IntFlag{
+		Name:        flagInboxRingSize,
+		Usage:       "Size of a lock-free ring to queue the messages of each component in, instead of a bounded and prioritized inbox.",
+		EnvVars:     []string{strcase.ToSNAKE(prefixEnv(flagInboxRingSize))},
+		DefaultText: "disabled",
+		Category:    catQueue,
+	},
+	&cli.DurationFlag{
+		Name:     flagConsumerTimeout,
+		Usage:    "Duration after which a consumer busy with a single message is reported as stuck.",
+		Value:    10 * time.Second,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagConsumerTimeout))},
+		Category: catQueue,
+	},
+	&cli.DurationFlag{
+		Name:     flagStopTimeout,
+		Usage:    "Timeout to finish consuming messages and to stop all components, once the game server exits.",
+		Value:    5 * time.Second,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagStopTimeout))},
+		Category: catQueue,
+	},
+	&cli.BoolFlag{
+		Name:     flagPrintTopology,
+		Usage:    "Flag whether to print the wiring of message producers and consumers, and exit.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagPrintTopology))},
+		Category: catQueue,
+	},
+	&cli.StringSliceFlag{
+		Name:     flagChaosDrop,
+		Usage:    "Drop messages with a probability, e.g. `agonesUpdate@*AgonesWatcher=0.5` to lose half of the watched state updates.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagChaosDrop))},
+		Category: catChaos,
+	},
+	&cli.StringSliceFlag{
+		Name:     flagChaosDelay,
+		Usage:    "Delay messages, e.g. `agonesReportHealth=5s` to delay the health reports.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagChaosDelay))},
+		Category: catChaos,
+	},
+	&cli.StringSliceFlag{
+		Name:     flagChaosDuplicate,
+		Usage:    "Deliver messages additional times, e.g. `agonesConnection=2`.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagChaosDuplicate))},
+		Category: catChaos,
+	},
+	&cli.Float64Flag{
+		Name:     flagTimeScale,
+		Usage:    "Factor by which to accelerate all timers and durations, e.g. `60` runs a 30 minute lifecycle within 30 seconds.",
+		Value:    1,
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagTimeScale))},
+		Category: catTime,
+	},
+	&cli.StringSliceFlag{
+		Name: flagProbeExpect,
+		Usage: "Expect the orchestrator to act, e.g. `Allocated within 5m of Ready`, `SIGTERM within 30s of Shutdown` or " +
+			"`label team by Allocated`. Exits with code 10 (state), 11 (signal) or 12 (label) when an expectation fails.",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagProbeExpect))},
+		Category: catProbe,
+	},
+	&cli.StringFlag{
+		Name:     flagProbeReport,
+		Usage:    "Write the probe report as JSON to this `path` on exit, `-` for stdout.",
+		Value:    "-",
+		EnvVars:  []string{strcase.ToSNAKE(prefixEnv(flagProbeReport))},
+		Category: catProbe,
+	},
 }.Merge(cmd.MonitoringFlags)
 
 func main() {
@@ -113,11 +345,14 @@ func main() {
 	app.Version = version
 	app.Flags = flags
 	app.Action = run
+	app.Commands = []*cli.Command{testCommand, planCommand, conformanceCommand}
 
 	if err := app.RunContext(context.Background(), os.Args); err != nil {
 		var exitErr *exiterror.ExitError
 		if errors.As(err, &exitErr) {
 			exitErr.RunHooks()
+		} else {
+			_, _ = fmt.Fprintln(os.Stderr, "Error:", err)
 		}
 		os.Exit(1)
 	}
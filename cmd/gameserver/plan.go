@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/scenario"
+	"github.com/urfave/cli/v2"
+)
+
+const flagScenario = "scenario"
+
+var planCommand = &cli.Command{
+	Name:  "plan",
+	Usage: "Print the timeline the game server follows with the given flags, without connecting to anything.",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagScenario,
+			Usage: "Plan with the flags of the scenario at `path`, listing its steps as outside events.",
+		},
+	},
+	Action: plan,
+}
+
+func plan(c *cli.Context) error {
+	path := c.String(flagScenario)
+	if path == "" {
+		return printPlan(c, c.App.Writer, time.Now(), nil)
+	}
+
+	s, err := scenario.Load(path)
+	if err != nil {
+		return err
+	}
+	w := c.App.Writer
+	return withArgs(c, s.Args, func(sc *cli.Context) error {
+		return printPlan(sc, w, s.Start, s.Steps)
+	})
+}
+
+func printPlan(c *cli.Context, w io.Writer, now time.Time, steps []scenario.Step) error {
+	cfg, err := config(c)
+	if err != nil {
+		return err
+	}
+	if !c.Bool(flagAgonesDisabled) {
+		cfg.Agones = agones.NewClient(nil) // Not connected, it only enables the Agones steps.
+	}
+
+	planned, err := fakegameserver.Plan(cfg, now)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "Timed steps, after the start (Agones steps after the Agones connection):")
+	for _, step := range planned {
+		if step.On == "" {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s%s\n", step.At, step.Description, planExit(c, step))
+		}
+	}
+	_, _ = fmt.Fprintln(tw, "\nSteps on outside events:")
+	for _, step := range planned {
+		if step.On != "" {
+			_, _ = fmt.Fprintf(tw, "  on %s\t%s%s\n", step.On, step.Description, planExit(c, step))
+		}
+	}
+	if len(steps) > 0 {
+		_, _ = fmt.Fprintln(tw, "\nOutside events of the scenario:")
+		for _, step := range steps {
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\n", time.Duration(step.At), step)
+		}
+	}
+	return tw.Flush()
+}
+
+// planExit returns how the game server exits at the step, with the exit code or signal given by flag first.
+func planExit(c *cli.Context, step fakegameserver.PlanStep) string {
+	if !step.Exit {
+		return ""
+	}
+	return " → " + exitError(c, step.ExitErr).Error()
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/antiphp/fakegameserver"
 	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
 	"github.com/antiphp/fakegameserver/internal/exiterror"
 	"github.com/hamba/cmd/v2/observe"
 	lctx "github.com/hamba/logger/v2/ctx"
@@ -15,8 +20,12 @@ import (
 )
 
 func run(c *cli.Context) error {
-	ctx, cancel := signal.NotifyContext(c.Context, syscall.SIGINT, syscall.SIGTERM)
-	defer cancel()
+	ctx := c.Context
+	if !c.IsSet(flagProbeExpect) { // The probe receives the signals as messages, to check them before it exits.
+		var cancel context.CancelFunc
+		ctx, cancel = signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+	}
 
 	obsvr, err := observe.NewFromCLI(c, "fakegameserver", &observe.Options{
 		LogTimeFormat: "2006-01-02T15:04:05.999Z07:00",
@@ -28,49 +37,69 @@ func run(c *cli.Context) error {
 
 	obsvr.Log.Info("Game server started")
 
-	gs := fakegameserver.New(obsvr.Log)
-	healthStatus := fakegameserver.NewHealthStatus()
-	healthStatus.Exclude(fakegameserver.MessageTypeInfo, fakegameserver.MessageTypeExit)
+	clk := clock.Real()
+	switch scale := c.Float64(flagTimeScale); {
+	case scale <= 0:
+		return fmt.Errorf("invalid time scale %v, expected a positive factor", scale)
+	case scale != 1:
+		clk = clock.NewScaled(clk, scale)
+	}
+
+	opts, err := gameServerOptions(c)
+	if err != nil {
+		return err
+	}
+	gs := fakegameserver.New(obsvr.Log, append(opts, fakegameserver.WithClock(clk))...)
 
-	if c.Duration(flagExitAfter) > 0 {
-		gs.AddHandler(fakegameserver.NewExitTimer(c.Duration(flagExitAfter)))
+	cfg, err := config(c)
+	if err != nil {
+		return err
 	}
 	if !c.Bool(flagAgonesDisabled) {
-		sdkClient, err := agones.NewSDKClient(c.String(flagAgonesAddr))
+		client, err := newAgonesClient(c, clk)
 		if err != nil {
-			return fmt.Errorf("creating Agones sdk client: %w", err)
+			return err
 		}
-
-		client := agones.NewClient(sdkClient)
 		go client.Run(ctx)
 
-		gs.AddHandler(fakegameserver.NewAgonesWatcher(client))
-		healthStatus.WaitFor(fakegameserver.MessageTypeAgonesConnection)
-
-		gs.AddHandler(fakegameserver.NewAgonesHealthReporter(client, c.Duration(flagHealthReportDelay), c.Duration(flagHealthReportInterval)))
-		healthStatus.Exclude(fakegameserver.MessageTypeAgonesReportHealth)
-
-		gs.AddHandler(fakegameserver.NewAgonesStateUpdater(client))
+		cfg.Agones = client
+	}
+	if len(cfg.Probe) > 0 {
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigCh)
+		cfg.Signals = sigCh
 
-		stateTimer := fakegameserver.NewAgonesStateTimer()
-		if c.IsSet(flagReadyAfter) {
-			stateTimer.AddState(agones.StateReady, c.Duration(flagReadyAfter))
-		}
-		if c.IsSet(flagAllocatedAfter) {
-			stateTimer.AddState(agones.StateAllocated, c.Duration(flagAllocatedAfter))
-		}
-		if c.IsSet(flagShutdownAfter) {
-			stateTimer.AddState(agones.StateShutdown, c.Duration(flagShutdownAfter))
+		report, err := probeReport(c.String(flagProbeReport))
+		if err != nil {
+			return err
 		}
-		gs.AddHandler(stateTimer)
+		defer func() { _ = report.Close() }()
+		cfg.ProbeReport = report
+	}
+	if err = fakegameserver.Wire(gs, cfg); err != nil {
+		return err
+	}
 
-		gs.AddHandler(fakegameserver.NewAgonesShutdown(func() bool {
-			return (client.IsLocal() && !c.IsSet(flagExitOnShutdown)) || c.Bool(flagExitOnShutdown)
-		}))
+	if c.Bool(flagPrintTopology) {
+		_, _ = fmt.Fprint(c.App.Writer, gs.Topology().String())
+		return nil
 	}
 
-	gs.AddHandler(healthStatus)
+	reason, err := gs.Run(ctx)
+	exitErr := exitError(c, err)
+	if err != nil {
+		obsvr.Log.Info("Game server stopped with error", lctx.Str("exit", exitErr.Error()))
+		return exitErr
+	}
 
+	obsvr.Log.Info("Game server stopped", lctx.Str("reason", reason), lctx.Str("exit", exitErr.Error()))
+	return exitErr
+}
+
+// exitError returns how to exit the program once the game server stopped, with the exit code or signal given by flag
+// first.
+func exitError(c *cli.Context, err error) *exiterror.ExitError {
 	var code, sig *int
 	if c.IsSet(flagExitCode) {
 		code = ptr.To[int](c.Int(flagExitCode))
@@ -80,15 +109,162 @@ func run(c *cli.Context) error {
 	}
 	exitErr := exiterror.New(code, sig)
 
-	reason, err := gs.Run(ctx)
 	if err != nil {
-		wrapErr := exiterror.Wrap(exitErr, err, exiterror.New(ptr.To(1), nil))
-		obsvr.Log.Info("Game server stopped with error", lctx.Str("exit", wrapErr.Error()))
+		return exiterror.Wrap(exitErr, err, exiterror.New(ptr.To(1), nil))
+	}
+	return exiterror.Wrap(exitErr, exiterror.New(ptr.To(0), nil))
+}
+
+func gameServerOptions(c *cli.Context) ([]fakegameserver.Option, error) {
+	policy, err := fakegameserver.ParseQueuePolicy(c.String(flagQueuePolicy))
+	if err != nil {
+		return nil, fmt.Errorf("parsing queue policy: %w", err)
+	}
+
+	var interceptors []fakegameserver.Interceptor
+	for _, chaos := range []struct{ kind, flag string }{
+		{kind: "drop", flag: flagChaosDrop},
+		{kind: "delay", flag: flagChaosDelay},
+		{kind: "duplicate", flag: flagChaosDuplicate},
+	} {
+		for _, spec := range c.StringSlice(chaos.flag) {
+			i, err := fakegameserver.ParseInterceptor(chaos.kind, spec)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s: %w", chaos.flag, err)
+			}
+			interceptors = append(interceptors, i)
+		}
+	}
+
+	return []fakegameserver.Option{
+		fakegameserver.WithQueue(c.Int(flagQueueSize), policy),
+		fakegameserver.WithRingInboxes(c.Int(flagInboxRingSize)),
+		fakegameserver.WithConsumerTimeout(c.Duration(flagConsumerTimeout)),
+		fakegameserver.WithStopTimeout(c.Duration(flagStopTimeout)),
+		fakegameserver.WithInterceptor(interceptors...),
+	}, nil
+}
+
+// config returns the configuration of the handlers, without the Agones client.
+func config(c *cli.Context) (fakegameserver.Config, error) {
+	cfg := fakegameserver.Config{
+		ExitAfter:    c.Duration(flagExitAfter),
+		ExitSchedule: c.String(flagExitSchedule),
+	}
+	for _, spec := range c.StringSlice(flagProbeExpect) {
+		exp, err := fakegameserver.ParseProbeExpectation(spec)
+		if err != nil {
+			return fakegameserver.Config{}, err
+		}
+		cfg.Probe = append(cfg.Probe, exp)
+	}
+	if c.Bool(flagAgonesDisabled) {
+		return cfg, nil
+	}
 
-		return wrapErr
+	err := agonesConfig(c, &cfg)
+	return cfg, err
+}
+
+// probeReport returns where to write the probe report to, either a file or `-` for stdout.
+func probeReport(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{Writer: os.Stdout}, nil
+	}
+
+	f, err := os.Create(path) //nolint:gosec // Writing to the given path is intended.
+	if err != nil {
+		return nil, fmt.Errorf("creating probe report: %w", err)
+	}
+	return f, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+func newAgonesClient(c *cli.Context, clk clock.Clock) (*agones.Client, error) {
+	sdkClient, err := agones.NewSDKClient(c.String(flagAgonesAddr))
+	if err != nil {
+		return nil, fmt.Errorf("creating Agones sdk client: %w", err)
+	}
+
+	betaClient, err := agones.NewBetaSDKClient(c.String(flagAgonesAddr))
+	if err != nil {
+		return nil, fmt.Errorf("creating Agones beta sdk client: %w", err)
+	}
+
+	alphaClient, err := agones.NewAlphaSDKClient(c.String(flagAgonesAddr))
+	if err != nil {
+		return nil, fmt.Errorf("creating Agones alpha sdk client: %w", err)
+	}
+
+	clientOpts := []agones.Option{
+		agones.WithPollInterval(c.Duration(flagAgonesPollInterval)),
+		agones.WithAlphaSDK(alphaClient),
+		agones.WithBetaSDK(betaClient),
+		agones.WithClock(clk),
+	}
+	if c.Bool(flagAgonesWatchDisabled) {
+		clientOpts = append(clientOpts, agones.WithoutWatch())
+	}
+	return agones.NewClient(sdkClient, clientOpts...), nil
+}
+
+func agonesConfig(c *cli.Context, cfg *fakegameserver.Config) error {
+	cfg.HealthReportDelay = c.Duration(flagHealthReportDelay)
+	cfg.HealthReportInterval = c.Duration(flagHealthReportInterval)
+	cfg.UpdateAttempts = c.Int(flagUpdateAttempts)
+	cfg.UpdateDeadline = c.Duration(flagUpdateDeadline)
+	cfg.ShutdownOnExit = c.Bool(flagShutdownOnExit)
+
+	var err error
+	if cfg.UpdateGiveUp, err = fakegameserver.ParseAction(c.String(flagUpdateGiveUp)); err != nil {
+		return fmt.Errorf("parsing update give-up action: %w", err)
+	}
+	if cfg.DisconnectTimeout = c.Duration(flagDisconnectTimeout); cfg.DisconnectTimeout > 0 {
+		if cfg.DisconnectAction, err = fakegameserver.ParseAction(c.String(flagDisconnectAction)); err != nil {
+			return fmt.Errorf("parsing disconnect action: %w", err)
+		}
+	}
+
+	for _, s := range []struct {
+		flag  string
+		state agones.State
+	}{
+		{flag: flagReadyAfter, state: agones.StateReady},
+		{flag: flagAllocatedAfter, state: agones.StateAllocated},
+		{flag: flagShutdownAfter, state: agones.StateShutdown},
+	} {
+		if c.IsSet(s.flag) {
+			cfg.States = append(cfg.States, fakegameserver.StateAfter{State: s.state, After: c.Duration(s.flag)})
+		}
+	}
+	if c.IsSet(flagExitOnShutdown) {
+		cfg.ExitOnShutdown = ptr.To(c.Bool(flagExitOnShutdown))
+	}
+
+	if c.IsSet(flagPopulationCurve) {
+		if cfg.Population, err = fakegameserver.ParseCurve(c.String(flagPopulationCurve)); err != nil {
+			return fmt.Errorf("parsing population curve: %w", err)
+		}
+
+		switch target := c.String(flagPopulationTarget); {
+		case target == "players":
+		case strings.HasPrefix(target, "counter:"):
+			cfg.PopulationCounter = strings.TrimPrefix(target, "counter:")
+		default:
+			return fmt.Errorf("invalid population target %q, expected players or counter:<name>", target)
+		}
+		cfg.PopulationTimeScale = c.Float64(flagPopulationTimeScale)
+		cfg.PopulationInterval = c.Duration(flagPopulationInterval)
 	}
 
-	wrapErr := exiterror.Wrap(exitErr, exiterror.New(ptr.To(0), nil))
-	obsvr.Log.Info("Game server stopped", lctx.Str("reason", reason), lctx.Str("exit", wrapErr.Error()))
-	return wrapErr
+	cfg.RoomsCounter = c.String(flagRoomsCounter)
+	cfg.RoomDurationMin = c.Duration(flagRoomDurationMin)
+	cfg.RoomDurationMax = c.Duration(flagRoomDurationMax)
+	cfg.RoomsIdleTimeout = c.Duration(flagRoomsIdleTimeout)
+	return nil
 }
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/fakegameservertest"
+	"github.com/antiphp/fakegameserver/internal/junit"
+	"github.com/antiphp/fakegameserver/internal/scenario"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	flagJUnit         = "junit"
+	flagPrintTimeline = "print-timeline"
+)
+
+var testCommand = &cli.Command{
+	Name:      "test",
+	Usage:     "Run scenarios against an in-memory SDK server under virtual time and check their expectations.",
+	ArgsUsage: "<scenario.json>...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  flagJUnit,
+			Usage: "Write the results as JUnit XML to the `path`.",
+		},
+		&cli.BoolFlag{
+			Name:  flagPrintTimeline,
+			Usage: "Print the full timeline of each scenario.",
+		},
+	},
+	Action: test,
+}
+
+func test(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return errors.New("expected at least one scenario file")
+	}
+
+	var (
+		suites []junit.Suite
+		failed int
+	)
+	for _, path := range c.Args().Slice() {
+		s, err := scenario.Load(path)
+		if err != nil {
+			return err
+		}
+
+		started := time.Now()
+		run, err := runScenario(c, s)
+		if err != nil {
+			return fmt.Errorf("running scenario %s: %w", s.Name, err)
+		}
+		results := s.Evaluate(run)
+		diff := s.Diff(run)
+
+		suite := report(c.App.Writer, s, run, results, diff, c.Bool(flagPrintTimeline))
+		suite.Time = junit.Seconds(time.Since(started))
+		suites = append(suites, suite)
+		if suite.Failures > 0 {
+			failed++
+		}
+	}
+
+	if path := c.String(flagJUnit); path != "" {
+		if err := writeJUnit(path, suites); err != nil {
+			return err
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scenarios failed", failed, len(suites))
+	}
+	return nil
+}
+
+// runScenario runs the scenario with its flags, on an in-memory SDK and a virtual clock.
+func runScenario(c *cli.Context, s *scenario.Scenario) (scenario.Run, error) {
+	var run scenario.Run
+
+	err := withArgs(c, s.Args, func(sc *cli.Context) error {
+		opts, err := gameServerOptions(sc)
+		if err != nil {
+			return err
+		}
+		cfg, err := config(sc)
+		if err != nil {
+			return err
+		}
+
+		clk := clock.NewVirtual(s.Start)
+		b := fakegameservertest.NewBuilder().
+			Clock(clk).
+			Options(opts...).
+			Config(func(c *fakegameserver.Config) { *c = cfg }).
+			ClientOptions(agones.WithPollInterval(sc.Duration(flagAgonesPollInterval)))
+		if sc.Bool(flagAgonesWatchDisabled) {
+			b.ClientOptions(agones.WithoutWatch())
+		}
+		if sc.Bool(flagAgonesDisabled) {
+			b.WithoutAgones()
+		}
+		h, err := b.Build()
+		if err != nil {
+			return err
+		}
+		s.Schedule(clk, h.SDK)
+
+		_, err = h.RunVirtual(sc.Context, clk, time.Duration(s.Limit))
+
+		run = scenario.Run{
+			Start:    s.Start,
+			Messages: h.Recorder.Messages(),
+			Exited:   !errors.Is(err, fakegameservertest.ErrTimeLimit),
+			Elapsed:  clk.Since(s.Start),
+		}
+		if run.Exited {
+			exitErr := exitError(sc, err)
+			run.Code = exitErr.Code()
+			run.Signal, _ = exitErr.Signal()
+		}
+		return nil
+	})
+	return run, err
+}
+
+// withArgs runs the action with the flags of the fakegs parsed from the arguments, e.g. of a scenario.
+func withArgs(c *cli.Context, args []string, action cli.ActionFunc) error {
+	app := cli.NewApp()
+	app.Flags = flags
+	app.HideHelp = true
+	app.HideVersion = true
+	app.Writer, app.ErrWriter = io.Discard, io.Discard
+	app.Action = func(ac *cli.Context) error {
+		if ac.NArg() > 0 {
+			return fmt.Errorf("unexpected arguments %v", ac.Args().Slice())
+		}
+		return action(ac)
+	}
+
+	if err := app.RunContext(c.Context, append([]string{c.App.Name}, args...)); err != nil {
+		return fmt.Errorf("invalid args: %w", err)
+	}
+	return nil
+}
+
+// report prints the results of the scenario, and returns them as test suite.
+func report(w io.Writer, s *scenario.Scenario, run scenario.Run, results []scenario.Result, diff []string, timeline bool) junit.Suite {
+	cases := make([]junit.Case, 0, len(results)+1)
+
+	_, _ = fmt.Fprintf(w, "=== %s (%s virtual)\n", s.Name, run.Elapsed)
+	for _, res := range results {
+		c := junit.Case{Name: res.Name, Classname: s.Name, Time: junit.Seconds(0)}
+		if res.Failure != "" {
+			_, _ = fmt.Fprintf(w, "FAIL %s: %s\n", res.Name, res.Failure)
+			c.Failure = &junit.Failure{Message: res.Failure}
+		} else {
+			_, _ = fmt.Fprintf(w, "PASS %s\n", res.Name)
+		}
+		cases = append(cases, c)
+	}
+
+	if len(s.Timeline) > 0 {
+		c := junit.Case{Name: "matches timeline", Classname: s.Name, Time: junit.Seconds(0)}
+		if diff != nil {
+			text := strings.Join(diff, "\n")
+			_, _ = fmt.Fprintf(w, "FAIL matches timeline:\n%s\n", text)
+			c.Failure = &junit.Failure{Message: "timeline differs", Text: text}
+		} else {
+			_, _ = fmt.Fprintln(w, "PASS matches timeline")
+		}
+		cases = append(cases, c)
+	}
+
+	lines := run.Timeline(time.Duration(s.Resolution))
+	if timeline {
+		_, _ = fmt.Fprintf(w, "--- timeline\n%s\n", strings.Join(lines, "\n"))
+	}
+
+	suite := junit.NewSuite(s.Name, 0, cases)
+	suite.SystemOut = strings.Join(lines, "\n")
+	return suite
+}
+
+func writeJUnit(path string, suites []junit.Suite) error {
+	f, err := os.Create(path) //nolint:gosec // Writing to the given path is intended.
+	if err != nil {
+		return fmt.Errorf("creating JUnit file: %w", err)
+	}
+
+	if err = junit.Write(f, suites); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing JUnit file: %w", err)
+	}
+	return f.Close()
+}
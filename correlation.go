@@ -0,0 +1,138 @@
+package fakegameserver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/google/uuid"
+)
+
+var _ Subscriber = (*Correlator)(nil)
+
+// Correlator awaits the replies to messages.
+//
+// A reply is a message caused by another message, see Message.Reply. The correlator must consume the replies, e.g. by
+// adding it as consumer to the game server. On a virtual clock, a waiter releases the clock while waiting, and a reply
+// holds it until the waiter is done with it and waits again, see clock.Hold.
+type Correlator struct {
+	mu      sync.Mutex
+	waiters map[string][]*waiter
+}
+
+type waiter struct {
+	types []MessageType
+	ch    chan Message
+	clock clock.Clock
+}
+
+// NewCorrelator returns a new correlator.
+func NewCorrelator() *Correlator {
+	return &Correlator{
+		waiters: map[string][]*waiter{},
+	}
+}
+
+// Request adds the message to the queue and awaits its first reply of the given types, or any reply if no types are
+// given. A timeout of zero waits until the context is done, the timeout is on the clock of the context.
+func (c *Correlator) Request(ctx context.Context, q Queue, msg Message, timeout time.Duration, types ...MessageType) (Message, error) {
+	if msg.ID == "" {
+		msg.ID = uuid.NewString()
+	}
+
+	w, cancel := c.watch(ctx, msg.ID, types)
+	defer cancel()
+
+	q.Add(msg)
+
+	return c.wait(ctx, msg.ID, w, timeout)
+}
+
+// Await awaits the first reply of the given types to the message with the given ID, or any reply if no types are
+// given. A timeout of zero waits until the context is done, the timeout is on the clock of the context.
+//
+// Only replies consumed after Await is called are considered.
+func (c *Correlator) Await(ctx context.Context, id string, timeout time.Duration, types ...MessageType) (Message, error) {
+	w, cancel := c.watch(ctx, id, types)
+	defer cancel()
+
+	return c.wait(ctx, id, w, timeout)
+}
+
+func (c *Correlator) watch(ctx context.Context, id string, types []MessageType) (*waiter, func()) {
+	w := &waiter{types: types, ch: make(chan Message, 1), clock: clock.FromContext(ctx)}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waiters[id] = append(c.waiters[id], w)
+	return w, func() { c.remove(id, w) }
+}
+
+func (c *Correlator) remove(id string, w *waiter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.waiters[id] = slices.DeleteFunc(c.waiters[id], func(other *waiter) bool { return other == w })
+	if len(c.waiters[id]) == 0 {
+		delete(c.waiters, id)
+	}
+
+	select {
+	case <-w.ch: // Replied, but no longer awaited.
+		clock.Release(w.clock)
+	default:
+	}
+}
+
+func (c *Correlator) wait(ctx context.Context, id string, w *waiter, timeout time.Duration) (Message, error) {
+	clk := clock.FromContext(ctx)
+
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		t := clk.NewTimer(timeout)
+		defer t.Stop()
+
+		timeoutCh = t.C()
+	}
+
+	clock.Release(clk)
+	select {
+	case <-ctx.Done():
+		return Message{}, fmt.Errorf("awaiting reply to message %s: %w", id, ctx.Err())
+	case <-timeoutCh:
+		return Message{}, fmt.Errorf("awaiting reply to message %s: %w", id, context.DeadlineExceeded)
+	case reply := <-w.ch:
+		return reply, nil
+	}
+}
+
+// Subscriptions returns the message types the correlator consumes.
+func (c *Correlator) Subscriptions() []MessageType {
+	return []MessageType{"*"}
+}
+
+// Consume consumes replies.
+func (c *Correlator) Consume(msg Message) {
+	if msg.CausationID == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, w := range c.waiters[msg.CausationID] {
+		if len(w.types) > 0 && !slices.Contains(w.types, msg.Type) {
+			continue
+		}
+		clock.Hold(w.clock) // Until the waiter is done with the reply.
+		select {
+		case w.ch <- msg:
+		default: // Already replied.
+			clock.Release(w.clock)
+		}
+	}
+}
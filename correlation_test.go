@@ -0,0 +1,63 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCorrelator_Request(t *testing.T) {
+	c := fakegameserver.NewCorrelator()
+	q := queueFunc(func(msg fakegameserver.Message) {
+		go func() {
+			c.Consume(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, CausationID: "other"})
+			c.Consume(msg.Reply(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "attempt"}))
+			c.Consume(msg.Reply(fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "done"}))
+		}()
+	})
+
+	reply, err := c.Request(t.Context(), q, fakegameserver.Message{ID: "req"}, 5*time.Second, fakegameserver.MessageTypeExit)
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reply.Description)
+	assert.Equal(t, "req", reply.CausationID)
+	assert.Equal(t, "req", reply.CorrelationID)
+}
+
+func TestCorrelator_RequestTimesOut(t *testing.T) {
+	clk := clock.NewVirtual(time.Now())
+	ctx := clock.NewContext(t.Context(), clk)
+
+	c := fakegameserver.NewCorrelator()
+	q := queueFunc(func(fakegameserver.Message) {})
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := c.Request(ctx, q, fakegameserver.Message{}, time.Minute)
+		errCh <- err
+	}()
+	require.NoError(t, clk.WaitTimers(ctx, 1))
+	clk.Advance(time.Minute) // The timeout is on the clock of the context.
+
+	assert.ErrorIs(t, <-errCh, context.DeadlineExceeded)
+}
+
+func TestMessage_Reply(t *testing.T) {
+	req := fakegameserver.Message{ID: "2", CorrelationID: "1"}
+
+	reply := req.Reply(fakegameserver.Message{ID: "3"})
+
+	assert.Equal(t, "1", reply.CorrelationID)
+	assert.Equal(t, "2", reply.CausationID)
+}
+
+type queueFunc func(fakegameserver.Message)
+
+func (fn queueFunc) Add(msg fakegameserver.Message) {
+	fn(msg)
+}
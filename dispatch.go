@@ -0,0 +1,165 @@
+package fakegameserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/queue"
+)
+
+const (
+	// MessageTypeError is the message type for internal errors, e.g. a panicking consumer.
+	MessageTypeError MessageType = "error"
+
+	// MessageTypeConsumerStuck is the message type for consumers that are stuck consuming a message.
+	MessageTypeConsumerStuck MessageType = "consumerStuck"
+)
+
+// EventConsumerStuck is the event type for stuck consumers, with the name of the consumer as payload.
+var EventConsumerStuck = NewEventType[string](MessageTypeConsumerStuck)
+
+// inboxQueue is the queue of an inbox, either a queue.Fifo or a queue.Ring.
+type inboxQueue interface {
+	AddContext(ctx context.Context, msg Message) error
+	GetN(buf []Message) (int, bool)
+	Close()
+	Dropped() int64
+	Len() int
+}
+
+// inbox dispatches messages to a consumer in its own goroutine, keeping the order of messages.
+type inbox struct {
+	name     string
+	consumer Consumer
+	subs     []MessageType
+	queue    inboxQueue
+	batch    int
+	clock    clock.Clock
+
+	busySince atomic.Int64 // Unix nanoseconds, zero when idle.
+	stuck     bool         // Owned by the watchdog.
+}
+
+// ringBatch is the number of messages an inbox on a ring retrieves at once.
+const ringBatch = 64
+
+// newInbox creates an inbox, queueing messages as configured for the message queue.
+//
+// With a ring size, the messages are queued in a ring instead, and retrieved in batches. Messages retrieved in a batch
+// are consumed in order, even if a message of a higher priority is added meanwhile.
+func newInbox(c Consumer, clk clock.Clock, cfg queue.Config[Message], ringSize int) *inbox {
+	i := &inbox{
+		name:     handlerName(c),
+		consumer: c,
+		subs:     subscriptions(c),
+		queue:    queue.New(cfg),
+		batch:    1,
+		clock:    clk,
+	}
+	if ringSize > 0 {
+		i.queue = queue.NewRing(ringSize, cfg.Policy, cfg.OnDrop)
+		i.batch = ringBatch
+	}
+	return i
+}
+
+// run consumes the messages of the inbox until it is shut down.
+func (i *inbox) run(errs Queue) {
+	buf := make([]Message, i.batch)
+	for {
+		n, shutdown := i.queue.GetN(buf)
+		for _, msg := range buf[:n] {
+			i.consume(errs, msg)
+		}
+		clear(buf[:n])
+		if shutdown {
+			return
+		}
+	}
+}
+
+func (i *inbox) consume(errs Queue, msg Message) {
+	i.busySince.Store(i.clock.Now().UnixNano())
+	defer clock.Release(i.clock) // The message is consumed, see deliver.
+	defer i.busySince.Store(0)
+
+	defer func() {
+		if r := recover(); r != nil {
+			errs.Add(msg.Reply(Message{
+				Type:        MessageTypeError,
+				Description: "Consumer " + i.name + " panicked consuming " + string(msg.Type),
+				Error:       fmt.Errorf("panic: %v", r),
+			}))
+		}
+	}()
+
+	i.consumer.Consume(msg)
+}
+
+// minWatchdogInterval is the minimum interval the watchdog checks the consumers at.
+const minWatchdogInterval = time.Millisecond
+
+// watchdog flags consumers that are busy with a single message for longer than the timeout.
+func watchdog(ctx context.Context, queue Queue, inboxesFn func() []*inbox, timeout time.Duration) {
+	clk := clock.FromContext(ctx)
+	t := clk.NewTicker(max(timeout/2, minWatchdogInterval))
+	defer t.Stop()
+
+	for {
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+
+		inboxes := inboxesFn()
+
+		var stuck, recovered []*inbox
+		for _, i := range inboxes {
+			since := i.busySince.Load()
+			busy := since != 0 && clk.Since(time.Unix(0, since)) > timeout
+
+			switch {
+			case busy && !i.stuck:
+				stuck = append(stuck, i)
+			case !busy && i.stuck:
+				recovered = append(recovered, i)
+			}
+			i.stuck = busy
+		}
+
+		for _, i := range stuck {
+			queue.Add(EventConsumerStuck.Message("Consumer "+i.name+" stuck for more than "+timeout.String(), stuckErr(inboxes), i.name))
+		}
+		for _, i := range recovered {
+			// Health is tracked per message type, so a recovery only reports healthy once no consumer is stuck.
+			queue.Add(EventConsumerStuck.Message("Consumer "+i.name+" recovered", stuckErr(inboxes), i.name))
+		}
+	}
+}
+
+// stuckErr returns an error for all stuck consumers, or nil if none is stuck.
+func stuckErr(inboxes []*inbox) error {
+	var errs []error
+	for _, i := range inboxes {
+		if i.stuck {
+			errs = append(errs, errors.New("consumer "+i.name+" stuck"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// handlerName returns the name of a message handler.
+func handlerName(h any) string {
+	typ := reflect.TypeOf(h)
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	return typ.String()
+}
@@ -0,0 +1,209 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameServer_RunRecoversPanics(t *testing.T) {
+	recCh := make(chan fakegameserver.Message, 10)
+
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Type == fakegameserver.MessageTypeInfo {
+			panic("test")
+		}
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		recCh <- msg
+	}))
+	go func() { _, _ = gs.Run(t.Context()) }()
+
+	msg := waitFor(t, recCh, fakegameserver.MessageTypeError)
+
+	assert.EqualError(t, msg.Error, "panic: test")
+	assert.Equal(t, "fakegameserver.ConsumerFunc", msg.Origin)
+}
+
+func TestGameServer_RunReportsStuckConsumers(t *testing.T) {
+	recCh := make(chan fakegameserver.Message, 10)
+	unblockCh := make(chan struct{})
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithConsumerTimeout(20*time.Millisecond),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Type == fakegameserver.MessageTypeInfo {
+			<-unblockCh
+		}
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		recCh <- msg
+	}))
+	go func() { _, _ = gs.Run(t.Context()) }()
+
+	msg := waitFor(t, recCh, fakegameserver.MessageTypeConsumerStuck)
+
+	assert.Error(t, msg.Error)
+
+	close(unblockCh)
+	msg = waitFor(t, recCh, fakegameserver.MessageTypeConsumerStuck)
+
+	assert.NoError(t, msg.Error)
+}
+
+func TestGameServer_RunHandlesTinyConsumerTimeout(t *testing.T) {
+	recCh := make(chan fakegameserver.Message, 10)
+	unblockCh := make(chan struct{})
+	t.Cleanup(func() { close(unblockCh) })
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithConsumerTimeout(time.Nanosecond),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Type == fakegameserver.MessageTypeInfo {
+			<-unblockCh
+		}
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		recCh <- msg
+	}))
+	go func() { _, _ = gs.Run(t.Context()) }()
+
+	msg := waitFor(t, recCh, fakegameserver.MessageTypeConsumerStuck)
+
+	assert.Error(t, msg.Error)
+}
+
+func TestGameServer_RunQueuesInboxesByPriority(t *testing.T) {
+	consumingCh := make(chan struct{})
+	unblockCh := make(chan struct{})
+	routineCh := make(chan struct{})
+	urgentCh := make(chan struct{})
+	recCh := make(chan fakegameserver.Message, 10)
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithPriority(func(msg fakegameserver.Message) fakegameserver.Priority {
+			if msg.Description == "urgent" {
+				return fakegameserver.PriorityControl
+			}
+			return fakegameserver.DefaultPriority(msg)
+		}),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "first"})
+		<-consumingCh
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "routine"})
+		<-routineCh
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "urgent"})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Type != fakegameserver.MessageTypeInfo {
+			return
+		}
+		if msg.Description == "first" {
+			close(consumingCh)
+			<-unblockCh
+		}
+		recCh <- msg
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		// Delivered to the inbox of the blocked consumer before.
+		switch msg.Description {
+		case "routine":
+			close(routineCh)
+		case "urgent":
+			close(urgentCh)
+		}
+	}))
+	go func() { _, _ = gs.Run(t.Context()) }()
+
+	select {
+	case <-urgentCh:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for the urgent message")
+	}
+	close(unblockCh)
+
+	var got []string
+	for range 3 {
+		got = append(got, waitFor(t, recCh, fakegameserver.MessageTypeInfo).Description)
+	}
+	assert.Equal(t, []string{"first", "urgent", "routine"}, got)
+}
+
+func TestGameServer_RunQueuesRingInboxes(t *testing.T) {
+	var got []string
+	consumedCh := make(chan struct{})
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithRingInboxes(8),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		for i := range 100 {
+			q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: strconv.Itoa(i)})
+		}
+		<-consumedCh // The exit message skips ahead.
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "done"})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Type == fakegameserver.MessageTypeInfo {
+			got = append(got, msg.Description)
+			if len(got) == 100 {
+				close(consumedCh)
+			}
+		}
+	}))
+
+	reason, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reason)
+	require.Len(t, got, 100)
+	for i, desc := range got {
+		assert.Equal(t, strconv.Itoa(i), desc)
+	}
+}
+
+type producerFunc func(context.Context, fakegameserver.Queue)
+
+func (fn producerFunc) Run(ctx context.Context, q fakegameserver.Queue) {
+	fn(ctx, q)
+}
+
+func waitFor(t *testing.T, ch <-chan fakegameserver.Message, typ fakegameserver.MessageType) fakegameserver.Message {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case <-timeout:
+			require.FailNow(t, "timeout waiting for message type "+string(typ))
+		case msg := <-ch:
+			if msg.Type == typ {
+				return msg
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package fakegameserver
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// registry maps message types to their payload types.
+var registry = struct {
+	mu    sync.RWMutex
+	types map[MessageType]reflect.Type
+}{
+	types: map[MessageType]reflect.Type{},
+}
+
+// EventType is a message type with a payload of type T.
+//
+// Messages of an event type are created and read through the event type, so wrong payloads fail at compile time.
+type EventType[T any] struct {
+	typ MessageType
+}
+
+// NewEventType returns a new event type and registers T as payload type of the message type.
+//
+// It panics if the message type is already registered with a different payload type.
+func NewEventType[T any](typ MessageType) EventType[T] {
+	payloadType := reflect.TypeFor[T]()
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	if existing, ok := registry.types[typ]; ok && existing != payloadType {
+		panic("Message type " + string(typ) + " already registered with payload type " + existing.String()) // Developer error.
+	}
+	registry.types[typ] = payloadType
+
+	return EventType[T]{typ: typ}
+}
+
+// Type returns the message type of the event type.
+func (e EventType[T]) Type() MessageType {
+	return e.typ
+}
+
+// Message returns a new message of the event type.
+func (e EventType[T]) Message(desc string, err error, payload T) Message {
+	return Message{
+		Type:        e.typ,
+		Description: desc,
+		Error:       err,
+		Payload:     payload,
+	}
+}
+
+// Payload returns the payload of the message, if the message is of the event type.
+func (e EventType[T]) Payload(msg Message) (T, bool) {
+	if msg.Type != e.typ {
+		var zero T
+		return zero, false
+	}
+	payload, ok := msg.Payload.(T)
+	return payload, ok
+}
+
+// Event returns the message as event, if the message is of the event type.
+func (e EventType[T]) Event(msg Message) (Event[T], bool) {
+	payload, ok := e.Payload(msg)
+	if !ok {
+		return Event[T]{}, false
+	}
+	return Event[T]{Message: msg, Payload: payload}, true
+}
+
+// Event is a message with a typed payload.
+type Event[T any] struct {
+	Message
+
+	// Payload is the typed payload, it shadows the untyped payload of the message.
+	Payload T
+}
+
+// ConsumerFunc is a function that consumes messages.
+type ConsumerFunc func(Message)
+
+// Consume consumes a message.
+func (fn ConsumerFunc) Consume(msg Message) {
+	fn(msg)
+}
+
+// Subscribe returns a consumer that subscribes to the event type and calls fn for each message of it.
+func Subscribe[T any](e EventType[T], fn func(Event[T])) Consumer {
+	h := Handlers{}
+	HandleEvent(h, e, fn)
+	return h
+}
+
+// PayloadType returns the registered payload type of a message type.
+func PayloadType(typ MessageType) (reflect.Type, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	payloadType, ok := registry.types[typ]
+	return payloadType, ok
+}
+
+// CheckPayload checks the payload of a message against the registered payload type of its message type.
+//
+// Messages of unregistered message types are not checked.
+func CheckPayload(msg Message) error {
+	payloadType, ok := PayloadType(msg.Type)
+	if !ok {
+		return nil
+	}
+
+	if msg.Payload == nil {
+		return fmt.Errorf("message type %s: missing payload of type %s", msg.Type, payloadType)
+	}
+	if got := reflect.TypeOf(msg.Payload); !got.AssignableTo(payloadType) {
+		return fmt.Errorf("message type %s: expected payload of type %s, got %s", msg.Type, payloadType, got)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package fakegameserver_test
+
+import (
+	"testing"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventType_Payload(t *testing.T) {
+	msg := fakegameserver.EventAgonesUpdate.Message("test", nil, agones.StateReady)
+
+	state, ok := fakegameserver.EventAgonesUpdate.Payload(msg)
+
+	require.True(t, ok)
+	assert.Equal(t, agones.StateReady, state)
+
+	_, ok = fakegameserver.EventAgonesRequestUpdate.Payload(msg)
+
+	assert.False(t, ok)
+}
+
+func TestSubscribe(t *testing.T) {
+	var got []bool
+	c := fakegameserver.Subscribe(fakegameserver.EventAgonesConnection, func(ev fakegameserver.Event[bool]) {
+		got = append(got, ev.Payload)
+	})
+
+	c.Consume(fakegameserver.EventAgonesConnection.Message("test", nil, true))
+	c.Consume(fakegameserver.EventAgonesSync.Message("test", nil, false))
+	c.Consume(fakegameserver.EventAgonesConnection.Message("test", nil, false))
+
+	assert.Equal(t, []bool{true, false}, got)
+}
+
+func TestCheckPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     fakegameserver.Message
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles valid payload",
+			msg:     fakegameserver.EventAgonesUpdate.Message("test", nil, agones.StateReady),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles unregistered message type",
+			msg:     fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Payload: 42},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles wrong payload",
+			msg:     fakegameserver.Message{Type: fakegameserver.MessageTypeAgonesUpdate, Payload: "Ready"},
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles missing payload",
+			msg:     fakegameserver.Message{Type: fakegameserver.MessageTypeHealthStatus},
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := fakegameserver.CheckPayload(test.msg)
+
+			test.wantErr(t, err)
+		})
+	}
+}
+
+func TestNewEventType_PanicsOnConflict(t *testing.T) {
+	assert.Panics(t, func() {
+		fakegameserver.NewEventType[string](fakegameserver.MessageTypeAgonesUpdate)
+	})
+}
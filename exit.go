@@ -2,7 +2,10 @@ package fakegameserver
 
 import (
 	"context"
+	"fmt"
 	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
 )
 
 // ExitTimer is a timer that sends an exit message to the queue when it elapses.
@@ -17,6 +20,11 @@ func NewExitTimer(dur time.Duration) *ExitTimer {
 	}
 }
 
+// Publications returns the message types the exit timer produces.
+func (e *ExitTimer) Publications() []MessageType {
+	return []MessageType{MessageTypeInfo, MessageTypeExit}
+}
+
 // Run starts the exit timer and sends a message to the queue when the timer elapses.
 func (e *ExitTimer) Run(ctx context.Context, q Queue) {
 	q.Add(Message{
@@ -24,13 +32,64 @@ func (e *ExitTimer) Run(ctx context.Context, q Queue) {
 		Description: "Exit timer started with " + e.dur.String(),
 	})
 
-	select {
-	case <-ctx.Done():
-		return
-	case <-time.After(e.dur):
+	h := scheduler(ctx, q).AddAfter(Message{
+		Type:        MessageTypeExit,
+		Description: "Exit timer elapsed after " + e.dur.String(),
+	}, e.dur)
+
+	clock.Release(clock.FromContext(ctx))
+	<-ctx.Done()
+	h.Cancel()
+}
+
+// ExitSchedule exits at the next time of a schedule, e.g. for a daily restart.
+type ExitSchedule struct {
+	spec  string
+	sched Schedule
+}
+
+// NewExitSchedule creates a new exit schedule.
+func NewExitSchedule(spec string) (*ExitSchedule, error) {
+	sched, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, fmt.Errorf("parsing exit schedule: %w", err)
+	}
+
+	return &ExitSchedule{
+		spec:  spec,
+		sched: sched,
+	}, nil
+}
+
+// Publications returns the message types the exit schedule produces.
+func (e *ExitSchedule) Publications() []MessageType {
+	return []MessageType{MessageTypeInfo, MessageTypeExit}
+}
+
+// Run schedules the exit and sends a message to the queue when it is due.
+func (e *ExitSchedule) Run(ctx context.Context, q Queue) {
+	clk := clock.FromContext(ctx)
+	next := e.sched.Next(clk.Now())
+	if next.IsZero() {
 		q.Add(Message{
-			Type:        MessageTypeExit,
-			Description: "Exit timer elapsed after " + e.dur.String(),
+			Type:        MessageTypeInfo,
+			Description: "Exit schedule " + e.spec + " has no next time, ignoring it",
 		})
+		clock.Release(clk)
+		return
 	}
+
+	q.Add(Message{
+		Type:        MessageTypeInfo,
+		Description: "Exit scheduled at " + next.Format(time.RFC3339) + " by " + e.spec,
+	})
+
+	h := scheduler(ctx, q).AddAt(Message{
+		Type:        MessageTypeExit,
+		Description: "Exit schedule " + e.spec + " elapsed",
+	}, next)
+
+	clock.Release(clk)
+	<-ctx.Done()
+	h.Cancel()
 }
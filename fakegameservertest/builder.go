@@ -0,0 +1,123 @@
+package fakegameservertest
+
+import (
+	"context"
+	"io"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/hamba/logger/v2"
+)
+
+// Builder builds a fake game server with the handlers of the fakegs, on an in-memory SDK.
+type Builder struct {
+	log        *logger.Logger
+	sdk        *SDK
+	cfg        fakegameserver.Config
+	opts       []fakegameserver.Option
+	clientOpts []agones.Option
+	noAgones   bool
+	clock      clock.Clock
+}
+
+// NewBuilder returns a new builder, with a new SDK and a logger that discards all logs.
+func NewBuilder() *Builder {
+	return &Builder{
+		log: logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		sdk: NewSDK(),
+	}
+}
+
+// Config changes the configuration of the handlers. The Agones client is set on build.
+func (b *Builder) Config(fn func(*fakegameserver.Config)) *Builder {
+	fn(&b.cfg)
+	return b
+}
+
+// Options adds options of the game server.
+func (b *Builder) Options(opts ...fakegameserver.Option) *Builder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// ClientOptions adds options of the Agones client.
+func (b *Builder) ClientOptions(opts ...agones.Option) *Builder {
+	b.clientOpts = append(b.clientOpts, opts...)
+	return b
+}
+
+// Clock sets the clock of the game server, the Agones client and the SDK, e.g. a virtual clock to advance the time
+// manually.
+func (b *Builder) Clock(c clock.Clock) *Builder {
+	b.opts = append(b.opts, fakegameserver.WithClock(c))
+	b.clientOpts = append(b.clientOpts, agones.WithClock(c))
+	b.clock = c
+	return b
+}
+
+// SDK sets the SDK to build on.
+func (b *Builder) SDK(s *SDK) *Builder {
+	b.sdk = s
+	return b
+}
+
+// WithoutAgones disables the Agones integration, as the flag `--agones-disabled` does.
+func (b *Builder) WithoutAgones() *Builder {
+	b.noAgones = true
+	return b
+}
+
+// Logger sets the logger of the game server.
+func (b *Builder) Logger(log *logger.Logger) *Builder {
+	b.log = log
+	return b
+}
+
+// Build builds the fake game server.
+func (b *Builder) Build() (*Harness, error) {
+	h := &Harness{
+		GameServer: fakegameserver.New(b.log, b.opts...),
+		SDK:        b.sdk,
+		Recorder:   NewRecorder(),
+		clock:      b.clock,
+	}
+	h.GameServer.AddHandler(h.Recorder)
+
+	if b.clock != nil {
+		b.sdk.SetClock(b.clock)
+	}
+
+	cfg := b.cfg
+	if !b.noAgones {
+		h.Client = b.sdk.NewClient(b.clientOpts...)
+		cfg.Agones = h.Client
+	}
+	if err := fakegameserver.Wire(h.GameServer, cfg); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Harness is a built fake game server.
+type Harness struct {
+	GameServer *fakegameserver.GameServer
+	SDK        *SDK
+	// Client is the Agones client of the game server. It is nil if the Agones integration is disabled.
+	Client   *agones.Client
+	Recorder *Recorder
+
+	clock clock.Clock
+}
+
+// Run runs the Agones client and the game server until it exits, and returns the exit reason.
+func (h *Harness) Run(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if h.Client != nil {
+		clock.Hold(h.clock) // Until the client waits, see agones.WithClock.
+		go h.Client.Run(ctx)
+	}
+	return h.GameServer.Run(ctx)
+}
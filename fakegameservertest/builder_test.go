@@ -0,0 +1,114 @@
+package fakegameservertest_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/fakegameservertest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestHarness_Run(t *testing.T) {
+	sdk := fakegameservertest.NewSDK()
+	sdk.Respond(fakegameservertest.MethodReady, errors.New("test"))
+
+	h, err := fakegameservertest.NewBuilder().
+		SDK(sdk).
+		Config(func(cfg *fakegameserver.Config) {
+			cfg.UpdateAttempts = 3
+			cfg.UpdateDeadline = time.Second
+			cfg.ExitOnShutdown = ptr.To(true)
+			cfg.States = []fakegameserver.StateAfter{
+				{State: agones.StateReady, After: 10 * time.Millisecond},
+			}
+		}).
+		Build()
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := h.Run(t.Context())
+		errCh <- err
+	}()
+	// Wait for the update reply, as the exit skips ahead of it otherwise.
+	h.Recorder.WaitSequence(t, 5*time.Second,
+		fakegameserver.MessageTypeAgonesRequestUpdate,
+		fakegameserver.MessageTypeAgonesUpdateAttempt,
+		fakegameserver.MessageTypeAgonesUpdate,
+	)
+	require.Equal(t, agones.StateReady, sdk.GameServer().State)
+
+	sdk.SetState(agones.StateShutdown)
+
+	require.EqualError(t, <-errCh, "signal 15") // Exits as if terminated by Agones.
+	h.Recorder.AssertSequence(t,
+		fakegameserver.MessageTypeAgonesConnection,
+		fakegameserver.MessageTypeAgonesRequestUpdate,
+		fakegameserver.MessageTypeAgonesUpdateAttempt,
+		fakegameserver.MessageTypeAgonesUpdate,
+		fakegameserver.MessageTypeExit,
+	)
+	assert.Equal(t, []fakegameservertest.Method{fakegameservertest.MethodReady, fakegameservertest.MethodReady}, filter(sdk.Calls(), fakegameservertest.MethodReady))
+}
+
+func TestSDK_Counters(t *testing.T) {
+	sdk := fakegameservertest.NewSDK()
+	sdk.SetCounter("rooms", 0, 2)
+	client := sdk.NewClient()
+
+	_, err := client.UpdateCounter(t.Context(), "rooms", 2)
+	require.NoError(t, err)
+
+	_, err = client.UpdateCounter(t.Context(), "rooms", 1)
+
+	require.Error(t, err)
+	assert.Equal(t, agones.Counter{Count: 2, Capacity: 2}, sdk.GameServer().Counters["rooms"])
+}
+
+func filter(calls []fakegameservertest.Method, m fakegameservertest.Method) []fakegameservertest.Method {
+	var got []fakegameservertest.Method
+	for _, c := range calls {
+		if c == m {
+			got = append(got, c)
+		}
+	}
+	return got
+}
+
+func TestHarness_RunVirtual(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	h, err := fakegameservertest.NewBuilder().
+		Clock(clk).
+		Config(func(cfg *fakegameserver.Config) {
+			cfg.HealthReportInterval = time.Minute
+			cfg.ExitOnShutdown = ptr.To(true)
+			cfg.States = []fakegameserver.StateAfter{
+				{State: agones.StateReady, After: time.Minute},
+				{State: agones.StateShutdown, After: 30 * time.Minute},
+			}
+		}).
+		Build()
+	require.NoError(t, err)
+
+	_, err = h.RunVirtual(t.Context(), clk, time.Hour)
+
+	require.EqualError(t, err, "signal 15")
+	assert.Equal(t, agones.StateShutdown, h.SDK.GameServer().State)
+	assert.Equal(t, 31, h.SDK.HealthReports()) // Once healthy, then every minute of the 30m in Ready.
+}
+
+func TestHarness_RunVirtualTimeLimit(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	h, err := fakegameservertest.NewBuilder().Clock(clk).Build()
+	require.NoError(t, err)
+
+	_, err = h.RunVirtual(t.Context(), clk, time.Minute)
+
+	require.ErrorIs(t, err, fakegameservertest.ErrTimeLimit)
+}
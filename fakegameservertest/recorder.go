@@ -0,0 +1,134 @@
+package fakegameservertest
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+)
+
+var _ fakegameserver.Subscriber = (*Recorder)(nil)
+
+// Recorder records all messages of a game server.
+type Recorder struct {
+	mu     sync.Mutex
+	msgs   []fakegameserver.Message
+	notify chan struct{}
+}
+
+// NewRecorder returns a new recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{notify: make(chan struct{})}
+}
+
+// Subscriptions returns all message types.
+func (r *Recorder) Subscriptions() []fakegameserver.MessageType {
+	return []fakegameserver.MessageType{"*"}
+}
+
+// Consume records a message.
+func (r *Recorder) Consume(msg fakegameserver.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.msgs = append(r.msgs, msg)
+	close(r.notify)
+	r.notify = make(chan struct{})
+}
+
+// Messages returns the recorded messages in order.
+func (r *Recorder) Messages() []fakegameserver.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.msgs)
+}
+
+// Len returns the number of recorded messages.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.msgs)
+}
+
+// Types returns the types of the recorded messages in order.
+func (r *Recorder) Types() []fakegameserver.MessageType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	types := make([]fakegameserver.MessageType, 0, len(r.msgs))
+	for _, msg := range r.msgs {
+		types = append(types, msg.Type)
+	}
+	return types
+}
+
+// Wait waits until a message matches, and returns it. It returns false if no message matched within the timeout.
+func (r *Recorder) Wait(timeout time.Duration, match fakegameserver.MessageMatcher) (fakegameserver.Message, bool) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var seen int
+	for {
+		r.mu.Lock()
+		msgs, notify := r.msgs[seen:], r.notify
+		seen = len(r.msgs)
+		r.mu.Unlock()
+
+		for _, msg := range msgs {
+			if match(msg) {
+				return msg, true
+			}
+		}
+
+		select {
+		case <-notify:
+		case <-timer.C:
+			return fakegameserver.Message{}, false
+		}
+	}
+}
+
+// WaitSequence waits until the recorded messages contain the types in order, not necessarily adjacent, and fails the
+// test on timeout.
+func (r *Recorder) WaitSequence(t testing.TB, timeout time.Duration, types ...fakegameserver.MessageType) {
+	t.Helper()
+
+	if len(types) == 0 {
+		return
+	}
+
+	var next int
+	_, ok := r.Wait(timeout, func(msg fakegameserver.Message) bool {
+		if fakegameserver.MatchMessageType(types[next], msg.Type) {
+			next++
+		}
+		return next == len(types)
+	})
+	if !ok {
+		t.Fatalf("timed out waiting for message sequence %v: got %v", types, r.Types())
+	}
+}
+
+// AssertSequence asserts that the recorded messages contain the types in order, not necessarily adjacent. A type may
+// contain the wildcard `*`.
+func (r *Recorder) AssertSequence(t testing.TB, types ...fakegameserver.MessageType) bool {
+	t.Helper()
+
+	got := r.Types()
+
+	var next int
+	for _, typ := range got {
+		if next < len(types) && fakegameserver.MatchMessageType(types[next], typ) {
+			next++
+		}
+	}
+	if next < len(types) {
+		t.Errorf("message sequence %v not recorded, missing %v: got %v", types, types[next:], got)
+		return false
+	}
+	return true
+}
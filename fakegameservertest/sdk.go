@@ -0,0 +1,589 @@
+// Package fakegameservertest provides an in-memory Agones SDK, a builder of fake game servers wired like the fakegs,
+// and a recorder to assert on the messages of a fake game server.
+package fakegameservertest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"agones.dev/agones/pkg/sdk"
+	"agones.dev/agones/pkg/sdk/alpha"
+	"agones.dev/agones/pkg/sdk/beta"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Method is a method of the Agones SDK.
+type Method string
+
+// Methods of the Agones SDK, that responses can be scripted for.
+const (
+	MethodReady               Method = "Ready"
+	MethodAllocate            Method = "Allocate"
+	MethodShutdown            Method = "Shutdown"
+	MethodReserve             Method = "Reserve"
+	MethodHealth              Method = "Health"
+	MethodGetGameServer       Method = "GetGameServer"
+	MethodWatchGameServer     Method = "WatchGameServer"
+	MethodSetLabel            Method = "SetLabel"
+	MethodSetAnnotation       Method = "SetAnnotation"
+	MethodGetCounter          Method = "GetCounter"
+	MethodUpdateCounter       Method = "UpdateCounter"
+	MethodPlayerConnect       Method = "PlayerConnect"
+	MethodPlayerDisconnect    Method = "PlayerDisconnect"
+	MethodGetConnectedPlayers Method = "GetConnectedPlayers"
+)
+
+// SDK is an in-memory fake of the Agones SDK server.
+//
+// State changes are applied immediately and sent to all watches. Responses can be scripted per method, e.g. to fail
+// the next Ready calls.
+type SDK struct {
+	mu        sync.Mutex
+	gs        agones.GameServer
+	players   []string
+	playerCap int64
+	health    int
+	calls     []Method
+	responses map[Method][]error
+	watches   map[*watchStream]struct{}
+	reserve   clock.Timer
+	clock     clock.Clock
+
+	healthTimeout time.Duration
+	healthTimer   clock.Timer
+}
+
+// NewSDK returns a new fake SDK with a game server in the Agones state Scheduled.
+func NewSDK() *SDK {
+	return &SDK{
+		gs: agones.GameServer{
+			Name:        "fakegs",
+			State:       agones.StateScheduled,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+			Counters:    map[string]agones.Counter{},
+		},
+		responses: map[Method][]error{},
+		watches:   map[*watchStream]struct{}{},
+		clock:     clock.Real(),
+	}
+}
+
+// SetClock sets the clock that reservations expire on.
+func (s *SDK) SetClock(c clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clock = c
+}
+
+// SetHealthTimeout moves the game server to the Agones state Unhealthy when no health report is received within the
+// timeout, as the Agones SDK server does outside of local development mode. Zero disables it, which is the default.
+func (s *SDK) SetHealthTimeout(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.healthTimeout = d
+	s.resetHealthTimer()
+}
+
+// resetHealthTimer restarts the health timeout. It must be called with the lock held.
+func (s *SDK) resetHealthTimer() {
+	if s.healthTimer != nil {
+		s.healthTimer.Stop()
+		s.healthTimer = nil
+	}
+	if s.healthTimeout <= 0 {
+		return
+	}
+
+	var timer clock.Timer
+	timer = s.clock.AfterFunc(s.healthTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.healthTimer != timer || s.gs.State.IsFinal() {
+			return
+		}
+		s.updateLocked(func() { s.gs.State = agones.StateUnhealthy })
+	})
+	s.healthTimer = timer
+}
+
+// Respond scripts the responses of the next calls of a method. A nil error lets the call succeed as usual, an error
+// fails the call without any effect. Once the scripted responses are used up, calls succeed again.
+func (s *SDK) Respond(m Method, errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[m] = append(s.responses[m], errs...)
+}
+
+// GameServer returns the current game server.
+func (s *SDK) GameServer() agones.GameServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cloneGameServer()
+}
+
+// Calls returns the called methods in order.
+func (s *SDK) Calls() []Method {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.calls)
+}
+
+// CallCount returns the number of calls, including health reports.
+func (s *SDK) CallCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.calls)
+}
+
+// HealthReports returns the number of received health reports.
+func (s *SDK) HealthReports() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.health
+}
+
+// SetState sets the Agones state, as the Agones controller does, e.g. on an allocation.
+func (s *SDK) SetState(state agones.State) {
+	s.update(func() { s.gs.State = state })
+}
+
+// SetLabel sets a label, as set by the Agones controller.
+func (s *SDK) SetLabel(key, value string) {
+	s.update(func() { s.gs.Labels[key] = value })
+}
+
+// SetAnnotation sets an annotation, e.g. as set by an allocation.
+func (s *SDK) SetAnnotation(key, value string) {
+	s.update(func() { s.gs.Annotations[key] = value })
+}
+
+// SetCounter sets a counter, as set by the Agones controller, e.g. on an allocation.
+func (s *SDK) SetCounter(name string, count, capacity int64) {
+	s.update(func() { s.gs.Counters[name] = agones.Counter{Count: count, Capacity: capacity} })
+}
+
+// SetLocal marks the SDK server as running in local development mode.
+func (s *SDK) SetLocal(local bool) {
+	if local {
+		s.SetLabel("islocal", "true")
+		return
+	}
+	s.update(func() { delete(s.gs.Labels, "islocal") })
+}
+
+// Disconnect ends all watches with an error, as if the connection to the SDK server was lost.
+func (s *SDK) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for w := range s.watches {
+		w.end(status.Error(codes.Unavailable, "connection lost"))
+		delete(s.watches, w)
+	}
+}
+
+// Client returns a client of the SDK.
+func (s *SDK) Client() sdk.SDKClient {
+	return sdkClient{s: s}
+}
+
+// AlphaClient returns a client of the alpha SDK.
+func (s *SDK) AlphaClient() alpha.SDKClient {
+	return alphaClient{s: s}
+}
+
+// BetaClient returns a client of the beta SDK.
+func (s *SDK) BetaClient() beta.SDKClient {
+	return betaClient{s: s}
+}
+
+// NewClient returns an Agones client of the SDK, with the alpha and beta SDK.
+func (s *SDK) NewClient(opts ...agones.Option) *agones.Client {
+	opts = append([]agones.Option{agones.WithAlphaSDK(s.AlphaClient()), agones.WithBetaSDK(s.BetaClient())}, opts...)
+	return agones.NewClient(s.Client(), opts...)
+}
+
+// call records a call and returns its scripted error. It must be called with the lock held.
+func (s *SDK) call(m Method) error {
+	s.calls = append(s.calls, m)
+
+	errs := s.responses[m]
+	if len(errs) == 0 {
+		return nil
+	}
+	s.responses[m] = errs[1:]
+	return errs[0]
+}
+
+// update applies a change and sends the game server to all watches.
+func (s *SDK) update(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateLocked(fn)
+}
+
+func (s *SDK) updateLocked(fn func()) {
+	fn()
+	s.gs.Players = int64(len(s.players))
+
+	raw := s.sdkGameServer()
+	for w := range s.watches {
+		w.send(raw)
+	}
+}
+
+func (s *SDK) cloneGameServer() agones.GameServer {
+	gs := s.gs
+	gs.Labels = maps.Clone(s.gs.Labels)
+	gs.Annotations = maps.Clone(s.gs.Annotations)
+	gs.Counters = maps.Clone(s.gs.Counters)
+	return gs
+}
+
+func (s *SDK) sdkGameServer() *sdk.GameServer {
+	counters := make(map[string]*sdk.GameServer_Status_CounterStatus, len(s.gs.Counters))
+	for name, c := range s.gs.Counters {
+		counters[name] = &sdk.GameServer_Status_CounterStatus{Count: c.Count, Capacity: c.Capacity}
+	}
+
+	return &sdk.GameServer{
+		ObjectMeta: &sdk.GameServer_ObjectMeta{
+			Name:        s.gs.Name,
+			Labels:      maps.Clone(s.gs.Labels),
+			Annotations: maps.Clone(s.gs.Annotations),
+		},
+		Status: &sdk.GameServer_Status{
+			State:    string(s.gs.State),
+			Players:  &sdk.GameServer_Status_PlayerStatus{Count: int64(len(s.players)), Capacity: s.playerCap, Ids: slices.Clone(s.players)},
+			Counters: counters,
+		},
+	}
+}
+
+// setState calls a state changing method.
+func (s *SDK) setState(m Method, state agones.State) (*sdk.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(m); err != nil {
+		return nil, err
+	}
+	if s.reserve != nil {
+		s.reserve.Stop()
+		s.reserve = nil
+	}
+	s.updateLocked(func() { s.gs.State = state })
+	return &sdk.Empty{}, nil
+}
+
+type sdkClient struct {
+	s *SDK
+}
+
+func (c sdkClient) Ready(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.Empty, error) {
+	return c.s.setState(MethodReady, agones.StateReady)
+}
+
+func (c sdkClient) Allocate(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.Empty, error) {
+	return c.s.setState(MethodAllocate, agones.StateAllocated)
+}
+
+func (c sdkClient) Shutdown(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.Empty, error) {
+	return c.s.setState(MethodShutdown, agones.StateShutdown)
+}
+
+// Reserve reserves the game server for the duration, after which it returns to Ready, unless the state changed.
+func (c sdkClient) Reserve(_ context.Context, in *sdk.Duration, _ ...grpc.CallOption) (*sdk.Empty, error) {
+	empty, err := c.s.setState(MethodReserve, agones.StateReserved)
+	if err != nil || in.GetSeconds() <= 0 {
+		return empty, err
+	}
+
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	var timer clock.Timer
+	timer = c.s.clock.AfterFunc(time.Duration(in.GetSeconds())*time.Second, func() {
+		c.s.mu.Lock()
+		defer c.s.mu.Unlock()
+
+		if c.s.reserve != timer {
+			return
+		}
+		c.s.reserve = nil
+		c.s.updateLocked(func() { c.s.gs.State = agones.StateReady })
+	})
+	c.s.reserve = timer
+	return empty, nil
+}
+
+func (c sdkClient) Health(ctx context.Context, _ ...grpc.CallOption) (sdk.SDK_HealthClient, error) {
+	return &healthStream{stream: stream{ctx: ctx}, s: c.s}, nil
+}
+
+func (c sdkClient) GetGameServer(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.GameServer, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodGetGameServer); err != nil {
+		return nil, err
+	}
+	return c.s.sdkGameServer(), nil
+}
+
+func (c sdkClient) WatchGameServer(ctx context.Context, _ *sdk.Empty, _ ...grpc.CallOption) (sdk.SDK_WatchGameServerClient, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodWatchGameServer); err != nil {
+		return nil, err
+	}
+
+	w := &watchStream{stream: stream{ctx: ctx}, ch: make(chan *sdk.GameServer, 1), endCh: make(chan struct{}), clock: c.s.clock}
+	w.send(c.s.sdkGameServer()) // The SDK server sends the current game server first.
+	c.s.watches[w] = struct{}{}
+	return w, nil
+}
+
+func (c sdkClient) SetLabel(_ context.Context, in *sdk.KeyValue, _ ...grpc.CallOption) (*sdk.Empty, error) {
+	return c.s.setMeta(MethodSetLabel, func() { c.s.gs.Labels["agones.dev/sdk-"+in.GetKey()] = in.GetValue() })
+}
+
+func (c sdkClient) SetAnnotation(_ context.Context, in *sdk.KeyValue, _ ...grpc.CallOption) (*sdk.Empty, error) {
+	return c.s.setMeta(MethodSetAnnotation, func() { c.s.gs.Annotations["agones.dev/sdk-"+in.GetKey()] = in.GetValue() })
+}
+
+func (s *SDK) setMeta(m Method, fn func()) (*sdk.Empty, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.call(m); err != nil {
+		return nil, err
+	}
+	s.updateLocked(fn)
+	return &sdk.Empty{}, nil
+}
+
+// alphaClient is a client of the alpha SDK. Methods that are not implemented panic.
+type alphaClient struct {
+	alpha.SDKClient
+
+	s *SDK
+}
+
+func (c alphaClient) PlayerConnect(_ context.Context, in *alpha.PlayerID, _ ...grpc.CallOption) (*alpha.Bool, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodPlayerConnect); err != nil {
+		return nil, err
+	}
+	if slices.Contains(c.s.players, in.PlayerID) {
+		return &alpha.Bool{Bool: false}, nil
+	}
+	if c.s.playerCap > 0 && int64(len(c.s.players)) >= c.s.playerCap {
+		return nil, status.Error(codes.ResourceExhausted, "players are already at capacity")
+	}
+	c.s.updateLocked(func() { c.s.players = append(c.s.players, in.PlayerID) })
+	return &alpha.Bool{Bool: true}, nil
+}
+
+func (c alphaClient) PlayerDisconnect(_ context.Context, in *alpha.PlayerID, _ ...grpc.CallOption) (*alpha.Bool, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodPlayerDisconnect); err != nil {
+		return nil, err
+	}
+	idx := slices.Index(c.s.players, in.PlayerID)
+	if idx == -1 {
+		return &alpha.Bool{Bool: false}, nil
+	}
+	c.s.updateLocked(func() { c.s.players = slices.Delete(c.s.players, idx, idx+1) })
+	return &alpha.Bool{Bool: true}, nil
+}
+
+func (c alphaClient) SetPlayerCapacity(_ context.Context, in *alpha.Count, _ ...grpc.CallOption) (*alpha.Empty, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	c.s.updateLocked(func() { c.s.playerCap = in.GetCount() })
+	return &alpha.Empty{}, nil
+}
+
+func (c alphaClient) GetPlayerCapacity(context.Context, *alpha.Empty, ...grpc.CallOption) (*alpha.Count, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	return &alpha.Count{Count: c.s.playerCap}, nil
+}
+
+func (c alphaClient) GetPlayerCount(context.Context, *alpha.Empty, ...grpc.CallOption) (*alpha.Count, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	return &alpha.Count{Count: int64(len(c.s.players))}, nil
+}
+
+func (c alphaClient) IsPlayerConnected(_ context.Context, in *alpha.PlayerID, _ ...grpc.CallOption) (*alpha.Bool, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	return &alpha.Bool{Bool: slices.Contains(c.s.players, in.PlayerID)}, nil
+}
+
+func (c alphaClient) GetConnectedPlayers(context.Context, *alpha.Empty, ...grpc.CallOption) (*alpha.PlayerIDList, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodGetConnectedPlayers); err != nil {
+		return nil, err
+	}
+	return &alpha.PlayerIDList{List: slices.Clone(c.s.players)}, nil
+}
+
+// betaClient is a client of the beta SDK. Methods that are not implemented, e.g. of lists, panic.
+type betaClient struct {
+	beta.SDKClient
+
+	s *SDK
+}
+
+func (c betaClient) GetCounter(_ context.Context, in *beta.GetCounterRequest, _ ...grpc.CallOption) (*beta.Counter, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodGetCounter); err != nil {
+		return nil, err
+	}
+	counter, ok := c.s.gs.Counters[in.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "counter "+in.Name+" not found")
+	}
+	return &beta.Counter{Name: in.Name, Count: counter.Count, Capacity: counter.Capacity}, nil
+}
+
+func (c betaClient) UpdateCounter(_ context.Context, in *beta.UpdateCounterRequest, _ ...grpc.CallOption) (*beta.Counter, error) {
+	c.s.mu.Lock()
+	defer c.s.mu.Unlock()
+
+	if err := c.s.call(MethodUpdateCounter); err != nil {
+		return nil, err
+	}
+	req := in.CounterUpdateRequest
+	counter, ok := c.s.gs.Counters[req.Name]
+	if !ok {
+		return nil, status.Error(codes.NotFound, "counter "+req.Name+" not found")
+	}
+
+	if req.Capacity != nil {
+		counter.Capacity = req.Capacity.GetValue()
+	}
+	if req.Count != nil {
+		counter.Count = req.Count.GetValue()
+	}
+	counter.Count += req.CountDiff
+	if counter.Count < 0 || counter.Count > counter.Capacity {
+		return nil, status.Error(codes.OutOfRange, "count of counter "+req.Name+" out of range")
+	}
+
+	c.s.updateLocked(func() { c.s.gs.Counters[req.Name] = counter })
+	return &beta.Counter{Name: req.Name, Count: counter.Count, Capacity: counter.Capacity}, nil
+}
+
+// stream is a client stream without transport.
+type stream struct {
+	ctx context.Context //nolint:containedctx // The context of the stream.
+}
+
+func (s stream) Header() (metadata.MD, error) { return metadata.MD{}, nil }
+func (s stream) Trailer() metadata.MD         { return metadata.MD{} }
+func (s stream) CloseSend() error             { return nil }
+func (s stream) Context() context.Context     { return s.ctx }
+func (s stream) SendMsg(any) error            { return nil }
+func (s stream) RecvMsg(any) error            { return nil }
+
+type healthStream struct {
+	stream
+
+	s *SDK
+}
+
+func (h *healthStream) Send(*sdk.Empty) error {
+	h.s.mu.Lock()
+	defer h.s.mu.Unlock()
+
+	if err := h.s.call(MethodHealth); err != nil {
+		return err
+	}
+	h.s.health++
+	h.s.resetHealthTimer()
+	return nil
+}
+
+func (h *healthStream) CloseAndRecv() (*sdk.Empty, error) {
+	return &sdk.Empty{}, nil
+}
+
+type watchStream struct {
+	stream
+
+	ch     chan *sdk.GameServer
+	endCh  chan struct{}
+	endErr error
+	clock  clock.Clock
+}
+
+// send sends the latest game server, replacing an unreceived one. It must be called with the lock of the SDK held.
+//
+// On a virtual clock, the game server holds the clock until the client received it and waits again, see clock.Hold.
+func (w *watchStream) send(gs *sdk.GameServer) {
+	select {
+	case <-w.ch: // Replaced, its hold passes on to the game server.
+	default:
+		clock.Hold(w.clock)
+	}
+	w.ch <- gs
+}
+
+// end ends the watch. It must be called with the lock of the SDK held.
+func (w *watchStream) end(err error) {
+	w.endErr = err
+	clock.Hold(w.clock) // Until the client received the end.
+	close(w.endCh)
+}
+
+func (w *watchStream) Recv() (*sdk.GameServer, error) {
+	select {
+	case gs := <-w.ch: // Before the end, as a stream does.
+		return gs, nil
+	default:
+	}
+
+	select {
+	case <-w.ctx.Done():
+		return nil, status.FromContextError(w.ctx.Err()).Err()
+	case gs := <-w.ch:
+		return gs, nil
+	case <-w.endCh:
+		return nil, w.endErr
+	}
+}
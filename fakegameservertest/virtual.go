@@ -0,0 +1,56 @@
+package fakegameservertest
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+// ErrTimeLimit is returned when the game server did not exit within the limit of virtual time.
+var ErrTimeLimit = errors.New("game server did not exit within the time limit")
+
+// RunVirtual runs the game server on the virtual clock, until it exits or the limit of virtual time is reached.
+//
+// Whenever the game server only waits for the clock, the clock is advanced to the next timer, so a lifecycle of hours
+// runs within seconds. Timers due at the same time fire one by one, in the order they were set. The harness must be
+// built with the same clock.
+//
+// The game server waits for the clock once nothing holds it anymore, see clock.Hold: messages hold it until they are
+// consumed, producers and the Agones client while they are busy, and ticks until they are handled. Custom producers
+// must release the clock whenever they wait, and hold it for any goroutine they start or wake up, otherwise RunVirtual
+// either advances the clock too early or not at all.
+func (h *Harness) RunVirtual(ctx context.Context, clk *clock.Virtual, limit time.Duration) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		reason string
+		err    error
+	}
+	runCh := make(chan result, 1)
+	clk.Hold() // Released while the game server runs, see fakegameserver.GameServer.Run.
+	go func() {
+		reason, err := h.Run(ctx)
+		runCh <- result{reason: reason, err: err}
+	}()
+
+	deadline := clk.Now().Add(limit)
+	for {
+		select {
+		case res := <-runCh:
+			clk.Release()
+			return res.reason, res.err
+		case <-clk.Idle():
+		}
+
+		if next, ok := clk.Next(); !ok || next.After(deadline) {
+			cancel()
+			<-runCh
+			clk.Release()
+			return "", ErrTimeLimit
+		}
+		clk.FireNext()
+	}
+}
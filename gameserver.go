@@ -2,9 +2,12 @@ package fakegameserver
 
 import (
 	"context"
-	"reflect"
+	"fmt"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/antiphp/fakegameserver/clock"
 	"github.com/antiphp/fakegameserver/internal/queue"
 	"github.com/google/uuid"
 	"github.com/hamba/logger/v2"
@@ -20,6 +23,22 @@ type Message struct {
 	Payload     any
 	Origin      string
 	Created     time.Time
+
+	// CorrelationID identifies the chain of messages a message belongs to. It is the ID of the first message of the
+	// chain.
+	CorrelationID string
+	// CausationID is the ID of the message that caused the message, if any.
+	CausationID string
+}
+
+// Reply returns the reply to the message, carrying the correlation forward.
+func (m Message) Reply(reply Message) Message {
+	reply.CorrelationID = m.CorrelationID
+	if reply.CorrelationID == "" {
+		reply.CorrelationID = m.ID
+	}
+	reply.CausationID = m.ID
+	return reply
 }
 
 // MessageType is the type of a message.
@@ -48,87 +67,524 @@ type Consumer interface {
 	Consume(Message)
 }
 
+// QueuePolicy is the overflow policy of a bounded message queue.
+type QueuePolicy = queue.Policy
+
+const (
+	// QueuePolicyBlock blocks producers until the message queue has space.
+	QueuePolicyBlock = queue.PolicyBlock
+
+	// QueuePolicyDropOldest drops the oldest message to make space.
+	QueuePolicyDropOldest = queue.PolicyDropOldest
+
+	// QueuePolicyDropNewest drops the added message.
+	QueuePolicyDropNewest = queue.PolicyDropNewest
+
+	// QueuePolicyCoalesce replaces a queued message with the same key, by default of the same type and origin.
+	QueuePolicyCoalesce = queue.PolicyCoalesce
+)
+
+// ParseQueuePolicy parses a message queue overflow policy.
+func ParseQueuePolicy(s string) (QueuePolicy, error) {
+	return queue.ParsePolicy(s)
+}
+
+// Priority is the priority of a message in the message queue.
+type Priority int
+
+const (
+	// PriorityExit is the priority of exit messages.
+	PriorityExit Priority = iota
+
+	// PriorityControl is the priority of control messages, e.g. Agones state update requests.
+	PriorityControl
+
+	// PriorityRoutine is the priority of all other messages.
+	PriorityRoutine
+
+	numPriorities = int(PriorityRoutine) + 1
+)
+
+// DefaultPriority returns the default priority of a message.
+func DefaultPriority(msg Message) Priority {
+	switch msg.Type {
+	case MessageTypeExit:
+		return PriorityExit
+	case MessageTypeAgonesRequestUpdate, MessageTypeAgonesStopHealth:
+		return PriorityControl
+	default:
+		return PriorityRoutine
+	}
+}
+
+// Option is a game server option.
+type Option func(*GameServer)
+
+// WithQueue bounds the message queue to the given size, applying the overflow policy when it is full. The inbox of each
+// consumer is bounded alike.
+//
+// Beware that with a blocking policy, a consumer that blocks on a producer that is blocked by the full queue
+// dead-locks the game server.
+func WithQueue(size int, policy QueuePolicy) Option {
+	return func(g *GameServer) {
+		g.queueCfg.Size = size
+		g.queueCfg.Policy = policy
+	}
+}
+
+// WithRingInboxes queues the messages of each consumer in a lock-free ring of the given size, instead of an inbox
+// bounded and prioritized like the message queue. The ring applies the overflow policy of the message queue, but blocks
+// instead of coalescing. A consumer retrieves the messages of its ring in batches, so that a message of a higher
+// priority only skips ahead of the messages of the next batch.
+//
+// A size of zero or less keeps the inboxes like the message queue.
+func WithRingInboxes(size int) Option {
+	return func(g *GameServer) {
+		g.ringSize = size
+	}
+}
+
+// WithConsumerTimeout sets the duration after which a consumer busy with a single message is reported as stuck.
+//
+// A timeout of zero or less disables the watchdog. The consumers are checked at half the timeout, but at most every
+// millisecond.
+func WithConsumerTimeout(timeout time.Duration) Option {
+	return func(g *GameServer) {
+		g.consumerTimeout = timeout
+	}
+}
+
+// WithPriority sets the priority of messages in the message queue.
+//
+// Messages of a higher priority skip ahead of messages of a lower priority, the order of messages of the same priority
+// is kept. This applies to the inbox of each consumer as well.
+func WithPriority(fn func(Message) Priority) Option {
+	return func(g *GameServer) {
+		g.queueCfg.Priority = func(m Message) int {
+			return int(fn(m))
+		}
+	}
+}
+
+// WithStopTimeout sets the timeout to notify the exit hooks, to drain the consumers, to wait for the producers and to
+// stop the handlers, once the game server exits.
+//
+// The stop timeout is on the wall clock rather than the clock of the game server. It guards against handlers that hang,
+// which a virtual clock would never time out, as nothing advances it then.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(g *GameServer) {
+		g.stopTimeout = timeout
+	}
+}
+
+// WithQueueKey sets the key to coalesce messages by.
+func WithQueueKey(fn func(Message) string) Option {
+	return func(g *GameServer) {
+		g.queueCfg.Key = fn
+	}
+}
+
+// WithInterceptor adds interceptors to the delivery of messages. The first added interceptor intercepts first.
+func WithInterceptor(i ...Interceptor) Option {
+	return func(g *GameServer) {
+		g.interceptors = append(g.interceptors, i...)
+	}
+}
+
+// WithClock sets the clock of the game server, e.g. a scaled clock to run a lifecycle faster, or a virtual clock in
+// tests. The clock is passed on to the handlers with the context, see clock.FromContext.
+func WithClock(c clock.Clock) Option {
+	return func(g *GameServer) {
+		g.clock = c
+	}
+}
+
 // GameServer is the game server.
 type GameServer struct {
-	queue     *queue.Fifo[Message]
-	producers []Producer
-	consumers []Consumer
+	queueCfg        queue.Config[Message]
+	queue           *queue.Fifo[Message]
+	ringSize        int
+	consumerTimeout time.Duration
+	stopTimeout     time.Duration
+	proj            *Projection
+	interceptors    []Interceptor
+	clock           clock.Clock
+
+	mu       sync.RWMutex
+	handlers []any
+	run      *runState // Set while running.
 
 	log *logger.Logger
 }
 
 // New creates a new game server.
-func New(log *logger.Logger) *GameServer {
-	return &GameServer{
-		queue: queue.NewFifo[Message](),
-		log:   log,
+func New(log *logger.Logger, opts ...Option) *GameServer {
+	g := &GameServer{
+		queueCfg: queue.Config[Message]{
+			Key: func(m Message) string {
+				return string(m.Type) + "/" + m.Origin
+			},
+			Lanes: numPriorities,
+			Priority: func(m Message) int {
+				return int(DefaultPriority(m))
+			},
+		},
+		consumerTimeout: 10 * time.Second,
+		stopTimeout:     5 * time.Second,
+		clock:           clock.Real(),
+		log:             log,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	g.proj = newProjection(g.clock)
+	g.queueCfg.OnDrop = func(Message) {
+		clock.Release(g.clock) // A dropped message is done, see queueFor.
+	}
+	for _, i := range g.interceptors {
+		if c, ok := i.(clocked); ok {
+			c.setClock(g.clock)
+		}
 	}
+	g.queue = queue.New(g.queueCfg)
+
+	return g
+}
+
+// Projection returns the projection of the game server state, for handlers to read from.
+func (g *GameServer) Projection() *Projection {
+	return g.proj
+}
+
+// QueueLen returns the number of queued messages.
+func (g *GameServer) QueueLen() int {
+	return g.queue.Len()
+}
+
+// QueueDropped returns the number of messages dropped or coalesced by the message queue.
+func (g *GameServer) QueueDropped() int64 {
+	return g.queue.Dropped()
 }
 
 // AddProducer adds a message producer to the game server.
 func (g *GameServer) AddProducer(p ...Producer) {
-	g.producers = append(g.producers, p...)
+	for _, prod := range p {
+		g.register(prod)
+	}
 }
 
 // AddConsumer adds a mesage consumer to the game server.
+//
+// A consumer that implements Subscriber only receives messages of its subscribed message types.
 func (g *GameServer) AddConsumer(c ...Consumer) {
-	g.consumers = append(g.consumers, c...)
+	for _, cons := range c {
+		_ = subscriptions(cons) // Validate early.
+		g.register(cons)
+	}
 }
 
 // AddHandler adds a message handler to the game server.
+//
+// A handler implements Producer or Consumer, and optionally the lifecycle hooks Starter, Stopper and ExitHook. A
+// handler that only implements lifecycle hooks is allowed as well.
+//
+// A handler added while the game server is running is started right away, and only receives messages queued after it
+// was added. If it fails to start, it is removed again and an error message is sent.
 func (g *GameServer) AddHandler(hdlr any) {
-	p, ok1 := hdlr.(Producer)
-	if ok1 {
-		g.AddProducer(p)
+	switch h := hdlr.(type) {
+	case Consumer:
+		g.AddConsumer(h)
+	case Producer, Starter, Stopper, ExitHook:
+		g.register(hdlr)
+	default:
+		panic("Handler must either implement Producer, Consumer or a lifecycle interface") // Developer error.
 	}
-	c, ok2 := hdlr.(Consumer)
-	if ok2 {
-		g.AddConsumer(c)
+}
+
+// RemoveHandler removes a message handler from the game server. It returns false if the handler was not added.
+//
+// A handler removed while the game server is running is stopped, as if the game server stopped: its producer is
+// cancelled, its consumer consumes its remaining messages, and it is stopped, within the stop timeout. Only handlers
+// of comparable types, e.g. pointers, can be removed.
+func (g *GameServer) RemoveHandler(hdlr any) bool {
+	g.mu.Lock()
+	idx := slices.IndexFunc(g.handlers, func(other any) bool { return sameHandler(hdlr, other) })
+	if idx == -1 {
+		g.mu.Unlock()
+		return false
 	}
-	if !ok1 && !ok2 {
-		panic("Handler must either implement Producer or Consumer interface") // Developer error.
+	g.handlers = slices.Delete(g.handlers, idx, idx+1)
+
+	var a *attachment
+	if g.run != nil {
+		a = g.run.detach(hdlr)
+	}
+	g.mu.Unlock()
+
+	if a != nil {
+		g.stopAttachment(a)
+	}
+	return true
+}
+
+// register registers a handler, and starts it if the game server is running.
+func (g *GameServer) register(hdlr any) {
+	if c, ok := hdlr.(clocked); ok {
+		c.setClock(g.clock)
+	}
+
+	g.mu.Lock()
+	if !g.addHandler(hdlr) {
+		g.mu.Unlock()
+		return
+	}
+	run := g.run
+	g.mu.Unlock()
+
+	if run == nil {
+		return
+	}
+
+	if s, ok := hdlr.(Starter); ok {
+		if err := s.Start(run.ctx); err != nil {
+			g.RemoveHandler(hdlr)
+			g.queueFor(run.ctx, handlerName(hdlr)).Add(Message{
+				Type:        MessageTypeError,
+				Description: "Handler " + handlerName(hdlr) + " failed to start",
+				Error:       err,
+			})
+			return
+		}
+	}
+
+	g.mu.Lock()
+	attached := g.run == run && (!removable(hdlr) || slices.ContainsFunc(g.handlers, func(other any) bool { return sameHandler(hdlr, other) }))
+	if attached {
+		g.attach(run, hdlr)
+	}
+	g.mu.Unlock()
+
+	if !attached { // The game server stopped, or the handler was removed while starting.
+		g.stop(context.Background(), []any{hdlr})
 	}
 }
 
 // Run starts the game server and runs all producers and consumers.
+//
+// Handlers are started in the order of their dependencies. Each consumer consumes messages in its own goroutine, in the
+// order of the message queue, from an inbox that is bounded and prioritized like the message queue. A panicking
+// consumer is reported with an error message, a consumer that is stuck with a message is reported by a watchdog.
+//
+// Once the game server exits, the exit hooks are notified, the consumers consume their remaining messages followed by
+// the exit message, the producers are cancelled and the handlers are stopped in reverse order, all within the stop
+// timeout.
+//
+// On a virtual clock, each message holds the clock until it is consumed or dropped, see clock.Hold. Run releases a hold
+// of the caller once the handlers are started, and takes it back once the game server exits. Producers are started with
+// the clock held, and release it whenever they wait.
 func (g *GameServer) Run(ctx context.Context) (string, error) {
-	ctx, cancel := context.WithCancel(ctx)
+	ctx = clock.NewContext(ctx, g.clock)
+
+	runCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	context.AfterFunc(ctx, func() {
+	// Handlers added while the handlers start are started and attached right away.
+	run := &runState{ctx: runCtx}
+	g.mu.Lock()
+	order := g.startOrder()
+	g.run = run
+	g.mu.Unlock()
+
+	if err := g.start(ctx, order); err != nil {
+		g.mu.Lock()
+		g.run = nil
+		added := slices.Clone(run.attached)
+		g.mu.Unlock()
+
+		for _, a := range added {
+			g.stopAttachment(a)
+		}
+		return "", fmt.Errorf("starting handlers: %w", err)
+	}
+
+	context.AfterFunc(runCtx, func() {
 		g.queue.Shutdown()
 	})
 
-	for _, p := range g.producers {
-		name := reflect.TypeOf(p).Elem().String()
+	var removed []any
+	g.mu.Lock()
+	added := run.attached
+	run.attached = nil
+	for _, h := range order {
+		if removable(h) && !slices.ContainsFunc(g.handlers, func(other any) bool { return sameHandler(h, other) }) {
+			removed = append(removed, h) // Removed while starting.
+			continue
+		}
+		g.attach(run, h)
+	}
+	run.attached = append(run.attached, added...)
+	if g.consumerTimeout > 0 {
+		run.producerWG.Add(1)
+		clock.Hold(g.clock)
+		go func() {
+			defer run.producerWG.Done()
+			watchdog(runCtx, g.queueFor(runCtx, "watchdog"), g.inboxes, g.consumerTimeout)
+		}()
+	}
+	g.mu.Unlock()
+
+	g.stop(ctx, removed)
+
+	clock.Release(g.clock) // Started, the handlers hold the clock themselves from now on.
+	exitMsg := g.dispatch(runCtx)
+	reason, err := exitMsg.Description, exitMsg.Error
+
+	g.queue.Shutdown()
+
+	g.mu.Lock()
+	g.run = nil
+	order = run.handlers()
+	g.mu.Unlock()
+
+	stopCtx, stopCancel := context.WithTimeout(context.WithoutCancel(ctx), g.stopTimeout) // On the wall clock, see WithStopTimeout.
+	defer stopCancel()
+
+	g.exit(stopCtx, order, reason, err)
 
-		go p.Run(ctx, queueFn(func(m Message) {
-			m.ID = uuid.NewString()
-			m.Created = time.Now()
-			m.Origin = name
-			g.queue.Add(m)
-		}))
+	if exitMsg.Type == MessageTypeExit {
+		deliver(stopCtx, run.inboxes, exitMsg) // After the exit hooks, as documented.
 	}
+	for _, i := range run.inboxes {
+		i.queue.Close()
+	}
+	if !wait(stopCtx, &run.consumerWG) {
+		g.log.Error("Game server consumers did not finish within the stop timeout", lctx.Str("timeout", g.stopTimeout.String()))
+	}
+
+	cancel()
+	if !wait(stopCtx, &run.producerWG) {
+		g.log.Error("Game server producers did not finish within the stop timeout", lctx.Str("timeout", g.stopTimeout.String()))
+	}
+
+	g.stop(stopCtx, order)
+
+	if dropped := g.queue.Dropped(); dropped > 0 {
+		g.log.Info("Game server messages dropped by the message queue", lctx.Int64("dropped", dropped))
+	}
+	for _, i := range run.inboxes {
+		if dropped := i.queue.Dropped(); dropped > 0 {
+			g.log.Info("Game server messages dropped by a consumer inbox", lctx.Str("consumer", i.name), lctx.Int64("dropped", dropped))
+		}
+	}
+
+	snap := g.proj.Snapshot()
+	g.log.Info("Game server summary",
+		lctx.Str("state", string(snap.State)),
+		lctx.Str("durations", snap.String()),
+		lctx.Int64("players", snap.Players),
+		lctx.Int64("messages", snap.Messages),
+	)
+	return reason, err
+}
+
+// dispatch dispatches messages through the interceptors to the consumer inboxes, until an exit message is received or
+// the queue is shut down. The exit message is returned without delivering it, to deliver it after the exit hooks.
+func (g *GameServer) dispatch(ctx context.Context) Message {
+	var (
+		mu      sync.Mutex
+		exited  bool
+		exitMsg Message
+	)
+	dispatch := chain(g.interceptors, func(msg Message) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if exited || !g.receive(msg) {
+			return
+		}
+		if msg.Type != MessageTypeExit {
+			deliver(ctx, g.inboxes(), msg)
+			return
+		}
+		exited, exitMsg = true, msg
+		clock.Hold(g.clock) // Taken back for the caller, see Run.
+		g.queue.Shutdown()
+	})
 
 	for {
 		msg, shutdown := g.queue.Get()
 		if shutdown {
-			return "", nil
+			break
 		}
+		dispatch(msg)
+		clock.Release(g.clock) // Delivered to the inboxes, delayed or dropped.
+	}
 
-		log := g.log.With(lctx.Str("desc", msg.Description), lctx.Str("type", string(msg.Type)))
-		if msg.Error != nil {
-			log = log.With(lctx.Err(msg.Error))
-		}
-		log.Info("Game server message received")
+	mu.Lock()
+	defer mu.Unlock()
 
-		for _, c := range g.consumers {
-			c.Consume(msg)
-		}
+	if !exited {
+		clock.Hold(g.clock) // Taken back for the caller, see Run.
+	}
+	exited = true // Ignore delayed messages.
+	return exitMsg
+}
+
+// receive logs a message and applies it to the projection. It returns false if the message is dropped.
+func (g *GameServer) receive(msg Message) bool {
+	log := g.log.With(lctx.Str("desc", msg.Description), lctx.Str("type", string(msg.Type)))
+	if err := CheckPayload(msg); err != nil {
+		log.Error("Game server message dropped", lctx.Str("origin", msg.Origin), lctx.Err(err))
+		return false
+	}
+	g.proj.Apply(msg)
 
-		if msg.Type == MessageTypeExit {
-			return msg.Description, msg.Error
+	if msg.CausationID != "" {
+		log = log.With(lctx.Str("correlation", msg.CorrelationID), lctx.Str("causation", msg.CausationID))
+	}
+	if msg.Error != nil {
+		log = log.With(lctx.Err(msg.Error))
+	}
+	log.Info("Game server message received", lctx.Str("id", msg.ID))
+	return true
+}
+
+// deliver delivers a message to the inboxes of the subscribed consumers. A delivery blocked by a full inbox is given up
+// once the context is done.
+func deliver(ctx context.Context, inboxes []*inbox, msg Message) {
+	for _, i := range inboxes {
+		if !subscribed(i.subs, msg.Type) {
+			continue
 		}
+		clock.Hold(i.clock) // Until consumed, see inbox.consume.
+		if err := i.queue.AddContext(ctx, msg); err != nil {
+			clock.Release(i.clock)
+		}
+	}
+}
+
+// queueFor returns a queue for a message origin, that stamps each message before adding it to the message queue.
+//
+// A message keeps its ID if already set, e.g. to await its reply. A message without correlation starts a new chain.
+//
+// The queue is a Scheduler, scheduled messages are delivered until the context is done.
+func (g *GameServer) queueFor(ctx context.Context, origin string) Queue {
+	return schedQueue{
+		Queue: queueFn(func(m Message) {
+			if m.ID == "" {
+				m.ID = uuid.NewString()
+			}
+			if m.CorrelationID == "" {
+				m.CorrelationID = m.ID
+			}
+			m.Created = g.clock.Now()
+			m.Origin = origin
+			clock.Hold(g.clock) // Until dispatched, see dispatch.
+			if err := g.queue.AddContext(context.Background(), m); err != nil {
+				clock.Release(g.clock)
+			}
+		}),
+		ctx: ctx,
 	}
 }
 
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"maps"
 	"slices"
+
+	"github.com/antiphp/fakegameserver/clock"
 )
 
 const (
@@ -12,22 +14,42 @@ const (
 	MessageTypeHealthStatus MessageType = "healthStatus"
 )
 
+// EventHealthStatus is the event type for health status updates, with the health as payload.
+var EventHealthStatus = NewEventType[bool](MessageTypeHealthStatus)
+
 var (
-	_ Producer = (*HealthStatus)(nil)
-	_ Consumer = (*HealthStatus)(nil)
+	_ Publisher  = (*HealthStatus)(nil)
+	_ Subscriber = (*HealthStatus)(nil)
 )
 
 // HealthStatus consumes all messages and reports the overall health status of the game server.
+//
+// The health is the health of the last message of each type, as read from the projection.
 type HealthStatus struct {
+	proj     *Projection
+	apply    bool
 	waitFor  []MessageType
 	excludes []MessageType
-	ch       chan map[MessageType]error
+	clock    clock.Clock
+	ch       chan error
+	doneCh   chan struct{}
 }
 
-// NewHealthStatus returns a new health status reporter.
+// NewHealthStatus returns a new health status reporter, that projects the messages it consumes itself.
 func NewHealthStatus() *HealthStatus {
+	r := NewProjectedHealthStatus(NewProjection())
+	r.apply = true
+	return r
+}
+
+// NewProjectedHealthStatus returns a new health status reporter, that reads the errors of the messages from the
+// projection of the game server.
+func NewProjectedHealthStatus(proj *Projection) *HealthStatus {
 	return &HealthStatus{
-		ch: make(chan map[MessageType]error, 1),
+		proj:   proj,
+		clock:  clock.Real(),
+		ch:     make(chan error, 1),
+		doneCh: make(chan struct{}),
 	}
 }
 
@@ -46,47 +68,35 @@ func (r *HealthStatus) Close() {
 	close(r.ch)
 }
 
+// Publications returns the message types the health status reporter produces.
+func (r *HealthStatus) Publications() []MessageType {
+	return []MessageType{MessageTypeHealthStatus}
+}
+
 // Run runs the health status reporter.
 func (r *HealthStatus) Run(ctx context.Context, queue Queue) {
-	health := make(map[MessageType]error)
+	defer close(r.doneCh)
+
+	clk := clock.FromContext(ctx)
+
 	first := true
 	was := false
 	for {
-		var report map[MessageType]error
+		var err error
+		clock.Release(clk)
 		select {
 		case <-ctx.Done():
 			return
-		case report = <-r.ch:
-		}
-
-		for typ, err := range report {
-			r.waitFor = slices.DeleteFunc(r.waitFor, func(t MessageType) bool {
-				return t == typ && err == nil
-			})
-
-			health[typ] = err
+		case err = <-r.ch:
 		}
 
-		if len(r.waitFor) > 0 {
-			continue
-		}
-
-		is := isHealthy(health)
+		is := err == nil
 
 		switch {
 		case is && (first || !was):
-			queue.Add(Message{
-				Type:        MessageTypeHealthStatus,
-				Description: "Game server became healthy",
-				Payload:     true,
-			})
+			queue.Add(EventHealthStatus.Message("Game server became healthy", nil, true))
 		case !is && (first || was):
-			queue.Add(Message{
-				Type:        MessageTypeHealthStatus,
-				Description: "Game server became unhealthy",
-				Error:       errors.Join(slices.Collect(maps.Values(health))...),
-				Payload:     false,
-			})
+			queue.Add(EventHealthStatus.Message("Game server became unhealthy", err, false))
 		}
 
 		first = false
@@ -94,23 +104,40 @@ func (r *HealthStatus) Run(ctx context.Context, queue Queue) {
 	}
 }
 
+// Subscriptions returns the message types the health status reporter consumes.
+func (r *HealthStatus) Subscriptions() []MessageType {
+	return []MessageType{"*"}
+}
+
 // Consume consumes all messages.
 func (r *HealthStatus) Consume(msg Message) {
+	if r.apply {
+		r.proj.Apply(msg)
+	}
 	if slices.Contains(r.excludes, msg.Type) {
 		return
 	}
 
-	r.ch <- map[MessageType]error{
-		msg.Type: msg.Error,
+	r.waitFor = slices.DeleteFunc(r.waitFor, func(t MessageType) bool {
+		return t == msg.Type && msg.Error == nil
+	})
+	if len(r.waitFor) > 0 {
+		return
 	}
-}
 
-// isHealthy returns true if all reports are healthy.
-func isHealthy(reports map[MessageType]error) bool {
-	for _, err := range reports {
-		if err != nil {
-			return false
-		}
+	health := r.proj.Errors()
+	for _, typ := range r.excludes {
+		delete(health, typ)
+	}
+
+	clock.Hold(r.clock) // Until the health status is reported.
+	select {
+	case r.ch <- errors.Join(slices.Collect(maps.Values(health))...):
+	case <-r.doneCh: // Not running anymore.
+		clock.Release(r.clock)
 	}
-	return true
+}
+
+func (r *HealthStatus) setClock(c clock.Clock) {
+	r.clock = c
 }
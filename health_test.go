@@ -91,8 +91,10 @@ func TestHealthStatus(t *testing.T) {
 				health.Consume(msg)
 			}
 
-			cond := sync.NewCond(&sync.Mutex{})
-			var got []bool
+			var (
+				mu  sync.Mutex
+				got []bool
+			)
 			go func() {
 				for {
 					msg, shutdown := q.Get()
@@ -102,21 +104,38 @@ func TestHealthStatus(t *testing.T) {
 
 					require.Equal(t, fakegameserver.MessageTypeHealthStatus, msg.Type)
 
-					cond.L.Lock()
+					mu.Lock()
 					got = append(got, msg.Payload.(bool))
-					cond.Signal()
-					cond.L.Unlock()
+					mu.Unlock()
 				}
 			}()
 
 			retry.Run(t, func(t *retry.SubT) {
-				cond.L.Lock()
-				defer cond.L.Unlock()
-				cond.Wait()
+				mu.Lock()
+				defer mu.Unlock()
 
 				assert.Equal(t, test.want, got)
 			})
-
 		})
 	}
 }
+
+func TestProjectedHealthStatus(t *testing.T) {
+	q := queue.NewFifo[fakegameserver.Message]()
+	defer q.Shutdown()
+
+	proj := fakegameserver.NewProjection()
+	health := fakegameserver.NewProjectedHealthStatus(proj)
+	go health.Run(t.Context(), q)
+
+	// The game server applies messages to its projection before dispatching them.
+	for _, msg := range []fakegameserver.Message{{Type: "foo1", Error: errors.New("test")}, {Type: "foo2"}} {
+		proj.Apply(msg)
+	}
+	health.Consume(fakegameserver.Message{Type: "foo2"})
+
+	msg, shutdown := q.Get()
+	require.False(t, shutdown)
+	assert.Equal(t, false, msg.Payload)
+	assert.EqualError(t, msg.Error, "test")
+}
@@ -0,0 +1,203 @@
+package fakegameserver
+
+import (
+	"errors"
+	"math/rand/v2"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+// Deliver delivers a message to the consumers.
+type Deliver func(Message)
+
+// Interceptor intercepts the delivery of messages, e.g. to drop, delay or rewrite them.
+type Interceptor interface {
+	// Intercept intercepts a message. It delivers the message by calling next, which may be called any number of times,
+	// also later from another goroutine, or not at all to drop the message.
+	Intercept(msg Message, next Deliver)
+}
+
+// InterceptorFunc is a function that implements Interceptor.
+type InterceptorFunc func(Message, Deliver)
+
+// Intercept intercepts a message.
+func (fn InterceptorFunc) Intercept(msg Message, next Deliver) {
+	fn(msg, next)
+}
+
+// chain returns a delivery through the interceptors, the first interceptor intercepts first.
+func chain(interceptors []Interceptor, deliver Deliver) Deliver {
+	for _, i := range slices.Backward(interceptors) {
+		next := deliver
+		deliver = func(msg Message) {
+			i.Intercept(msg, next)
+		}
+	}
+	return deliver
+}
+
+// MessageMatcher matches messages.
+type MessageMatcher func(Message) bool
+
+// MatchMessage returns a matcher for messages of a type and origin. Both may contain the wildcard `*`, an empty origin
+// matches all origins.
+func MatchMessage(typ MessageType, origin string) MessageMatcher {
+	return func(msg Message) bool {
+		if !MatchMessageType(typ, msg.Type) {
+			return false
+		}
+		if origin == "" {
+			return true
+		}
+		ok, _ := path.Match(origin, msg.Origin)
+		return ok
+	}
+}
+
+// Drop drops matching messages with the given probability between 0 and 1.
+func Drop(match MessageMatcher, probability float64) Interceptor {
+	return InterceptorFunc(func(msg Message, next Deliver) {
+		if match(msg) && rand.Float64() < probability { //nolint:gosec // No need for secure randomness.
+			return
+		}
+		next(msg)
+	})
+}
+
+// clocked is an interceptor or handler that waits on the clock of the game server.
+type clocked interface {
+	setClock(c clock.Clock)
+}
+
+// Delay delays the delivery of matching messages, on the clock of the game server.
+//
+// Delayed messages are delivered out of order. They are not delivered anymore, once the game server exits.
+func Delay(match MessageMatcher, d time.Duration) Interceptor {
+	return &delay{match: match, d: d, clock: clock.Real()}
+}
+
+type delay struct {
+	match MessageMatcher
+	d     time.Duration
+	clock clock.Clock
+}
+
+func (i *delay) setClock(c clock.Clock) {
+	i.clock = c
+}
+
+func (i *delay) Intercept(msg Message, next Deliver) {
+	if !i.match(msg) {
+		next(msg)
+		return
+	}
+	i.clock.AfterFunc(i.d, func() {
+		next(msg)
+	})
+}
+
+// Duplicate delivers matching messages n additional times.
+func Duplicate(match MessageMatcher, n int) Interceptor {
+	return InterceptorFunc(func(msg Message, next Deliver) {
+		next(msg)
+		if !match(msg) {
+			return
+		}
+		for range n {
+			next(msg)
+		}
+	})
+}
+
+// Rewrite rewrites matching messages.
+func Rewrite(match MessageMatcher, fn func(Message) Message) Interceptor {
+	return InterceptorFunc(func(msg Message, next Deliver) {
+		if match(msg) {
+			msg = fn(msg)
+		}
+		next(msg)
+	})
+}
+
+// MessageCounter counts the delivered messages by type.
+type MessageCounter struct {
+	mu     sync.Mutex
+	counts map[MessageType]int64
+}
+
+// NewMessageCounter returns a new message counter.
+func NewMessageCounter() *MessageCounter {
+	return &MessageCounter{
+		counts: map[MessageType]int64{},
+	}
+}
+
+// Intercept counts a message.
+func (c *MessageCounter) Intercept(msg Message, next Deliver) {
+	c.mu.Lock()
+	c.counts[msg.Type]++
+	c.mu.Unlock()
+
+	next(msg)
+}
+
+// Counts returns the number of delivered messages by type.
+func (c *MessageCounter) Counts() map[MessageType]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[MessageType]int64, len(c.counts))
+	for typ, n := range c.counts {
+		counts[typ] = n
+	}
+	return counts
+}
+
+// ParseInterceptor parses a built-in interceptor, either `drop`, `delay` or `duplicate`.
+//
+// The spec has the format `<type>[@<origin>]=<value>`, e.g. `agonesUpdate=0.5` to drop half of the Agones state
+// updates, `agonesReportHealth=5s` to delay the health reports, or `agonesConnection@*AgonesWatcher=2` to deliver the
+// connection updates of the Agones watcher three times. Type and origin may contain the wildcard `*`.
+func ParseInterceptor(kind, spec string) (Interceptor, error) {
+	sel, val, ok := strings.Cut(spec, "=")
+	if !ok {
+		return nil, errors.New("invalid " + kind + " interceptor " + strconv.Quote(spec) + ", expected <type>[@<origin>]=<value>")
+	}
+	typ, origin, _ := strings.Cut(sel, "@")
+	if _, err := path.Match(typ, ""); err != nil || typ == "" {
+		return nil, errors.New("invalid message type " + strconv.Quote(typ))
+	}
+	if _, err := path.Match(origin, ""); err != nil {
+		return nil, errors.New("invalid origin " + strconv.Quote(origin))
+	}
+	match := MatchMessage(MessageType(typ), origin)
+
+	switch kind {
+	case "drop":
+		p, err := strconv.ParseFloat(val, 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, errors.New("invalid drop probability " + strconv.Quote(val) + ", expected 0 to 1")
+		}
+		return Drop(match, p), nil
+	case "delay":
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			return nil, errors.New("invalid delay " + strconv.Quote(val))
+		}
+		return Delay(match, d), nil
+	case "duplicate":
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, errors.New("invalid duplicate count " + strconv.Quote(val))
+		}
+		return Duplicate(match, n), nil
+	default:
+		return nil, errors.New("unknown interceptor " + strconv.Quote(kind) + ", expected drop, delay or duplicate")
+	}
+}
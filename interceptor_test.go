@@ -0,0 +1,105 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameServer_RunWithInterceptors(t *testing.T) {
+	counter := fakegameserver.NewMessageCounter()
+	recCh := make(chan fakegameserver.Message, 10)
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithInterceptor(
+			counter,
+			fakegameserver.Drop(fakegameserver.MatchMessage("agones*", ""), 1),
+			fakegameserver.Duplicate(fakegameserver.MatchMessage(fakegameserver.MessageTypeInfo, "*producerFunc"), 1),
+			fakegameserver.Delay(fakegameserver.MatchMessage(fakegameserver.MessageTypeExit, ""), 10*time.Millisecond),
+		),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeAgonesUpdate})
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo})
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "done"})
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		recCh <- msg
+	}))
+
+	reason, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reason)
+	close(recCh)
+	var got []fakegameserver.MessageType
+	for msg := range recCh {
+		got = append(got, msg.Type)
+	}
+	want := []fakegameserver.MessageType{fakegameserver.MessageTypeInfo, fakegameserver.MessageTypeInfo, fakegameserver.MessageTypeExit}
+	assert.Equal(t, want, got)
+	assert.Equal(t, map[fakegameserver.MessageType]int64{"agonesUpdate": 1, "info": 1, "exit": 1}, counter.Counts())
+}
+
+func TestParseInterceptor(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		spec    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles drop",
+			kind:    "drop",
+			spec:    "agonesUpdate@*AgonesWatcher=0.5",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles delay",
+			kind:    "delay",
+			spec:    "agonesReportHealth=5s",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles duplicate",
+			kind:    "duplicate",
+			spec:    "agones*=2",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles missing value",
+			kind:    "drop",
+			spec:    "agonesUpdate",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid probability",
+			kind:    "drop",
+			spec:    "agonesUpdate=2",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles unknown kind",
+			kind:    "rewrite",
+			spec:    "agonesUpdate=1",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := fakegameserver.ParseInterceptor(test.kind, test.spec)
+
+			test.wantErr(t, err)
+		})
+	}
+}
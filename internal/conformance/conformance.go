@@ -0,0 +1,312 @@
+// Package conformance checks an Agones SDK server against the documented behavior of the Agones SDK.
+package conformance
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+// The checks, in the order they run.
+const (
+	CheckReady    = "Ready is reflected in the watch stream"
+	CheckReserve  = "Reserve expires back to Ready"
+	CheckLabel    = "SetLabel shows up with the agones.dev/sdk- prefix"
+	CheckCounter  = "Counters stay within capacity"
+	CheckAllocate = "Allocate works from Ready"
+	CheckHealth   = "Health timeouts move the server to Unhealthy"
+)
+
+// LabelPrefix is the prefix Agones adds to the keys of labels set by the SDK.
+const LabelPrefix = "agones.dev/sdk-"
+
+// Status is the status of a check.
+type Status string
+
+// The statuses of a check.
+const (
+	StatusPass Status = "PASS"
+	StatusFail Status = "FAIL"
+	StatusSkip Status = "SKIP"
+)
+
+// Result is the result of a check.
+type Result struct {
+	Check    string
+	Status   Status
+	Detail   string
+	Duration time.Duration
+}
+
+// Config configures the checks.
+type Config struct {
+	// Timeout is the time a change may take to show up in the watch stream.
+	Timeout time.Duration
+	// Reserve is the duration to reserve the game server for.
+	Reserve time.Duration
+	// Counter is the counter to check, which must be defined on the game server. Empty skips the counter check.
+	Counter string
+	// HealthInterval is the interval of the health reports while checking. HealthTimeout is the time the game server may
+	// take to become Unhealthy once the health reports stop.
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+}
+
+// errSkip skips a check, with the error text as detail.
+type errSkip string
+
+func (e errSkip) Error() string { return string(e) }
+
+type check struct {
+	name     string
+	requires string
+	run      func(c *checker, ctx context.Context) (string, error)
+}
+
+var checks = []check{
+	{name: CheckReady, run: (*checker).checkReady},
+	{name: CheckReserve, requires: CheckReady, run: (*checker).checkReserve},
+	{name: CheckLabel, run: (*checker).checkLabel},
+	{name: CheckCounter, run: (*checker).checkCounter},
+	{name: CheckAllocate, requires: CheckReady, run: (*checker).checkAllocate},
+	{name: CheckHealth, run: (*checker).checkHealth},
+}
+
+// Checks returns the names of the checks, in the order they run.
+func Checks() []string {
+	names := make([]string, 0, len(checks))
+	for _, c := range checks {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Run runs the checks against the SDK server of the client, in order, and returns their results. The client must not
+// run yet, it is run until the checks are done.
+//
+// The checks change the game server, which ends up Allocated or Unhealthy. They must not be run against a game server
+// in use.
+func Run(ctx context.Context, client *agones.Client, cfg Config) []Result {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := &checker{
+		client:  client,
+		cfg:     cfg,
+		clock:   clock.FromContext(ctx),
+		notify:  make(chan struct{}),
+		healthy: make(chan struct{}),
+	}
+
+	results := make([]Result, 0, len(checks))
+	gs, err := client.GameServer(ctx)
+	if err != nil {
+		for _, chk := range checks {
+			results = append(results, Result{Check: chk.name, Status: StatusFail, Detail: "SDK server unreachable: " + err.Error()})
+		}
+		return results
+	}
+	c.update(gs)
+
+	go client.WatchGameServer(ctx, c.update)
+	go client.Run(ctx)
+	go c.reportHealth(ctx)
+
+	passed := map[string]bool{}
+	for _, chk := range checks {
+		if chk.requires != "" && !passed[chk.requires] {
+			results = append(results, Result{Check: chk.name, Status: StatusSkip, Detail: "requires " + strconv.Quote(chk.requires)})
+			continue
+		}
+
+		start := c.clock.Now()
+		detail, err := chk.run(c, ctx)
+		res := Result{Check: chk.name, Status: StatusPass, Detail: detail, Duration: c.clock.Since(start)}
+
+		var skip errSkip
+		switch {
+		case errors.As(err, &skip):
+			res.Status, res.Detail = StatusSkip, skip.Error()
+		case err != nil:
+			res.Status, res.Detail = StatusFail, err.Error()
+		}
+		passed[chk.name] = res.Status == StatusPass
+		results = append(results, res)
+	}
+	return results
+}
+
+// checker runs the checks, tracking the watched game server.
+type checker struct {
+	client *agones.Client
+	cfg    Config
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	gs      agones.GameServer
+	notify  chan struct{}
+	healthy chan struct{}
+}
+
+func (c *checker) update(gs agones.GameServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gs = gs
+	close(c.notify)
+	c.notify = make(chan struct{})
+}
+
+// waitFor waits until the watched game server matches, and returns it. It returns the last watched game server and an
+// error on timeout.
+func (c *checker) waitFor(ctx context.Context, timeout time.Duration, match func(agones.GameServer) bool) (agones.GameServer, error) {
+	timer := c.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		c.mu.Lock()
+		gs, notify := c.gs, c.notify
+		c.mu.Unlock()
+
+		if match(gs) {
+			return gs, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return gs, ctx.Err()
+		case <-timer.C():
+			return gs, errors.New("timed out after " + timeout.String())
+		case <-notify:
+		}
+	}
+}
+
+func (c *checker) waitForState(ctx context.Context, timeout time.Duration, state agones.State) error {
+	gs, err := c.waitFor(ctx, timeout, func(gs agones.GameServer) bool { return gs.State == state })
+	if err != nil {
+		return errors.New(string(state) + " not watched, state " + string(gs.State) + ": " + err.Error())
+	}
+	return nil
+}
+
+// reportHealth reports health until the health reports are stopped.
+func (c *checker) reportHealth(ctx context.Context) {
+	t := c.clock.NewTicker(c.cfg.HealthInterval)
+	defer t.Stop()
+
+	for {
+		_ = c.client.Health(ctx) // A failed health report fails the health check.
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.healthy:
+			return
+		case <-t.C():
+		}
+	}
+}
+
+func (c *checker) checkReady(ctx context.Context) (string, error) {
+	start := c.clock.Now()
+	if err := c.client.UpdateState(ctx, agones.StateReady); err != nil {
+		return "", err
+	}
+	if err := c.waitForState(ctx, c.cfg.Timeout, agones.StateReady); err != nil {
+		return "", err
+	}
+	return "watched after " + c.clock.Since(start).Round(time.Millisecond).String(), nil
+}
+
+func (c *checker) checkReserve(ctx context.Context) (string, error) {
+	if err := c.client.Reserve(ctx, c.cfg.Reserve); err != nil {
+		return "", err
+	}
+	if err := c.waitForState(ctx, c.cfg.Timeout, agones.StateReserved); err != nil {
+		return "", err
+	}
+
+	start := c.clock.Now()
+	if err := c.waitForState(ctx, c.cfg.Reserve+c.cfg.Timeout, agones.StateReady); err != nil {
+		return "", err
+	}
+	return "Ready again after " + c.clock.Since(start).Round(time.Millisecond).String() + ", reserved for " + c.cfg.Reserve.String(), nil
+}
+
+func (c *checker) checkLabel(ctx context.Context) (string, error) {
+	key, val := "fakegs-conformance", strconv.FormatInt(c.clock.Now().UnixNano(), 10)
+	if err := c.client.SetLabel(ctx, key, val); err != nil {
+		return "", err
+	}
+
+	gs, err := c.waitFor(ctx, c.cfg.Timeout, func(gs agones.GameServer) bool { return gs.Labels[LabelPrefix+key] == val })
+	if err != nil {
+		if gs.Labels[key] == val {
+			return "", errors.New("label " + key + " watched without prefix")
+		}
+		return "", errors.New("label " + LabelPrefix + key + " not watched: " + err.Error())
+	}
+	return "watched as " + LabelPrefix + key, nil
+}
+
+func (c *checker) checkCounter(ctx context.Context) (string, error) {
+	if c.cfg.Counter == "" {
+		return "", errSkip("no counter given")
+	}
+
+	counter, err := c.client.Counter(ctx, c.cfg.Counter)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = c.client.UpdateCounter(ctx, c.cfg.Counter, counter.Capacity-counter.Count+1); err == nil {
+		return "", errors.New("increment beyond capacity " + strconv.FormatInt(counter.Capacity, 10) + " accepted")
+	}
+	if _, err = c.client.UpdateCounter(ctx, c.cfg.Counter, -counter.Count-1); err == nil {
+		return "", errors.New("decrement below zero accepted")
+	}
+
+	after, err := c.client.Counter(ctx, c.cfg.Counter)
+	switch {
+	case err != nil:
+		return "", err
+	case after != counter:
+		return "", errors.New("count changed from " + strconv.FormatInt(counter.Count, 10) + " to " + strconv.FormatInt(after.Count, 10) +
+			" by rejected updates")
+	}
+	return "out of range updates rejected at " + strconv.FormatInt(counter.Count, 10) + " of " + strconv.FormatInt(counter.Capacity, 10), nil
+}
+
+func (c *checker) checkAllocate(ctx context.Context) (string, error) {
+	if err := c.waitForState(ctx, c.cfg.Timeout, agones.StateReady); err != nil {
+		return "", err
+	}
+
+	start := c.clock.Now()
+	if err := c.client.UpdateState(ctx, agones.StateAllocated); err != nil {
+		return "", err
+	}
+	if err := c.waitForState(ctx, c.cfg.Timeout, agones.StateAllocated); err != nil {
+		return "", err
+	}
+	return "watched after " + c.clock.Since(start).Round(time.Millisecond).String(), nil
+}
+
+func (c *checker) checkHealth(ctx context.Context) (string, error) {
+	if c.client.IsLocal() {
+		return "", errSkip("SDK server runs in local development mode, which does not check health")
+	}
+
+	close(c.healthy)
+	start := c.clock.Now()
+	if err := c.waitForState(ctx, c.cfg.HealthTimeout, agones.StateUnhealthy); err != nil {
+		return "", err
+	}
+	return "Unhealthy after " + c.clock.Since(start).Round(time.Millisecond).String() + " without health reports", nil
+}
@@ -0,0 +1,120 @@
+package conformance_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/fakegameservertest"
+	"github.com/antiphp/fakegameserver/internal/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(s *fakegameservertest.SDK)
+		counter string
+		want    map[string]conformance.Status
+	}{
+		{
+			name: "handles conforming SDK server",
+			setup: func(s *fakegameservertest.SDK) {
+				s.SetHealthTimeout(200 * time.Millisecond)
+				s.SetCounter("rooms", 2, 10)
+			},
+			counter: "rooms",
+			want: map[string]conformance.Status{
+				conformance.CheckReady:    conformance.StatusPass,
+				conformance.CheckReserve:  conformance.StatusPass,
+				conformance.CheckLabel:    conformance.StatusPass,
+				conformance.CheckCounter:  conformance.StatusPass,
+				conformance.CheckAllocate: conformance.StatusPass,
+				conformance.CheckHealth:   conformance.StatusPass,
+			},
+		},
+		{
+			name: "handles failed Ready",
+			setup: func(s *fakegameservertest.SDK) {
+				s.Respond(fakegameservertest.MethodReady, errors.New("unavailable"))
+				s.SetHealthTimeout(200 * time.Millisecond)
+			},
+			want: map[string]conformance.Status{
+				conformance.CheckReady:    conformance.StatusFail,
+				conformance.CheckReserve:  conformance.StatusSkip,
+				conformance.CheckLabel:    conformance.StatusPass,
+				conformance.CheckCounter:  conformance.StatusSkip,
+				conformance.CheckAllocate: conformance.StatusSkip,
+				conformance.CheckHealth:   conformance.StatusPass,
+			},
+		},
+		{
+			name: "handles missing health timeout",
+			setup: func(s *fakegameservertest.SDK) {
+				s.SetCounter("rooms", 0, 1)
+			},
+			counter: "players",
+			want: map[string]conformance.Status{
+				conformance.CheckReady:    conformance.StatusPass,
+				conformance.CheckReserve:  conformance.StatusPass,
+				conformance.CheckLabel:    conformance.StatusPass,
+				conformance.CheckCounter:  conformance.StatusFail,
+				conformance.CheckAllocate: conformance.StatusPass,
+				conformance.CheckHealth:   conformance.StatusFail,
+			},
+		},
+		{
+			name: "handles local development mode",
+			setup: func(s *fakegameservertest.SDK) {
+				s.SetLocal(true)
+			},
+			want: map[string]conformance.Status{
+				conformance.CheckReady:    conformance.StatusPass,
+				conformance.CheckReserve:  conformance.StatusPass,
+				conformance.CheckLabel:    conformance.StatusPass,
+				conformance.CheckCounter:  conformance.StatusSkip,
+				conformance.CheckAllocate: conformance.StatusPass,
+				conformance.CheckHealth:   conformance.StatusSkip,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			sdk := fakegameservertest.NewSDK()
+			test.setup(sdk)
+
+			got := conformance.Run(t.Context(), sdk.NewClient(), conformance.Config{
+				Timeout:        2 * time.Second,
+				Reserve:        time.Second,
+				Counter:        test.counter,
+				HealthInterval: 50 * time.Millisecond,
+				HealthTimeout:  time.Second,
+			})
+
+			require.Len(t, got, len(test.want))
+			for i, res := range got {
+				assert.Equal(t, conformance.Checks()[i], res.Check)
+				assert.Equal(t, test.want[res.Check], res.Status, "%s: %s", res.Check, res.Detail)
+			}
+		})
+	}
+}
+
+func TestRun_Unreachable(t *testing.T) {
+	sdk := fakegameservertest.NewSDK()
+	sdk.Respond(fakegameservertest.MethodGetGameServer, errors.New("connection refused"))
+
+	got := conformance.Run(t.Context(), sdk.NewClient(), conformance.Config{Timeout: time.Second})
+
+	require.Len(t, got, len(conformance.Checks()))
+	for _, res := range got {
+		assert.Equal(t, conformance.StatusFail, res.Status)
+		assert.Equal(t, "SDK server unreachable: getting game server: connection refused", res.Detail)
+	}
+	assert.Equal(t, agones.StateScheduled, sdk.GameServer().State)
+}
@@ -0,0 +1,204 @@
+// Package cron provides cron-style schedules.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a recurring schedule.
+type Schedule interface {
+	// Next returns the next activation time after the given time.
+	Next(time.Time) time.Time
+}
+
+// Every is a schedule that activates in a fixed interval.
+type Every time.Duration
+
+// Next returns the next activation time after the given time.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// Spec is a schedule of a cron expression with the fields minute, hour, day of month, month and day of week.
+type Spec struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar are set when the field is `*`. If both day fields are restricted, a day matches if either field
+	// matches, as in traditional cron.
+	domStar, dowStar bool
+}
+
+type bounds struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = bounds{min: 0, max: 59}
+	hours   = bounds{min: 0, max: 23}
+	doms    = bounds{min: 1, max: 31}
+	months  = bounds{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule.
+//
+// A schedule is either a cron expression with five fields, e.g. `0 4 * * *`, a macro like `@daily`, or a fixed interval
+// like `@every 10m`. Fields support lists, ranges, steps and the names of months and days of week.
+func Parse(s string) (Schedule, error) {
+	s = strings.TrimSpace(s)
+	if dur, ok := strings.CutPrefix(s, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(dur))
+		if err != nil {
+			return nil, fmt.Errorf("parsing interval: %w", err)
+		}
+		if d <= 0 {
+			return nil, errors.New("interval must be positive")
+		}
+		return Every(d), nil
+	}
+	if expr, ok := macros[s]; ok {
+		s = expr
+	}
+
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, expected 5 fields", s)
+	}
+
+	var (
+		spec Spec
+		err  error
+	)
+	if spec.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, fmt.Errorf("parsing minute: %w", err)
+	}
+	if spec.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, fmt.Errorf("parsing hour: %w", err)
+	}
+	if spec.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, fmt.Errorf("parsing day of month: %w", err)
+	}
+	if spec.month, err = parseField(fields[3], months); err != nil {
+		return nil, fmt.Errorf("parsing month: %w", err)
+	}
+	if spec.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, fmt.Errorf("parsing day of week: %w", err)
+	}
+	if spec.dow&(1<<7) != 0 { // Sunday is 0 or 7.
+		spec.dow |= 1
+	}
+	spec.domStar = fields[2] == "*" || fields[2] == "?"
+	spec.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return spec, nil
+}
+
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for part := range strings.SplitSeq(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		lo, hi := b.min, b.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(loStr, b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, b); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := parseValue(rng, b)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if !hasStep {
+				hi = v
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("invalid range %q", part)
+		}
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// Next returns the next activation time after the given time, or the zero time if there is none within five years.
+func (s Spec) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<t.Month()) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location()) // In wall clock time, unlike Truncate.
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Spec) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<t.Weekday()) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,111 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	from := time.Date(2025, time.March, 14, 10, 30, 0, 0, time.UTC) // Friday.
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+
+	tests := []struct {
+		name    string
+		spec    string
+		from    time.Time
+		want    time.Time
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles daily time",
+			spec:    "0 4 * * *",
+			want:    time.Date(2025, time.March, 15, 4, 0, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles daily time in half hour time zone",
+			spec:    "0 4 * * *",
+			from:    time.Date(2025, time.March, 14, 10, 30, 0, 0, ist),
+			want:    time.Date(2025, time.March, 15, 4, 0, 0, 0, ist),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles steps",
+			spec:    "*/10 * * * *",
+			want:    time.Date(2025, time.March, 14, 10, 40, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles ranges and names",
+			spec:    "0 9 * * mon-wed",
+			want:    time.Date(2025, time.March, 17, 9, 0, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles lists",
+			spec:    "15,45 10 * * *",
+			want:    time.Date(2025, time.March, 14, 10, 45, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles day of month or day of week",
+			spec:    "0 0 1 * sun",
+			want:    time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles sunday as 7",
+			spec:    "0 0 * * 7",
+			want:    time.Date(2025, time.March, 16, 0, 0, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles macros",
+			spec:    "@monthly",
+			want:    time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles intervals",
+			spec:    "@every 10m",
+			want:    time.Date(2025, time.March, 14, 10, 40, 0, 0, time.UTC),
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles invalid field count",
+			spec:    "0 4 * *",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles out of range values",
+			spec:    "60 * * * *",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid steps",
+			spec:    "*/0 * * * *",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			sched, err := Parse(test.spec)
+
+			test.wantErr(t, err)
+			if err != nil {
+				return
+			}
+			start := from
+			if !test.from.IsZero() {
+				start = test.from
+			}
+			assert.Equal(t, test.want, sched.Next(start))
+		})
+	}
+}
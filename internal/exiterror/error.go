@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -13,6 +14,13 @@ import (
 type ExitError struct {
 	names   []string
 	hookFns []func()
+	exits   []exit
+}
+
+// exit is how a hook exits the program.
+type exit struct {
+	code *int
+	sig  *int
 }
 
 // New creates a new exit error with a signal and/or exit code.
@@ -23,19 +31,40 @@ func New(code, sig *int) *ExitError {
 
 	exitErr := &ExitError{}
 	if sig != nil {
-		exitErr.addHook("signal "+strconv.Itoa(*sig), func() {
+		exitErr.addHook("signal "+strconv.Itoa(*sig), exit{sig: sig}, func() {
 			_ = syscall.Kill(os.Getpid(), syscall.Signal(*sig))
 			time.Sleep(5 * time.Second) // Brace for impact.
 		})
 	}
 	if code != nil {
-		exitErr.addHook("exit code "+strconv.Itoa(*code), func() {
+		exitErr.addHook("exit code "+strconv.Itoa(*code), exit{code: code}, func() {
 			os.Exit(*code)
 		})
 	}
 	return exitErr
 }
 
+// Parse parses an exit error from either `code:<code>` or `signal:<signal>`.
+func Parse(s string) (*ExitError, error) {
+	kind, val, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, errors.New("invalid exit " + strconv.Quote(s) + ", expected code:<code> or signal:<signal>")
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, errors.New("invalid exit " + strconv.Quote(s) + ", " + val + " is not a number")
+	}
+
+	switch kind {
+	case "code":
+		return New(&i, nil), nil
+	case "signal":
+		return New(nil, &i), nil
+	default:
+		return nil, errors.New("invalid exit " + strconv.Quote(s) + ", expected code:<code> or signal:<signal>")
+	}
+}
+
 func (e *ExitError) Error() string {
 	for _, how := range e.names {
 		return how
@@ -43,14 +72,36 @@ func (e *ExitError) Error() string {
 	return "exit error"
 }
 
-func (e *ExitError) addHook(name string, hookFn func()) {
+// Code returns the exit code of the program, as the first hook exits it. A signal is reported as 128 plus the signal,
+// as shells do. Without hooks, the exit code is 0.
+func (e *ExitError) Code() int {
+	if len(e.exits) == 0 {
+		return 0
+	}
+	if sig := e.exits[0].sig; sig != nil {
+		return 128 + *sig
+	}
+	return *e.exits[0].code
+}
+
+// Signal returns the signal the first hook sends, if any.
+func (e *ExitError) Signal() (int, bool) {
+	if len(e.exits) == 0 || e.exits[0].sig == nil {
+		return 0, false
+	}
+	return *e.exits[0].sig, true
+}
+
+func (e *ExitError) addHook(name string, ex exit, hookFn func()) {
 	e.hookFns = append(e.hookFns, hookFn)
 	e.names = append(e.names, name)
+	e.exits = append(e.exits, ex)
 }
 
-func (e *ExitError) addHooks(names []string, hookFns []func()) {
-	e.names = append(e.names, names...)
-	e.hookFns = append(e.hookFns, hookFns...)
+func (e *ExitError) addHooks(other *ExitError) {
+	e.names = append(e.names, other.names...)
+	e.hookFns = append(e.hookFns, other.hookFns...)
+	e.exits = append(e.exits, other.exits...)
 }
 
 // RunHooks executes all registered hooks in the order they were added.
@@ -71,7 +122,7 @@ func Wrap(errs ...error) *ExitError {
 		}
 		var exitErr *ExitError
 		if errors.As(err, &exitErr) && exitErr != nil {
-			retErr.addHooks(exitErr.names, exitErr.hookFns)
+			retErr.addHooks(exitErr)
 		}
 	}
 	return retErr
@@ -0,0 +1,101 @@
+// Package junit writes test results in the JUnit XML format, as read by CI systems.
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Suites are test suites.
+type Suites struct {
+	XMLName  xml.Name `xml:"testsuites"`
+	Tests    int      `xml:"tests,attr"`
+	Failures int      `xml:"failures,attr"`
+	Time     string   `xml:"time,attr"`
+	Suites   []Suite  `xml:"testsuite"`
+}
+
+// Suite is a test suite.
+type Suite struct {
+	Name      string `xml:"name,attr"`
+	Tests     int    `xml:"tests,attr"`
+	Failures  int    `xml:"failures,attr"`
+	Skipped   int    `xml:"skipped,attr,omitempty"`
+	Time      string `xml:"time,attr"`
+	Timestamp string `xml:"timestamp,attr,omitempty"`
+	Cases     []Case `xml:"testcase"`
+	SystemOut string `xml:"system-out,omitempty"`
+}
+
+// Case is a test case.
+type Case struct {
+	Name      string   `xml:"name,attr"`
+	Classname string   `xml:"classname,attr"`
+	Time      string   `xml:"time,attr"`
+	Failure   *Failure `xml:"failure,omitempty"`
+	Skipped   *Skipped `xml:"skipped,omitempty"`
+}
+
+// Failure is the failure of a test case.
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Skipped is the reason a test case was skipped.
+type Skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// NewSuite returns a new test suite of the test cases, counting the failures and skipped test cases.
+func NewSuite(name string, dur time.Duration, cases []Case) Suite {
+	s := Suite{
+		Name:  name,
+		Tests: len(cases),
+		Time:  Seconds(dur),
+		Cases: cases,
+	}
+	for _, c := range cases {
+		if c.Failure != nil {
+			s.Failures++
+		}
+		if c.Skipped != nil {
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+// Seconds formats a duration in seconds.
+func Seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// Write writes the test suites as JUnit XML.
+func Write(w io.Writer, suites []Suite) error {
+	all := Suites{Suites: suites}
+
+	var total float64
+	for _, s := range suites {
+		all.Tests += s.Tests
+		all.Failures += s.Failures
+
+		secs, _ := strconv.ParseFloat(s.Time, 64)
+		total += secs
+	}
+	all.Time = strconv.FormatFloat(total, 'f', 3, 64)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing header: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(all); err != nil {
+		return fmt.Errorf("encoding test suites: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
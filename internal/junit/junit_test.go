@@ -0,0 +1,49 @@
+package junit_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver/internal/junit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	suite := junit.NewSuite("allocation", 1500*time.Millisecond, []junit.Case{
+		{Name: "reaches Allocated", Classname: "allocation", Time: "0.000"},
+		{Name: "exits with code 3", Classname: "allocation", Time: "0.000", Failure: &junit.Failure{Message: "exited with code 0", Text: "<details>"}},
+	})
+	var buf bytes.Buffer
+
+	err := junit.Write(&buf, []junit.Suite{suite})
+
+	require.NoError(t, err)
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites tests="2" failures="1" time="1.500">
+  <testsuite name="allocation" tests="2" failures="1" time="1.500">
+    <testcase name="reaches Allocated" classname="allocation" time="0.000"></testcase>
+    <testcase name="exits with code 3" classname="allocation" time="0.000">
+      <failure message="exited with code 0">&lt;details&gt;</failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	assert.Equal(t, want, buf.String())
+}
+
+func TestNewSuite_Skipped(t *testing.T) {
+	suite := junit.NewSuite("conformance", time.Second, []junit.Case{
+		{Name: "Ready", Classname: "conformance", Time: "0.000"},
+		{Name: "Allocate", Classname: "conformance", Time: "0.000", Skipped: &junit.Skipped{Message: "requires Ready"}},
+	})
+	var buf bytes.Buffer
+
+	err := junit.Write(&buf, []junit.Suite{suite})
+
+	require.NoError(t, err)
+	assert.Equal(t, 1, suite.Skipped)
+	assert.Contains(t, buf.String(), `<testsuite name="conformance" tests="2" failures="0" skipped="1" time="1.000">`)
+	assert.Contains(t, buf.String(), `<skipped message="requires Ready"></skipped>`)
+}
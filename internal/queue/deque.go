@@ -0,0 +1,58 @@
+package queue
+
+// deque is a double-ended queue on a growable ring buffer.
+//
+// Removed items are zeroed, so the buffer does not keep references to them, and the buffer is reused instead of being
+// reallocated on each append.
+type deque[T any] struct {
+	buf  []T
+	head int
+	len  int
+}
+
+func (d *deque[T]) size() int {
+	return d.len
+}
+
+// at returns the i-th item from the front.
+func (d *deque[T]) at(i int) *T {
+	return &d.buf[(d.head+i)%len(d.buf)]
+}
+
+func (d *deque[T]) pushBack(v T) {
+	if d.len == len(d.buf) {
+		d.grow()
+	}
+	*d.at(d.len) = v
+	d.len++
+}
+
+func (d *deque[T]) popFront() T {
+	var zero T
+
+	p := d.at(0)
+	v := *p
+	*p = zero
+	d.head = (d.head + 1) % len(d.buf)
+	d.len--
+	return v
+}
+
+func (d *deque[T]) popBack() T {
+	var zero T
+
+	p := d.at(d.len - 1)
+	v := *p
+	*p = zero
+	d.len--
+	return v
+}
+
+func (d *deque[T]) grow() {
+	buf := make([]T, max(2*len(d.buf), 8))
+	n := copy(buf, d.buf[d.head:])
+	copy(buf[n:], d.buf[:d.head])
+
+	d.buf = buf
+	d.head = 0
+}
@@ -1,23 +1,118 @@
 // Package queue provides queue implementations.
 package queue
 
-import "sync"
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+)
 
-// Fifo is a first-in-first-out queue.
+// ErrStopped is returned when adding to a stopped queue.
+var ErrStopped = errors.New("queue stopped")
+
+// Policy is the overflow policy of a bounded queue.
+type Policy int
+
+const (
+	// PolicyBlock blocks until the queue has space.
+	PolicyBlock Policy = iota
+
+	// PolicyDropOldest drops the oldest item to make space.
+	PolicyDropOldest
+
+	// PolicyDropNewest drops the added item.
+	PolicyDropNewest
+
+	// PolicyCoalesce replaces a queued item with the same key. Without such an item, it blocks until the queue has
+	// space.
+	PolicyCoalesce
+)
+
+var policyNames = map[Policy]string{
+	PolicyBlock:      "block",
+	PolicyDropOldest: "drop-oldest",
+	PolicyDropNewest: "drop-newest",
+	PolicyCoalesce:   "coalesce",
+}
+
+// ParsePolicy parses an overflow policy.
+func ParsePolicy(s string) (Policy, error) {
+	for p, name := range policyNames {
+		if name == s {
+			return p, nil
+		}
+	}
+	return 0, errors.New("unknown queue policy " + strconv.Quote(s) + ", expected block, drop-oldest, drop-newest or coalesce")
+}
+
+func (p Policy) String() string {
+	if name, ok := policyNames[p]; ok {
+		return name
+	}
+	return "Policy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// Config configures a queue.
+type Config[T any] struct {
+	// Size is the maximum number of queued items. Zero means unbounded.
+	Size int
+
+	// Policy is the overflow policy, when the queue is full.
+	Policy Policy
+
+	// Key returns the coalescing key of an item. It is required for PolicyCoalesce.
+	Key func(T) string
+
+	// Lanes is the number of priority lanes. Zero means a single lane.
+	Lanes int
+
+	// Priority returns the priority lane of an item, from 0 (highest) to Lanes-1 (lowest). Items of a higher priority
+	// are retrieved first, the order within a lane is kept. Dropping policies only drop items of the lowest priority
+	// that is queued or added.
+	Priority func(T) int
+
+	// OnDrop is called with each dropped or coalesced item, with the lock of the queue held. It is optional.
+	OnDrop func(T)
+}
+
+// Fifo is a first-in-first-out queue, with optional priority lanes.
 type Fifo[T any] struct {
+	cfg     Config[T]
 	cond    *sync.Cond
-	queue   []T
+	space   *sync.Cond
+	lanes   []deque[T]
+	len     int
+	dropped int64
+	closed  bool
 	stopped bool
 }
 
-// NewFifo creates a new Fifo queue.
+// NewFifo creates a new unbounded Fifo queue.
 func NewFifo[T any]() *Fifo[T] {
+	return New(Config[T]{})
+}
+
+// New creates a new Fifo queue with the given configuration.
+func New[T any](cfg Config[T]) *Fifo[T] {
+	if cfg.Policy == PolicyCoalesce && cfg.Key == nil {
+		panic("queue: coalesce policy requires a key function") // Developer error.
+	}
+	if cfg.OnDrop == nil {
+		cfg.OnDrop = func(T) {}
+	}
+	if cfg.Priority == nil || cfg.Lanes < 1 {
+		cfg.Lanes = 1
+		cfg.Priority = func(T) int { return 0 }
+	}
+
 	mu := sync.Mutex{}
-	cond := sync.NewCond(&mu)
 
 	return &Fifo[T]{
-		cond:  cond,
-		queue: make([]T, 0),
+		cfg:   cfg,
+		cond:  sync.NewCond(&mu),
+		space: sync.NewCond(&mu),
+		lanes: make([]deque[T], cfg.Lanes),
 	}
 }
 
@@ -28,19 +123,124 @@ func (f *Fifo[T]) Shutdown() {
 
 	f.stopped = true
 	f.cond.Broadcast()
+	f.space.Broadcast()
+}
+
+// Close closes the queue for new items and wakes up all waiting goroutines. Queued items can still be retrieved, once
+// the queue is empty, Get reports it as stopped.
+func (f *Fifo[T]) Close() {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	f.closed = true
+	f.cond.Broadcast()
+	f.space.Broadcast()
 }
 
 // Add adds an item to the queue and wakes up one waiting goroutine.
+//
+// If the queue is full, the overflow policy applies.
 func (f *Fifo[T]) Add(v T) {
+	_ = f.AddContext(context.Background(), v)
+}
+
+// AddContext adds an item to the queue and wakes up one waiting goroutine.
+//
+// If the queue is full, the overflow policy applies. A blocked add returns the context error when the context is done.
+func (f *Fifo[T]) AddContext(ctx context.Context, v T) error {
 	f.cond.L.Lock()
 	defer f.cond.L.Unlock()
 
-	if f.stopped {
-		return
+	if f.stopped || f.closed {
+		return ErrStopped
+	}
+
+	lane := min(max(f.cfg.Priority(v), 0), f.cfg.Lanes-1)
+	if f.full() {
+		if f.cfg.Policy == PolicyCoalesce && f.coalesce(v) {
+			return nil
+		}
+
+		// Only drop items of the lowest priority that is queued or added.
+		lowest := max(lane, f.lowestLane())
+		switch {
+		case f.cfg.Policy == PolicyDropOldest && lowest == lane && f.lanes[lane].size() == 0,
+			f.cfg.Policy == PolicyDropNewest && lowest == lane:
+			f.dropped++
+			f.cfg.OnDrop(v)
+			return nil
+		case f.cfg.Policy == PolicyDropOldest:
+			f.cfg.OnDrop(f.lanes[lowest].popFront())
+			f.len--
+			f.dropped++
+		case f.cfg.Policy == PolicyDropNewest:
+			f.cfg.OnDrop(f.lanes[lowest].popBack())
+			f.len--
+			f.dropped++
+		default:
+			if err := f.waitSpace(ctx); err != nil {
+				return err
+			}
+		}
 	}
 
-	f.queue = append(f.queue, v)
+	f.lanes[lane].pushBack(v)
+	f.len++
 	f.cond.Signal()
+	return nil
+}
+
+// coalesce replaces a queued item with the same key as v. It returns false if there is no such item.
+func (f *Fifo[T]) coalesce(v T) bool {
+	key := f.cfg.Key(v)
+	for l := range f.lanes {
+		lane := &f.lanes[l]
+		for i := range lane.size() {
+			if item := lane.at(i); f.cfg.Key(*item) == key {
+				f.cfg.OnDrop(*item)
+				*item = v
+				f.dropped++
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lowestLane returns the lowest priority lane with queued items, or -1 if the queue is empty.
+func (f *Fifo[T]) lowestLane() int {
+	for i := len(f.lanes) - 1; i >= 0; i-- {
+		if f.lanes[i].size() > 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitSpace waits until the queue has space. It must be called with the lock held.
+func (f *Fifo[T]) waitSpace(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		f.cond.L.Lock()
+		defer f.cond.L.Unlock()
+
+		f.space.Broadcast()
+	})
+	defer stop()
+
+	for f.full() {
+		if f.stopped || f.closed {
+			return ErrStopped
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		f.space.Wait()
+	}
+	return nil
+}
+
+func (f *Fifo[T]) full() bool {
+	return f.cfg.Size > 0 && f.len >= f.cfg.Size
 }
 
 // Get retrieves an item from the queue.
@@ -50,14 +250,70 @@ func (f *Fifo[T]) Get() (T, bool) {
 	f.cond.L.Lock()
 	defer f.cond.L.Unlock()
 
-	if len(f.queue) == 0 && !f.stopped {
+	for f.len == 0 && !f.stopped && !f.closed {
 		f.cond.Wait()
 	}
-	if len(f.queue) == 0 {
+	if f.len == 0 {
 		var zero T
-		return zero, f.stopped
+		return zero, true
+	}
+
+	for i := range f.lanes {
+		if f.lanes[i].size() == 0 {
+			continue
+		}
+
+		val := f.lanes[i].popFront()
+		f.len--
+		f.space.Signal()
+		return val, f.stopped
+	}
+	panic("queue: inconsistent length") // Unreachable.
+}
+
+// GetN retrieves up to len(buf) items from the queue into buf, in the order Get retrieves them, and returns the number
+// of items.
+//
+// If the queue is empty, it waits until an item is added or the queue is stopped.
+func (f *Fifo[T]) GetN(buf []T) (int, bool) {
+	if len(buf) == 0 {
+		return 0, false
+	}
+
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	for f.len == 0 && !f.stopped && !f.closed {
+		f.cond.Wait()
+	}
+	if f.len == 0 {
+		return 0, true
+	}
+
+	var n int
+	for i := range f.lanes {
+		for n < len(buf) && f.lanes[i].size() > 0 {
+			buf[n] = f.lanes[i].popFront()
+			n++
+		}
 	}
-	val := f.queue[0]
-	f.queue = f.queue[1:]
-	return val, f.stopped
+	f.len -= n
+	f.space.Broadcast()
+	return n, f.stopped
+}
+
+// Len returns the number of queued items.
+func (f *Fifo[T]) Len() int {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	return f.len
+}
+
+// Dropped returns the number of dropped and coalesced items.
+func (f *Fifo[T]) Dropped() int64 {
+	f.cond.L.Lock()
+	defer f.cond.L.Unlock()
+
+	return f.dropped
 }
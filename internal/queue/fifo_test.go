@@ -1,7 +1,9 @@
 package queue
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -40,3 +42,216 @@ func TestFifo(t *testing.T) {
 	assert.Equal(t, 0, v)
 	assert.True(t, stopped)
 }
+
+func TestFifo_Bounded(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		add         []string
+		want        []string
+		wantDropped int64
+		wantOnDrop  []string
+	}{
+		{
+			name:        "handles drop oldest",
+			policy:      PolicyDropOldest,
+			add:         []string{"a1", "b1", "c1"},
+			want:        []string{"b1", "c1"},
+			wantDropped: 1,
+			wantOnDrop:  []string{"a1"},
+		},
+		{
+			name:        "handles drop newest",
+			policy:      PolicyDropNewest,
+			add:         []string{"a1", "b1", "c1"},
+			want:        []string{"a1", "b1"},
+			wantDropped: 1,
+			wantOnDrop:  []string{"c1"},
+		},
+		{
+			name:        "handles coalesce",
+			policy:      PolicyCoalesce,
+			add:         []string{"a1", "b1", "a2", "b2"},
+			want:        []string{"a2", "b2"},
+			wantDropped: 2,
+			wantOnDrop:  []string{"a1", "b1"},
+		},
+		{
+			name:   "handles coalesce with space left",
+			policy: PolicyCoalesce,
+			add:    []string{"a1", "a2"},
+			want:   []string{"a1", "a2"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var dropped []string
+			f := New(Config[string]{
+				Size:   2,
+				Policy: test.policy,
+				Key:    func(s string) string { return s[:1] },
+				OnDrop: func(s string) { dropped = append(dropped, s) },
+			})
+			for _, v := range test.add {
+				f.Add(v)
+			}
+
+			assert.Equal(t, len(test.want), f.Len())
+			assert.Equal(t, test.wantDropped, f.Dropped())
+			assert.Equal(t, test.wantOnDrop, dropped)
+
+			var got []string
+			for range test.want {
+				v, _ := f.Get()
+				got = append(got, v)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFifo_AddContextBlocks(t *testing.T) {
+	f := New(Config[int]{Size: 1, Policy: PolicyBlock})
+	f.Add(1)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	err := f.AddContext(ctx, 2)
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- f.AddContext(t.Context(), 3)
+	}()
+
+	v, _ := f.Get()
+
+	assert.Equal(t, 1, v)
+	assert.NoError(t, <-errCh)
+
+	v, _ = f.Get()
+
+	assert.Equal(t, 3, v)
+}
+
+func TestFifo_Priority(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		add         []string
+		want        []string
+		wantDropped int64
+	}{
+		{
+			name:   "handles priorities",
+			policy: PolicyBlock,
+			add:    []string{"b1", "b2", "a1", "b3", "a2"},
+			want:   []string{"a1", "a2", "b1", "b2", "b3"},
+		},
+		{
+			name:        "handles drop oldest of lowest priority",
+			policy:      PolicyDropOldest,
+			add:         []string{"b1", "a1", "a2", "a3", "a4", "a5", "b2"},
+			want:        []string{"a1", "a2", "a3", "a4", "a5"},
+			wantDropped: 2,
+		},
+		{
+			name:        "handles drop newest of lowest priority",
+			policy:      PolicyDropNewest,
+			add:         []string{"b1", "b2", "b3", "b4", "b5", "a1"},
+			want:        []string{"a1", "b1", "b2", "b3", "b4"},
+			wantDropped: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := New(Config[string]{
+				Size:   5,
+				Policy: test.policy,
+				Lanes:  2,
+				Priority: func(s string) int {
+					if s[0] == 'a' {
+						return 0
+					}
+					return 1
+				},
+			})
+			for _, v := range test.add {
+				f.Add(v)
+			}
+
+			assert.Equal(t, test.wantDropped, f.Dropped())
+
+			var got []string
+			for range test.want {
+				v, _ := f.Get()
+				got = append(got, v)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFifo_Close(t *testing.T) {
+	f := NewFifo[int]()
+	f.Add(1)
+
+	f.Close()
+	f.Add(2)
+
+	v, stopped := f.Get()
+
+	assert.Equal(t, 1, v)
+	assert.False(t, stopped)
+
+	v, stopped = f.Get()
+
+	assert.Equal(t, 0, v)
+	assert.True(t, stopped)
+}
+
+func TestFifo_GetN(t *testing.T) {
+	f := New(Config[int]{Lanes: 2, Priority: func(v int) int { return v % 2 }})
+	for i := range 5 {
+		f.Add(i)
+	}
+	f.Close()
+	buf := make([]int, 4)
+
+	n, stopped := f.GetN(buf)
+
+	assert.Equal(t, []int{0, 2, 4, 1}, buf[:n])
+	assert.False(t, stopped)
+
+	n, stopped = f.GetN(buf)
+
+	assert.Equal(t, []int{3}, buf[:n])
+	assert.False(t, stopped)
+
+	n, stopped = f.GetN(buf)
+
+	assert.Zero(t, n)
+	assert.True(t, stopped)
+}
+
+func TestFifo_ReleasesItems(t *testing.T) {
+	f := NewFifo[*int]()
+	for i := range 20 {
+		f.Add(&i)
+	}
+	for range 20 {
+		f.Get()
+	}
+
+	for _, v := range f.lanes[0].buf {
+		assert.Nil(t, v)
+	}
+}
@@ -0,0 +1,288 @@
+package queue
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Ring is a bounded, lock-free multi-producer multi-consumer queue on a ring buffer.
+//
+// Adding and retrieving items does not lock or allocate. Only a consumer waiting for items blocks, until an item is
+// added or the ring is closed, and a producer waiting for space, until an item is retrieved or the ring is closed.
+//
+// Unlike Fifo, the ring has no priority lanes and does not coalesce items.
+type Ring[T any] struct {
+	_    [cacheLine]byte
+	head atomic.Uint64 // Position of the next item to retrieve.
+	_    [cacheLine - 8]byte
+	tail atomic.Uint64 // Position of the next item to add.
+	_    [cacheLine - 8]byte
+
+	mask    uint64
+	slots   []slot[T]
+	policy  Policy
+	onDrop  func(T)
+	dropped atomic.Int64
+	waiting atomic.Int32
+	notify  chan struct{}
+	full    atomic.Int32 // Number of producers waiting for space.
+	space   chan struct{}
+	closed  atomic.Bool
+	done    chan struct{}
+}
+
+const cacheLine = 64
+
+// slot is a slot of the ring. Its sequence tells whether it is ready to be written (seq == pos) or read
+// (seq == pos+1) at a position.
+type slot[T any] struct {
+	seq atomic.Uint64
+	val T
+}
+
+// NewRing creates a new ring with at least the given size, applying the overflow policy when it is full. The size is
+// rounded up to a power of two, of at least two. As the ring has no keys to coalesce items by, PolicyCoalesce blocks like PolicyBlock.
+// The optional onDrop is called with each dropped item.
+func NewRing[T any](size int, policy Policy, onDrop func(T)) *Ring[T] {
+	if onDrop == nil {
+		onDrop = func(T) {}
+	}
+
+	n := uint64(1)
+	for n < uint64(max(size, 2)) { // A single slot could not tell a written from a read slot.
+		n <<= 1
+	}
+
+	r := &Ring[T]{
+		mask:   n - 1,
+		slots:  make([]slot[T], n),
+		policy: policy,
+		onDrop: onDrop,
+		notify: make(chan struct{}, 1),
+		space:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	for i := range r.slots {
+		r.slots[i].seq.Store(uint64(i))
+	}
+	return r
+}
+
+// Add adds an item to the ring.
+//
+// If the ring is full, the overflow policy applies.
+func (r *Ring[T]) Add(v T) {
+	_ = r.AddContext(context.Background(), v)
+}
+
+// AddContext adds an item to the ring.
+//
+// If the ring is full, the overflow policy applies. A blocked add returns the context error when the context is done.
+func (r *Ring[T]) AddContext(ctx context.Context, v T) error {
+	for {
+		if r.TryAdd(v) {
+			return nil
+		}
+		if r.closed.Load() {
+			return ErrStopped
+		}
+
+		switch r.policy {
+		case PolicyDropNewest:
+			r.dropped.Add(1)
+			r.onDrop(v)
+			return nil
+		case PolicyDropOldest:
+			if old, ok := r.TryGet(); ok {
+				r.dropped.Add(1)
+				r.onDrop(old)
+			}
+		default:
+			if err := r.waitSpace(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// TryAdd adds an item to the ring without waiting. It returns false if the ring is full or closed.
+func (r *Ring[T]) TryAdd(v T) bool {
+	if r.closed.Load() {
+		return false
+	}
+
+	for {
+		pos := r.tail.Load()
+		s := &r.slots[pos&r.mask]
+		switch seq := s.seq.Load(); {
+		case seq == pos:
+			if !r.tail.CompareAndSwap(pos, pos+1) {
+				continue
+			}
+			s.val = v
+			s.seq.Store(pos + 1)
+			r.wake()
+			return true
+		case seq < pos: // The slot is not read yet, the ring is full.
+			return false
+		}
+		// Another producer added at this position, retry.
+	}
+}
+
+// TryGet retrieves an item from the ring without waiting. It returns false if the ring is empty.
+func (r *Ring[T]) TryGet() (T, bool) {
+	var zero T
+	for {
+		pos := r.head.Load()
+		s := &r.slots[pos&r.mask]
+		switch seq := s.seq.Load(); {
+		case seq == pos+1:
+			if !r.head.CompareAndSwap(pos, pos+1) {
+				continue
+			}
+			v := s.val
+			s.val = zero
+			s.seq.Store(pos + r.mask + 1)
+			r.wakeProducer()
+			return v, true
+		case seq < pos+1: // The slot is not written yet, the ring is empty.
+			return zero, false
+		}
+		// Another consumer retrieved at this position, retry.
+	}
+}
+
+// Get retrieves an item from the ring.
+//
+// If the ring is empty, it waits until an item is added or the ring is closed. Once the ring is closed and empty, it
+// reports it as stopped.
+func (r *Ring[T]) Get() (T, bool) {
+	for {
+		if v, ok := r.TryGet(); ok {
+			return v, false
+		}
+		if !r.wait() {
+			v, ok := r.TryGet()
+			return v, !ok
+		}
+	}
+}
+
+// GetN retrieves up to len(buf) items from the ring into buf, and returns the number of items.
+//
+// If the ring is empty, it waits until an item is added or the ring is closed. Once the ring is closed and empty, it
+// reports it as stopped.
+func (r *Ring[T]) GetN(buf []T) (int, bool) {
+	if len(buf) == 0 {
+		return 0, false
+	}
+
+	v, stopped := r.Get()
+	if stopped {
+		return 0, true
+	}
+	buf[0] = v
+
+	n := 1
+	for ; n < len(buf); n++ {
+		v, ok := r.TryGet()
+		if !ok {
+			break
+		}
+		buf[n] = v
+	}
+	return n, false
+}
+
+// wait waits until an item may have been added. It returns false if the ring is closed.
+func (r *Ring[T]) wait() bool {
+	r.waiting.Add(1)
+	defer r.waiting.Add(-1)
+
+	// Re-check after announcing the wait, an item may have been added in between.
+	if r.Len() > 0 {
+		return true
+	}
+
+	select {
+	case <-r.notify:
+		if r.Len() > 1 { // Pass the wake-up on to another waiting consumer.
+			r.wake()
+		}
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+func (r *Ring[T]) wake() {
+	if r.waiting.Load() == 0 {
+		return
+	}
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// waitSpace waits until an item may have been retrieved. It returns an error if the ring is closed or the context is
+// done.
+func (r *Ring[T]) waitSpace(ctx context.Context) error {
+	r.full.Add(1)
+	defer r.full.Add(-1)
+
+	// Re-check after announcing the wait, an item may have been retrieved in between.
+	if r.Len() < r.Cap() {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-r.space:
+		if r.Len() < r.Cap()-1 { // Pass the wake-up on to another waiting producer.
+			r.wakeProducer()
+		}
+		return nil
+	case <-r.done:
+		return ErrStopped
+	}
+}
+
+func (r *Ring[T]) wakeProducer() {
+	if r.full.Load() == 0 {
+		return
+	}
+	select {
+	case r.space <- struct{}{}:
+	default:
+	}
+}
+
+// Close closes the ring for new items and wakes up all waiting consumers and producers. Queued items can still be
+// retrieved.
+func (r *Ring[T]) Close() {
+	if r.closed.CompareAndSwap(false, true) {
+		close(r.done)
+	}
+}
+
+// Len returns the number of queued items.
+func (r *Ring[T]) Len() int {
+	head, tail := r.head.Load(), r.tail.Load()
+	if tail < head { // The head moved on after the tail was loaded.
+		return 0
+	}
+	return int(tail - head) //nolint:gosec // Bounded by the size of the ring.
+}
+
+// Cap returns the capacity of the ring.
+func (r *Ring[T]) Cap() int {
+	return len(r.slots)
+}
+
+// Dropped returns the number of dropped items.
+func (r *Ring[T]) Dropped() int64 {
+	return r.dropped.Load()
+}
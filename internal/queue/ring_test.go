@@ -0,0 +1,278 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	r := NewRing[int](3, PolicyBlock, nil)
+
+	assert.Equal(t, 4, r.Cap())
+	for i := range 4 {
+		require.True(t, r.TryAdd(i))
+	}
+	assert.False(t, r.TryAdd(4), "full")
+
+	v, stopped := r.Get()
+
+	assert.Equal(t, 0, v)
+	assert.False(t, stopped)
+
+	require.True(t, r.TryAdd(4))
+	buf := make([]int, 10)
+
+	n, stopped := r.GetN(buf)
+
+	assert.Equal(t, []int{1, 2, 3, 4}, buf[:n])
+	assert.False(t, stopped)
+	assert.Equal(t, 0, r.Len())
+}
+
+func TestRing_Close(t *testing.T) {
+	r := NewRing[int](4, PolicyBlock, nil)
+	r.Add(1)
+
+	r.Close()
+
+	assert.False(t, r.TryAdd(2))
+	v, stopped := r.Get()
+	assert.Equal(t, 1, v)
+	assert.False(t, stopped)
+	_, stopped = r.Get()
+	assert.True(t, stopped)
+}
+
+func TestRing_Policies(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		want        []int
+		wantDropped int64
+		wantOnDrop  []int
+	}{
+		{
+			name:        "drop oldest",
+			policy:      PolicyDropOldest,
+			want:        []int{2, 3},
+			wantDropped: 2,
+			wantOnDrop:  []int{0, 1},
+		},
+		{
+			name:        "drop newest",
+			policy:      PolicyDropNewest,
+			want:        []int{0, 1},
+			wantDropped: 2,
+			wantOnDrop:  []int{2, 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var dropped []int
+			r := NewRing(2, test.policy, func(v int) { dropped = append(dropped, v) })
+
+			for i := range 4 {
+				r.Add(i)
+			}
+			r.Close()
+
+			buf := make([]int, 4)
+			n, _ := r.GetN(buf)
+			assert.Equal(t, test.want, buf[:n])
+			assert.Equal(t, test.wantDropped, r.Dropped())
+			assert.Equal(t, test.wantOnDrop, dropped)
+		})
+	}
+}
+
+func TestRing_BlockWaitsForSpace(t *testing.T) {
+	r := NewRing[int](1, PolicyBlock, nil)
+	r.Add(0)
+	r.Add(1)
+
+	assert.Equal(t, 2, r.Cap())
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, r.AddContext(ctx, 2), context.DeadlineExceeded)
+
+	doneCh := make(chan error)
+	go func() {
+		doneCh <- r.AddContext(t.Context(), 2)
+	}()
+	v, _ := r.Get()
+
+	assert.Equal(t, 0, v)
+	require.NoError(t, <-doneCh)
+	n, _ := r.GetN(make([]int, 2))
+	assert.Equal(t, 2, n)
+}
+
+func TestRing_CloseWakesConsumers(t *testing.T) {
+	r := NewRing[int](4, PolicyBlock, nil)
+
+	doneCh := make(chan bool)
+	go func() {
+		_, stopped := r.Get()
+		doneCh <- stopped
+	}()
+	r.Close()
+
+	assert.True(t, <-doneCh)
+}
+
+func TestRing_CloseDoesNotAllocate(t *testing.T) {
+	rings := make([]*Ring[int], 10)
+	for i := range rings {
+		rings[i] = NewRing[int](4, PolicyBlock, nil)
+	}
+
+	var i int
+	allocs := testing.AllocsPerRun(len(rings)-1, func() {
+		rings[i].Close()
+		i++
+	})
+
+	assert.Zero(t, allocs)
+}
+
+func TestRing_Concurrent(t *testing.T) {
+	const producers, consumers, items = 4, 4, 10000
+
+	r := NewRing[int](64, PolicyBlock, nil)
+
+	var sum, count atomic.Int64
+	var consumerWG sync.WaitGroup
+	for range consumers {
+		consumerWG.Add(1)
+		go func() {
+			defer consumerWG.Done()
+
+			buf := make([]int, 8)
+			for {
+				n, stopped := r.GetN(buf)
+				if stopped {
+					return
+				}
+				for _, v := range buf[:n] {
+					sum.Add(int64(v))
+				}
+				count.Add(int64(n))
+			}
+		}()
+	}
+
+	var producerWG sync.WaitGroup
+	for range producers {
+		producerWG.Add(1)
+		go func() {
+			defer producerWG.Done()
+
+			for i := 1; i <= items; i++ {
+				r.Add(i) // Blocks while full.
+			}
+		}()
+	}
+	producerWG.Wait()
+	r.Close()
+	consumerWG.Wait()
+
+	assert.Equal(t, int64(producers*items), count.Load())
+	assert.Equal(t, int64(producers*items*(items+1)/2), sum.Load())
+}
+
+func BenchmarkFifo_AddGet(b *testing.B) {
+	f := NewFifo[int]()
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		f.Add(i)
+		f.Get()
+	}
+}
+
+func BenchmarkRing_AddGet(b *testing.B) {
+	r := NewRing[int](1024, PolicyBlock, nil)
+
+	b.ReportAllocs()
+	for i := 0; b.Loop(); i++ {
+		r.Add(i)
+		r.Get()
+	}
+}
+
+func BenchmarkFifo_Burst(b *testing.B) {
+	f := NewFifo[int]()
+
+	b.ReportAllocs()
+	for b.Loop() {
+		for i := range 1000 {
+			f.Add(i)
+		}
+		for range 1000 {
+			f.Get()
+		}
+	}
+}
+
+func BenchmarkRing_Burst(b *testing.B) {
+	r := NewRing[int](1024, PolicyBlock, nil)
+	buf := make([]int, 64)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		for i := range 1000 {
+			r.Add(i)
+		}
+		for n := 0; n < 1000; {
+			got, _ := r.GetN(buf)
+			n += got
+		}
+	}
+}
+
+func BenchmarkFifo_Parallel(b *testing.B) {
+	f := NewFifo[int]()
+	go func() {
+		for {
+			if _, stopped := f.Get(); stopped {
+				return
+			}
+		}
+	}()
+	defer f.Shutdown()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			f.Add(i)
+		}
+	})
+}
+
+func BenchmarkRing_Parallel(b *testing.B) {
+	r := NewRing[int](1024, PolicyBlock, nil)
+	go func() {
+		buf := make([]int, 64)
+		for {
+			if _, stopped := r.GetN(buf); stopped {
+				return
+			}
+		}
+	}()
+	defer r.Close()
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			r.Add(i)
+		}
+	})
+}
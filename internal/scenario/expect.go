@@ -0,0 +1,206 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+)
+
+// Expectation is an expectation of a scenario. Exactly one of State, ExitCode, ExitSignal or Messages is set.
+type Expectation struct {
+	// State expects the game server to reach the Agones state, within the duration after the start or the request if set.
+	//
+	// The request of a state is the first step setting it, or else the first request of the game server to update to it.
+	State  agones.State `json:"state,omitempty"`
+	Within *Duration    `json:"within,omitempty"`
+	After  string       `json:"after,omitempty"`
+
+	// ExitCode expects the game server to exit with the code.
+	ExitCode *int `json:"exitCode,omitempty"`
+	// ExitSignal expects the game server to exit with the signal, e.g. 15 for SIGTERM.
+	ExitSignal *int `json:"exitSignal,omitempty"`
+
+	// Messages expects the number of messages of the type, which may contain the wildcard `*`, to be exactly, at least
+	// and/or at most the given counts.
+	Messages fakegameserver.MessageType `json:"messages,omitempty"`
+	Exactly  *int                       `json:"exactly,omitempty"`
+	AtLeast  *int                       `json:"atLeast,omitempty"`
+	AtMost   *int                       `json:"atMost,omitempty"`
+}
+
+// The references of the within duration of an expected state.
+const (
+	AfterStart   = "start"
+	AfterRequest = "request"
+)
+
+func (e Expectation) validate() error {
+	var n int
+	for _, set := range []bool{e.State != "", e.ExitCode != nil, e.ExitSignal != nil, e.Messages != ""} {
+		if set {
+			n++
+		}
+	}
+	switch {
+	case n != 1:
+		return errors.New("expected exactly one of state, exitCode, exitSignal or messages")
+	case e.After != "" && e.After != AfterStart && e.After != AfterRequest:
+		return fmt.Errorf("invalid after %q, expected %q or %q", e.After, AfterStart, AfterRequest)
+	case e.Messages != "" && e.Exactly == nil && e.AtLeast == nil && e.AtMost == nil:
+		return errors.New("expected exactly, atLeast or atMost for messages")
+	}
+	if e.State != "" {
+		if _, err := agones.ParseState(string(e.State)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Name returns the readable name of the expectation, e.g. `reaches Allocated within 2s of request`.
+func (e Expectation) Name() string {
+	switch {
+	case e.State != "":
+		name := "reaches " + string(e.State)
+		if e.Within != nil {
+			name += " within " + time.Duration(*e.Within).String() + " of " + e.after()
+		}
+		return name
+	case e.ExitCode != nil:
+		return "exits with code " + strconv.Itoa(*e.ExitCode)
+	case e.ExitSignal != nil:
+		return "exits with signal " + strconv.Itoa(*e.ExitSignal)
+	}
+
+	name := "emits"
+	if e.Exactly != nil {
+		name += " exactly " + strconv.Itoa(*e.Exactly)
+	}
+	if e.AtLeast != nil {
+		name += " at least " + strconv.Itoa(*e.AtLeast)
+	}
+	if e.AtMost != nil {
+		if e.AtLeast != nil {
+			name += " and"
+		}
+		name += " at most " + strconv.Itoa(*e.AtMost)
+	}
+	return name + " " + string(e.Messages)
+}
+
+func (e Expectation) after() string {
+	if e.After == "" {
+		return AfterStart
+	}
+	return e.After
+}
+
+// Result is the result of an expectation.
+type Result struct {
+	Name string
+	// Failure is the reason the expectation failed, if it failed.
+	Failure string
+}
+
+// Evaluate evaluates the expectations of the scenario against the run.
+func (s *Scenario) Evaluate(run Run) []Result {
+	res := make([]Result, 0, len(s.Expect))
+	for _, e := range s.Expect {
+		res = append(res, Result{Name: e.Name(), Failure: s.evaluate(e, run)})
+	}
+	return res
+}
+
+func (s *Scenario) evaluate(e Expectation, run Run) string {
+	switch {
+	case e.State != "":
+		return s.evaluateState(e, run)
+	case e.ExitCode != nil:
+		switch {
+		case !run.Exited:
+			return "did not exit within " + time.Duration(s.Limit).String()
+		case run.Code != *e.ExitCode:
+			return "exited with code " + strconv.Itoa(run.Code)
+		}
+		return ""
+	case e.ExitSignal != nil:
+		switch {
+		case !run.Exited:
+			return "did not exit within " + time.Duration(s.Limit).String()
+		case run.Signal == 0:
+			return "exited without signal, with code " + strconv.Itoa(run.Code)
+		case run.Signal != *e.ExitSignal:
+			return "exited with signal " + strconv.Itoa(run.Signal)
+		}
+		return ""
+	}
+
+	var n int
+	for _, msg := range run.Messages {
+		if fakegameserver.MatchMessageType(e.Messages, msg.Type) {
+			n++
+		}
+	}
+	if (e.Exactly != nil && n != *e.Exactly) || (e.AtLeast != nil && n < *e.AtLeast) || (e.AtMost != nil && n > *e.AtMost) {
+		return "emitted " + strconv.Itoa(n) + " " + string(e.Messages)
+	}
+	return ""
+}
+
+func (s *Scenario) evaluateState(e Expectation, run Run) string {
+	proj := fakegameserver.NewProjection()
+
+	var (
+		reached   time.Duration
+		ok        bool
+		requested = time.Duration(-1)
+	)
+	for _, step := range s.Steps {
+		if step.State == e.State {
+			requested = time.Duration(step.At)
+			break
+		}
+	}
+	for _, msg := range run.Messages {
+		at := msg.Created.Sub(run.Start)
+		if state, isReq := fakegameserver.EventAgonesRequestUpdate.Payload(msg); isReq && state == e.State && requested < 0 {
+			requested = at
+		}
+
+		proj.Apply(msg)
+		if proj.State() == e.State {
+			reached, ok = at, true
+			break
+		}
+	}
+
+	if !ok {
+		return "did not reach " + string(e.State) + ", last state " + stateName(proj.State())
+	}
+	if e.Within == nil {
+		return ""
+	}
+
+	from := time.Duration(0)
+	if e.after() == AfterRequest {
+		if requested < 0 {
+			return string(e.State) + " was not requested"
+		}
+		from = requested
+	}
+	if took := reached - from; took > time.Duration(*e.Within) {
+		return "reached " + string(e.State) + " after " + took.String()
+	}
+	return ""
+}
+
+func stateName(state agones.State) string {
+	if state == "" {
+		return "none"
+	}
+	return string(state)
+}
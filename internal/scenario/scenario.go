@@ -0,0 +1,201 @@
+// Package scenario runs scenarios of a fake game server under virtual time and checks their expectations.
+package scenario
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/fakegameservertest"
+)
+
+// Scenario is a scenario of a fake game server.
+type Scenario struct {
+	// Name is the name of the scenario. It defaults to the file name.
+	Name string `json:"name"`
+	// Start is the virtual time the scenario starts at, e.g. for exit schedules. It defaults to 2025-01-01T00:00:00Z.
+	Start time.Time `json:"start"`
+	// Args are the flags of the fakegs to run the scenario with, e.g. `--ready-after=1s`.
+	Args []string `json:"args"`
+	// Limit is the virtual time the game server has to exit within. It defaults to 1h.
+	Limit Duration `json:"limit"`
+	// Resolution is the resolution of the timeline offsets. It defaults to 1s.
+	Resolution Duration `json:"resolution"`
+	// Steps are the changes to the game server, as done by the Agones controller.
+	Steps []Step `json:"steps"`
+	// Expect are the expectations of the scenario.
+	Expect []Expectation `json:"expect"`
+	// Timeline is the expected timeline of the message types it contains, see Run.Timeline.
+	Timeline []string `json:"timeline"`
+}
+
+// Step is a change to the game server at an offset, as done by the Agones controller. Exactly one change is set.
+type Step struct {
+	At Duration `json:"at"`
+
+	// State sets the Agones state, e.g. `Allocated` for an allocation.
+	State agones.State `json:"state,omitempty"`
+	// Counter sets the count and capacity of a counter.
+	Counter  string `json:"counter,omitempty"`
+	Count    int64  `json:"count,omitempty"`
+	Capacity int64  `json:"capacity,omitempty"`
+	// Label sets a label to the value.
+	Label string `json:"label,omitempty"`
+	Value string `json:"value,omitempty"`
+	// Disconnect ends all watches, as if the connection to the SDK server was lost.
+	Disconnect bool `json:"disconnect,omitempty"`
+	// Fail fails the next calls of an SDK method, e.g. `Ready`, the given number of times (default 1).
+	Fail  fakegameservertest.Method `json:"fail,omitempty"`
+	Times int                       `json:"times,omitempty"`
+}
+
+// Load loads a scenario from a JSON file.
+func Load(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path) //nolint:gosec // Reading the given scenario is intended.
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+
+	s, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".json")
+	}
+	return s, nil
+}
+
+// Parse parses a scenario from JSON.
+func Parse(b []byte) (*Scenario, error) {
+	var s Scenario
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	if s.Start.IsZero() {
+		s.Start = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if s.Limit == 0 {
+		s.Limit = Duration(time.Hour)
+	}
+	if s.Resolution == 0 {
+		s.Resolution = Duration(time.Second)
+	}
+
+	for i, step := range s.Steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	for i, exp := range s.Expect {
+		if err := exp.validate(); err != nil {
+			return nil, fmt.Errorf("expectation %d: %w", i+1, err)
+		}
+	}
+	return &s, nil
+}
+
+func (s Step) validate() error {
+	var n int
+	for _, set := range []bool{s.State != "", s.Counter != "", s.Label != "", s.Disconnect, s.Fail != ""} {
+		if set {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("expected exactly one of state, counter, label, disconnect or fail")
+	}
+	if s.State != "" {
+		if _, err := agones.ParseState(string(s.State)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Schedule schedules the steps on the clock, to be applied to the SDK.
+func (s *Scenario) Schedule(clk clock.Clock, sdk *fakegameservertest.SDK) {
+	for _, step := range s.Steps {
+		clk.AfterFunc(time.Duration(step.At), func() {
+			step.apply(sdk)
+		})
+	}
+}
+
+func (s Step) apply(sdk *fakegameservertest.SDK) {
+	switch {
+	case s.State != "":
+		sdk.SetState(s.State)
+	case s.Counter != "":
+		sdk.SetCounter(s.Counter, s.Count, s.Capacity)
+	case s.Label != "":
+		sdk.SetLabel(s.Label, s.Value)
+	case s.Disconnect:
+		sdk.Disconnect()
+	case s.Fail != "":
+		errs := make([]error, max(s.Times, 1))
+		for i := range errs {
+			errs[i] = errors.New("scripted failure of " + string(s.Fail))
+		}
+		sdk.Respond(s.Fail, errs...)
+	}
+}
+
+// String describes the step, e.g. `set state Allocated`.
+func (s Step) String() string {
+	switch {
+	case s.State != "":
+		return "set state " + string(s.State)
+	case s.Counter != "":
+		return "set counter " + s.Counter + " to " + strconv.FormatInt(s.Count, 10) + " of " + strconv.FormatInt(s.Capacity, 10)
+	case s.Label != "":
+		return "set label " + s.Label + " to " + strconv.Quote(s.Value)
+	case s.Disconnect:
+		return "disconnect"
+	}
+	return "fail " + string(s.Fail) + " " + strconv.Itoa(max(s.Times, 1)) + " times"
+}
+
+// Duration is a duration in JSON, e.g. `1m30s`.
+type Duration time.Duration
+
+// UnmarshalJSON unmarshals a duration string.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return fmt.Errorf("invalid duration %s, expected a string like \"1m30s\"", b)
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(dur)
+	return nil
+}
+
+// MarshalJSON marshals the duration as string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Duration(d).String())), nil
+}
+
+// Run is the run of a scenario.
+type Run struct {
+	// Start is the virtual time the run started at.
+	Start time.Time
+	// Messages are the messages of the game server.
+	Messages []fakegameserver.Message
+	// Exited is true if the game server exited within the limit.
+	Exited bool
+	// Code is the exit code, and Signal the exit signal if any.
+	Code   int
+	Signal int
+	// Elapsed is the elapsed virtual time.
+	Elapsed time.Duration
+}
@@ -0,0 +1,169 @@
+package scenario_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/scenario"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	s, err := scenario.Parse([]byte(`{
+		"args": ["--ready-after=10s"],
+		"steps": [{"at": "1m", "state": "Allocated"}],
+		"expect": [{"state": "Allocated", "within": "2s", "after": "request"}]
+	}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--ready-after=10s"}, s.Args)
+	assert.Equal(t, scenario.Duration(time.Hour), s.Limit)
+	assert.Equal(t, scenario.Duration(time.Second), s.Resolution)
+	assert.Equal(t, scenario.Duration(time.Minute), s.Steps[0].At)
+	assert.Equal(t, "reaches Allocated within 2s of request", s.Expect[0].Name())
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{
+			name:    "duration",
+			json:    `{"limit": 60}`,
+			wantErr: `invalid duration 60, expected a string like "1m30s"`,
+		},
+		{
+			name:    "step without change",
+			json:    `{"steps": [{"at": "1m"}]}`,
+			wantErr: "step 1: expected exactly one of state, counter, label, disconnect or fail",
+		},
+		{
+			name:    "step with unknown state",
+			json:    `{"steps": [{"at": "1m", "state": "Sleeping"}]}`,
+			wantErr: "step 1: unknown state: Sleeping",
+		},
+		{
+			name:    "expectation with unknown state",
+			json:    `{"expect": [{"state": "Sleeping"}]}`,
+			wantErr: "expectation 1: unknown state: Sleeping",
+		},
+		{
+			name:    "expectation with two checks",
+			json:    `{"expect": [{"state": "Ready", "exitCode": 0}]}`,
+			wantErr: "expectation 1: expected exactly one of state, exitCode, exitSignal or messages",
+		},
+		{
+			name:    "expectation with invalid after",
+			json:    `{"expect": [{"state": "Ready", "after": "allocation"}]}`,
+			wantErr: `expectation 1: invalid after "allocation", expected "start" or "request"`,
+		},
+		{
+			name:    "messages without count",
+			json:    `{"expect": [{"messages": "exit"}]}`,
+			wantErr: "expectation 1: expected exactly, atLeast or atMost for messages",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := scenario.Parse([]byte(test.json))
+
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestScenario_Evaluate(t *testing.T) {
+	s, err := scenario.Parse([]byte(`{
+		"steps": [{"at": "1m", "state": "Allocated"}],
+		"expect": [
+			{"state": "Ready", "within": "10s"},
+			{"state": "Allocated", "within": "2s", "after": "request"},
+			{"state": "Shutdown"},
+			{"exitCode": 3},
+			{"exitSignal": 15},
+			{"messages": "agonesReportHealth", "exactly": 2},
+			{"messages": "agones*", "atLeast": 1, "atMost": 3}
+		]
+	}`))
+	require.NoError(t, err)
+	run := newRun(t)
+
+	got := s.Evaluate(run)
+
+	want := []scenario.Result{
+		{Name: "reaches Ready within 10s of start", Failure: "reached Ready after 20s"},
+		{Name: "reaches Allocated within 2s of request"},
+		{Name: "reaches Shutdown", Failure: "did not reach Shutdown, last state Allocated"},
+		{Name: "exits with code 3"},
+		{Name: "exits with signal 15", Failure: "exited without signal, with code 3"},
+		{Name: "emits exactly 2 agonesReportHealth"},
+		{Name: "emits at least 1 and at most 3 agones*", Failure: "emitted 4 agones*"},
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestScenario_Diff(t *testing.T) {
+	s, err := scenario.Parse([]byte(`{
+		"timeline": [
+			"20s agonesGameServer: Received Ready",
+			"1m0s agonesGameServer: Received Shutdown",
+			"1m1s exit: Exit"
+		]
+	}`))
+	require.NoError(t, err)
+	run := newRun(t)
+
+	got := s.Diff(run)
+
+	want := []string{
+		"  20s agonesGameServer: Received Ready",
+		"- 1m0s agonesGameServer: Received Shutdown",
+		"+ 1m1s agonesGameServer: Received Allocated",
+		"  1m1s exit: Exit",
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestRun_Timeline(t *testing.T) {
+	run := newRun(t)
+
+	got := run.Timeline(time.Minute)
+
+	want := []string{
+		"0s agonesReportHealth: Health reported",
+		"0s agonesGameServer: Received Ready",
+		"0s agonesReportHealth: Health reported (test)",
+		"1m0s agonesGameServer: Received Allocated",
+		"1m0s exit: Exit",
+	}
+	assert.Equal(t, want, got)
+}
+
+func newRun(t *testing.T) scenario.Run {
+	t.Helper()
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(d time.Duration, msg fakegameserver.Message) fakegameserver.Message {
+		msg.Created = start.Add(d)
+		return msg
+	}
+	return scenario.Run{
+		Start: start,
+		Messages: []fakegameserver.Message{
+			at(0, fakegameserver.EventAgonesReportHealth.Message("Health reported", nil, true)),
+			at(20*time.Second, fakegameserver.EventAgonesGameServer.Message("Received Ready", nil, agones.GameServer{State: agones.StateReady})),
+			at(30*time.Second, fakegameserver.EventAgonesReportHealth.Message("Health reported", errors.New("test"), false)),
+			at(61*time.Second, fakegameserver.EventAgonesGameServer.Message("Received Allocated", nil, agones.GameServer{State: agones.StateAllocated})),
+			at(61*time.Second, fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "Exit"}),
+		},
+		Exited: true,
+		Code:   3,
+	}
+}
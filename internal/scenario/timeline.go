@@ -0,0 +1,92 @@
+package scenario
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Timeline returns the timeline of the run, one line per message, e.g. `1m0s agonesUpdate: Updated state to Ready`.
+//
+// The offsets are truncated to the resolution, so that jitter, e.g. of reconnects, does not show.
+func (r Run) Timeline(resolution time.Duration) []string {
+	lines := make([]string, 0, len(r.Messages))
+	for _, msg := range r.Messages {
+		off := msg.Created.Sub(r.Start).Truncate(resolution)
+
+		line := off.String() + " " + string(msg.Type) + ": " + msg.Description
+		if msg.Error != nil {
+			line += " (" + msg.Error.Error() + ")"
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Diff returns the diff of the expected timeline of the scenario to the timeline of the run, or nil if they equal.
+//
+// Only the message types contained in the expected timeline are compared. Lines are prefixed with `-` if expected
+// but missing, with `+` if unexpected, and with a space otherwise.
+func (s *Scenario) Diff(run Run) []string {
+	if len(s.Timeline) == 0 {
+		return nil
+	}
+
+	types := map[string]bool{}
+	for _, line := range s.Timeline {
+		types[lineType(line)] = true
+	}
+	var got []string
+	for _, line := range run.Timeline(time.Duration(s.Resolution)) {
+		if types[lineType(line)] {
+			got = append(got, line)
+		}
+	}
+	if slices.Equal(s.Timeline, got) {
+		return nil
+	}
+	return diff(s.Timeline, got)
+}
+
+// lineType returns the message type of a timeline line.
+func lineType(line string) string {
+	_, rest, _ := strings.Cut(line, " ")
+	typ, _, _ := strings.Cut(rest, ":")
+	return typ
+}
+
+// diff returns the line diff of a to b, based on their longest common subsequence.
+func diff(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var (
+		lines []string
+		i, j  int
+	)
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			lines = append(lines, "+ "+b[j])
+			j++
+		default:
+			lines = append(lines, "- "+a[i])
+			i++
+		}
+	}
+	return lines
+}
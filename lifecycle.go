@@ -0,0 +1,261 @@
+package fakegameserver
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/antiphp/fakegameserver/clock"
+	lctx "github.com/hamba/logger/v2/ctx"
+)
+
+// Starter is a handler that needs to be started before any producer runs.
+type Starter interface {
+	// Start starts the handler. An error aborts the start of the game server.
+	Start(ctx context.Context) error
+}
+
+// Stopper is a handler that needs to be stopped after all producers and consumers returned.
+type Stopper interface {
+	// Stop stops the handler within the deadline of the context.
+	Stop(ctx context.Context) error
+}
+
+// ExitHook is a handler that is notified when the game server exits, before the producers are cancelled.
+//
+// The message queue is already shut down then, so messages queued by exit hooks or producers are dropped.
+type ExitHook interface {
+	// OnExit is called with the reason and error the game server exits with, within the deadline of the context.
+	OnExit(ctx context.Context, reason string, err error)
+}
+
+// addHandler adds a handler to the ordered handlers, unless it is already added. It returns false if it is already
+// added.
+func (g *GameServer) addHandler(h any) bool {
+	if slices.ContainsFunc(g.handlers, func(other any) bool { return sameHandler(h, other) }) {
+		return false
+	}
+	g.handlers = append(g.handlers, h)
+	return true
+}
+
+func sameHandler(a, b any) bool {
+	return removable(a) && reflect.TypeOf(a) == reflect.TypeOf(b) && a == b
+}
+
+// removable determines if a handler can be told apart from other handlers, to remove it.
+func removable(h any) bool {
+	return reflect.TypeOf(h).Comparable()
+}
+
+// runState is the state of a running game server. It is guarded by the lock of the game server.
+type runState struct {
+	ctx context.Context //nolint:containedctx // Scoped to the run of the game server.
+
+	attached []*attachment
+	inboxes  []*inbox // Copied on write, to be read by the dispatcher without copying.
+
+	consumerWG sync.WaitGroup
+	producerWG sync.WaitGroup
+}
+
+// attachment is a handler attached to a running game server.
+type attachment struct {
+	handler any
+	inbox   *inbox
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// attach runs the producer and consumer of a started handler. It must be called with the lock held.
+func (g *GameServer) attach(run *runState, h any) {
+	a := &attachment{handler: h}
+
+	if c, ok := h.(Consumer); ok {
+		i := newInbox(c, g.clock, g.queueCfg, g.ringSize)
+		q := g.queueFor(run.ctx, i.name)
+		run.consumerWG.Add(1)
+		a.wg.Add(1)
+		go func() {
+			defer run.consumerWG.Done()
+			defer a.wg.Done()
+			i.run(q)
+		}()
+
+		a.inbox = i
+		run.inboxes = append(slices.Clip(run.inboxes), i)
+	}
+
+	if p, ok := h.(Producer); ok {
+		var ctx context.Context
+		ctx, a.cancel = context.WithCancel(run.ctx)
+		q := g.queueFor(ctx, handlerName(p))
+		run.producerWG.Add(1)
+		a.wg.Add(1)
+		clock.Hold(g.clock) // Until the producer waits, see Run.
+		go func() {
+			defer run.producerWG.Done()
+			defer a.wg.Done()
+			p.Run(ctx, q)
+		}()
+	}
+
+	run.attached = append(run.attached, a)
+}
+
+// detach detaches a handler. It returns nil if the handler is not attached. It must be called with the lock held.
+func (r *runState) detach(h any) *attachment {
+	idx := slices.IndexFunc(r.attached, func(a *attachment) bool { return sameHandler(h, a.handler) })
+	if idx == -1 {
+		return nil
+	}
+	a := r.attached[idx]
+
+	r.attached = slices.Delete(r.attached, idx, idx+1)
+	if a.inbox != nil {
+		r.inboxes = slices.DeleteFunc(slices.Clone(r.inboxes), func(i *inbox) bool { return i == a.inbox })
+	}
+	return a
+}
+
+// handlers returns the attached handlers in the order they were started. It must be called with the lock held.
+func (r *runState) handlers() []any {
+	hdlrs := make([]any, 0, len(r.attached))
+	for _, a := range r.attached {
+		hdlrs = append(hdlrs, a.handler)
+	}
+	return hdlrs
+}
+
+// inboxes returns the inboxes of the attached consumers.
+func (g *GameServer) inboxes() []*inbox {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.run == nil {
+		return nil
+	}
+	return g.run.inboxes
+}
+
+// stopAttachment stops a detached handler within the stop timeout.
+func (g *GameServer) stopAttachment(a *attachment) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.stopTimeout) // On the wall clock, see WithStopTimeout.
+	defer cancel()
+
+	if a.cancel != nil {
+		a.cancel()
+	}
+	if a.inbox != nil {
+		a.inbox.queue.Close()
+	}
+	if !wait(ctx, &a.wg) {
+		g.log.Error("Game server handler did not finish within the stop timeout",
+			lctx.Str("handler", handlerName(a.handler)), lctx.Str("timeout", g.stopTimeout.String()))
+	}
+
+	g.stop(ctx, []any{a.handler})
+}
+
+// startOrder returns the handlers in the order to start them.
+//
+// A handler starts after the handlers that produce the message types it consumes, e.g. the Agones handlers start before
+// the timers that depend on their messages. Handlers that depend on each other start in the order they were added.
+func (g *GameServer) startOrder() []any {
+	deps := make([][]int, len(g.handlers))
+	for i, h := range g.handlers {
+		c, ok := h.(Consumer)
+		if !ok {
+			continue
+		}
+		subs := subscriptions(c)
+
+		for j, other := range g.handlers {
+			pub, ok := other.(Publisher)
+			if !ok || i == j {
+				continue
+			}
+			if slices.ContainsFunc(pub.Publications(), func(typ MessageType) bool { return subscribed(subs, typ) }) {
+				deps[i] = append(deps[i], j)
+			}
+		}
+	}
+
+	order := make([]any, 0, len(g.handlers))
+	started := make([]bool, len(g.handlers))
+	for len(order) < len(g.handlers) {
+		next := -1
+		for i := range g.handlers {
+			if started[i] {
+				continue
+			}
+			if next == -1 {
+				next = i // Fall back to the first added handler, if all depend on each other.
+			}
+			if !slices.ContainsFunc(deps[i], func(j int) bool { return !started[j] }) {
+				next = i
+				break
+			}
+		}
+
+		started[next] = true
+		order = append(order, g.handlers[next])
+	}
+	return order
+}
+
+// start starts the handlers in order. On error, the already started handlers are stopped.
+func (g *GameServer) start(ctx context.Context, order []any) error {
+	for i, h := range order {
+		s, ok := h.(Starter)
+		if !ok {
+			continue
+		}
+
+		if err := s.Start(ctx); err != nil {
+			g.stop(ctx, order[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// stop stops the handlers in reverse order.
+func (g *GameServer) stop(ctx context.Context, order []any) {
+	for _, h := range slices.Backward(order) {
+		s, ok := h.(Stopper)
+		if !ok {
+			continue
+		}
+
+		if err := s.Stop(ctx); err != nil {
+			g.log.Error("Game server handler stop failed", lctx.Str("handler", handlerName(h)), lctx.Err(err))
+		}
+	}
+}
+
+// exit notifies the exit hooks in order.
+func (g *GameServer) exit(ctx context.Context, order []any, reason string, err error) {
+	for _, h := range order {
+		if hook, ok := h.(ExitHook); ok {
+			hook.OnExit(ctx, reason, err)
+		}
+	}
+}
+
+// wait waits for the wait group, or until the context is done. It returns false if the context is done first.
+func wait(ctx context.Context, wg *sync.WaitGroup) bool {
+	doneCh := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(doneCh)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-doneCh:
+		return true
+	}
+}
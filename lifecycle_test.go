@@ -0,0 +1,228 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGameServer_RunLifecycle(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddHandler(&testHandler{name: "consumer", subs: []fakegameserver.MessageType{"exit"}, record: record})
+	gs.AddHandler(&testHandler{name: "producer", pubs: []fakegameserver.MessageType{"exit"}, record: record})
+
+	reason, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reason)
+	want := []string{
+		"producer start", "consumer start",
+		"producer exit", "consumer exit",
+		"consumer consume exit",
+		"consumer stop", "producer stop",
+	}
+	assert.Equal(t, want, events)
+}
+
+func TestGameServer_RunStartsDependenciesFirst(t *testing.T) {
+	record := func(string) {}
+
+	// The consumer is added first, but can only start once the producer of its messages started.
+	producer := &testHandler{name: "producer", pubs: []fakegameserver.MessageType{"exit"}, record: record}
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddHandler(&testHandler{name: "consumer", subs: []fakegameserver.MessageType{"exit"}, requires: producer, record: record})
+	gs.AddHandler(producer)
+
+	_, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+}
+
+func TestGameServer_RunDropsExitHookMessages(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddHandler(&testHandler{name: "consumer", subs: []fakegameserver.MessageType{"*"}, record: record})
+	gs.AddHandler(&queueingExitHook{})
+
+	reason, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reason)
+	assert.Equal(t, []string{"consumer start", "consumer exit", "consumer consume exit", "consumer stop"}, events)
+}
+
+func TestGameServer_RunStartsHandlersAddedWhileStarting(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddHandler(&addingStarter{gs: gs, hdlr: &testHandler{name: "producer", pubs: []fakegameserver.MessageType{"exit"}, record: record}})
+
+	reason, err := gs.Run(t.Context())
+
+	require.NoError(t, err)
+	assert.Equal(t, "done", reason)
+	assert.Equal(t, []string{"producer start", "producer exit", "producer stop"}, events)
+}
+
+func TestGameServer_AddRemoveHandlerWhileRunning(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+	recorded := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return slices.Clone(events)
+	}
+
+	sendCh := make(chan fakegameserver.Message)
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddProducer(producerFunc(func(ctx context.Context, q fakegameserver.Queue) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-sendCh:
+				q.Add(msg)
+			}
+		}
+	}))
+	doneCh := make(chan struct{})
+	go func() {
+		defer close(doneCh)
+		_, _ = gs.Run(t.Context())
+	}()
+
+	h := &testHandler{name: "runtime", subs: []fakegameserver.MessageType{fakegameserver.MessageTypeInfo}, record: record}
+	gs.AddHandler(h)
+	sendCh <- fakegameserver.Message{Type: fakegameserver.MessageTypeInfo}
+
+	require.Eventually(t, func() bool { return len(recorded()) == 2 }, 5*time.Second, time.Millisecond)
+
+	assert.True(t, gs.RemoveHandler(h))
+	assert.False(t, gs.RemoveHandler(h))
+
+	sendCh <- fakegameserver.Message{Type: fakegameserver.MessageTypeInfo}
+	sendCh <- fakegameserver.Message{Type: fakegameserver.MessageTypeExit}
+	<-doneCh
+
+	assert.Equal(t, []string{"runtime start", "runtime consume info", "runtime stop"}, recorded())
+}
+
+type testHandler struct {
+	name     string
+	pubs     []fakegameserver.MessageType
+	subs     []fakegameserver.MessageType
+	requires *testHandler
+	record   func(string)
+
+	started bool
+}
+
+func (h *testHandler) Start(context.Context) error {
+	if h.requires != nil && !h.requires.started {
+		return errors.New(h.name + " requires " + h.requires.name)
+	}
+	h.started = true
+	h.record(h.name + " start")
+	return nil
+}
+
+func (h *testHandler) Stop(context.Context) error {
+	h.started = false
+	h.record(h.name + " stop")
+	return nil
+}
+
+func (h *testHandler) OnExit(context.Context, string, error) {
+	h.record(h.name + " exit")
+}
+
+func (h *testHandler) Publications() []fakegameserver.MessageType {
+	return h.pubs
+}
+
+func (h *testHandler) Run(_ context.Context, q fakegameserver.Queue) {
+	if len(h.pubs) > 0 {
+		q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "done"})
+	}
+}
+
+func (h *testHandler) Subscriptions() []fakegameserver.MessageType {
+	return h.subs
+}
+
+func (h *testHandler) Consume(msg fakegameserver.Message) {
+	h.record(h.name + " consume " + string(msg.Type))
+}
+
+// queueingExitHook queues a message when the game server exits.
+type queueingExitHook struct {
+	q fakegameserver.Queue
+}
+
+func (h *queueingExitHook) Run(_ context.Context, q fakegameserver.Queue) {
+	h.q = q
+	q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "done"})
+}
+
+func (h *queueingExitHook) OnExit(context.Context, string, error) {
+	h.q.Add(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "exited"})
+}
+
+// addingStarter adds a handler when it is started.
+type addingStarter struct {
+	gs   *fakegameserver.GameServer
+	hdlr any
+}
+
+func (h *addingStarter) Start(context.Context) error {
+	h.gs.AddHandler(h.hdlr)
+	return nil
+}
@@ -0,0 +1,65 @@
+package fakegameserver
+
+import (
+	"sync"
+
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+// mailbox hands the latest value over to a producer, replacing a value the producer did not receive yet.
+//
+// On a virtual clock, a value holds the clock until the producer is done with it and waits again, see clock.Hold. Once
+// the producer stops receiving, it closes the mailbox, so that values are dropped rather than holding the clock.
+type mailbox[T any] struct {
+	ch    chan T
+	clock clock.Clock
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newMailbox[T any]() *mailbox[T] {
+	return &mailbox[T]{
+		ch:    make(chan T, 1),
+		clock: clock.Real(),
+	}
+}
+
+// setClock sets the clock the values hold. It must be called before the mailbox is used.
+func (m *mailbox[T]) setClock(c clock.Clock) {
+	m.clock = c
+}
+
+// put puts the value into the mailbox, replacing a value that was not received yet.
+func (m *mailbox[T]) put(v T) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+
+	select {
+	case <-m.ch: // Replaced, its hold passes on to the value.
+	default:
+		clock.Hold(m.clock)
+	}
+	m.ch <- v
+}
+
+// close closes the mailbox, dropping a value that was not received yet.
+func (m *mailbox[T]) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return
+	}
+	m.closed = true
+
+	select {
+	case <-m.ch:
+		clock.Release(m.clock)
+	default:
+	}
+}
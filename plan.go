@@ -0,0 +1,187 @@
+package fakegameserver
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/exiterror"
+	"k8s.io/utils/ptr"
+)
+
+// PlanStep is a step the game server follows, as planned from its configuration.
+type PlanStep struct {
+	// At is the time of a timed step after the start. Agones steps are timed after the Agones connection.
+	At time.Duration
+	// On is the outside event an untimed step depends on, e.g. `Allocated`. Empty for timed steps.
+	On string
+	// Description describes the step.
+	Description string
+
+	// Exit is set if the game server exits at the step, with the exit error if any.
+	Exit    bool
+	ExitErr error
+}
+
+// Plan returns the steps the game server follows with the configuration, without running it: first the timed steps in
+// order, up to the first exit, then the steps that depend on outside events.
+//
+// The time now is the start, e.g. for the exit schedule. The Agones client of the configuration is not used.
+func Plan(cfg Config, now time.Time) ([]PlanStep, error) {
+	var timed, untimed []PlanStep
+
+	if cfg.ExitAfter > 0 {
+		timed = append(timed, PlanStep{At: cfg.ExitAfter, Description: "Exit timer elapses", Exit: true})
+	}
+	if cfg.ExitSchedule != "" {
+		sched, err := ParseSchedule(cfg.ExitSchedule)
+		if err != nil {
+			return nil, err
+		}
+		if next := sched.Next(now); !next.IsZero() {
+			timed = append(timed, PlanStep{
+				At:          next.Sub(now),
+				Description: "Exit schedule " + cfg.ExitSchedule + " elapses at " + next.Format(time.RFC3339),
+				Exit:        true,
+			})
+		}
+	}
+
+	if cfg.Agones != nil {
+		agonesTimed, agonesUntimed := planAgones(cfg)
+		timed = append(timed, agonesTimed...)
+		untimed = append(untimed, agonesUntimed...)
+	}
+
+	if len(cfg.Probe) > 0 {
+		for _, exp := range cfg.Probe {
+			untimed = append(untimed, PlanStep{
+				On:          "probe expectation " + strconv.Quote(exp.String()) + " failed",
+				Description: "Exit",
+				Exit:        true,
+				ExitErr:     exiterror.New(ptr.To(exp.code()), nil),
+			})
+		}
+		untimed = append(untimed, PlanStep{
+			On:          "SIGINT or SIGTERM",
+			Description: "Fail the pending probe expectations and exit, with the code of the first failed expectation",
+			Exit:        true,
+		})
+	} else {
+		untimed = append(untimed, PlanStep{On: "SIGINT or SIGTERM", Description: "Stop", Exit: true})
+	}
+
+	slices.SortStableFunc(timed, func(a, b PlanStep) int {
+		return cmp.Compare(a.At, b.At)
+	})
+	if i := slices.IndexFunc(timed, func(s PlanStep) bool { return s.Exit }); i >= 0 {
+		timed = timed[:i+1]
+	}
+	return append(timed, untimed...), nil
+}
+
+func planAgones(cfg Config) (timed, untimed []PlanStep) {
+	timed = append(timed, PlanStep{Description: "Connect to Agones and watch the game server"})
+
+	health := "Report health every " + cfg.HealthReportInterval.String() + " once healthy, i.e. connected to Agones"
+	if cfg.HealthReportDelay > 0 {
+		health += ", after a delay of " + cfg.HealthReportDelay.String()
+	}
+	timed = append(timed, PlanStep{At: cfg.HealthReportDelay, Description: health})
+
+	if cfg.Population != nil {
+		target := "the player count"
+		if cfg.PopulationCounter != "" {
+			target = "counter " + cfg.PopulationCounter
+		}
+		timed = append(timed, PlanStep{Description: "Apply the population curve to " + target + " every " + cfg.PopulationInterval.String()})
+	}
+
+	shutdown, shutdownExit := planShutdown(cfg)
+	var shutdownErr error
+	if shutdownExit {
+		shutdownErr = sigterm()
+	}
+
+	var (
+		at   time.Duration
+		prev = "the Agones connection"
+	)
+	for _, s := range cfg.States {
+		at += s.After
+		timed = append(timed, PlanStep{
+			At: at,
+			Description: "Request Agones state " + string(s.State) + ", " + s.After.String() + " after " + prev +
+				" (skipped if already " + string(s.State) + ", stopped if the state is final)",
+		})
+		if s.State.IsFinal() {
+			timed = append(timed, PlanStep{At: at, Description: shutdown, Exit: shutdownExit, ExitErr: shutdownErr})
+		}
+		prev = "requesting " + string(s.State)
+	}
+
+	session := "Start a game session, if described by the annotations " + AnnotationSessionDuration + " and " + AnnotationExit
+	if len(cfg.States) > 0 {
+		session += ", stopping the Agones state timers"
+	}
+	untimed = append(untimed, PlanStep{On: string(agones.StateAllocated), Description: session})
+	if cfg.RoomsCounter != "" {
+		rooms := "Open a room for " + cfg.RoomDurationMin.String() + " to " + cfg.RoomDurationMax.String() +
+			", and decrement the counter when it ends"
+		if cfg.RoomsIdleTimeout > 0 {
+			rooms += ", requesting Agones state Shutdown when idle for " + cfg.RoomsIdleTimeout.String()
+		}
+		untimed = append(untimed, PlanStep{On: "counter " + cfg.RoomsCounter + " incremented", Description: rooms})
+	}
+	untimed = append(untimed, PlanStep{
+		On:          "final Agones state (Shutdown, Unhealthy or Error)",
+		Description: shutdown,
+		Exit:        shutdownExit,
+		ExitErr:     shutdownErr,
+	})
+	if cfg.DisconnectTimeout > 0 {
+		untimed = append(untimed, cfg.DisconnectAction.planStep("Agones connection lost for "+cfg.DisconnectTimeout.String()))
+	}
+
+	update := "Agones state update failed " + strconv.Itoa(cfg.UpdateAttempts) + " times"
+	if cfg.UpdateDeadline > 0 {
+		update += " or for " + cfg.UpdateDeadline.String()
+	}
+	untimed = append(untimed, cfg.UpdateGiveUp.planStep(update))
+
+	if cfg.ShutdownOnExit {
+		untimed = append(untimed, PlanStep{On: "exit", Description: "Request Agones state Shutdown, unless the state is final"})
+	}
+	return timed, untimed
+}
+
+// planShutdown returns the description of the shutdown, and whether it exits the game server.
+func planShutdown(cfg Config) (string, bool) {
+	switch {
+	case cfg.ExitOnShutdown == nil:
+		return "Exit, emulating the SIGTERM of Agones, if Agones runs in local development mode, otherwise continue until " +
+			"Agones sends SIGTERM", false
+	case *cfg.ExitOnShutdown:
+		return "Exit, emulating the SIGTERM of Agones", true
+	}
+	return "Continue until Agones sends SIGTERM", false
+}
+
+func sigterm() error {
+	return exiterror.New(nil, ptr.To(int(syscall.SIGTERM)))
+}
+
+// planStep returns the plan step of taking the action on an outside event.
+func (a Action) planStep(on string) PlanStep {
+	msg := a.message("", nil)
+	switch msg.Type {
+	case MessageTypeExit:
+		return PlanStep{On: on, Description: "Exit", Exit: true, ExitErr: msg.Error}
+	case MessageTypeAgonesStopHealth:
+		return PlanStep{On: on, Description: "Stop the health reports, so Agones marks the game server Unhealthy"}
+	}
+	return PlanStep{On: on, Description: "Continue"}
+}
@@ -0,0 +1,83 @@
+package fakegameserver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestPlan(t *testing.T) {
+	cfg := fakegameserver.Config{
+		ExitAfter: 2 * time.Hour,
+		Agones:    agones.NewClient(nil),
+		States: []fakegameserver.StateAfter{
+			{State: agones.StateReady, After: 10 * time.Second},
+			{State: agones.StateAllocated, After: 10 * time.Minute},
+			{State: agones.StateShutdown, After: 30 * time.Minute},
+		},
+		ExitOnShutdown:    ptr.To(true),
+		DisconnectTimeout: time.Minute,
+		DisconnectAction:  "code:3",
+	}
+
+	got, err := fakegameserver.Plan(cfg, time.Now())
+
+	require.NoError(t, err)
+	var timed []time.Duration
+	for _, step := range got {
+		if step.On == "" {
+			timed = append(timed, step.At)
+		}
+	}
+	assert.Equal(t, []time.Duration{0, 0, 10 * time.Second, 10*time.Minute + 10*time.Second, 40*time.Minute + 10*time.Second, 40*time.Minute + 10*time.Second}, timed)
+
+	last := got[len(timed)-1]
+	assert.True(t, last.Exit)
+	assert.EqualError(t, last.ExitErr, "signal 15")
+
+	disconnect := got[len(got)-3]
+	assert.Equal(t, "Agones connection lost for 1m0s", disconnect.On)
+	assert.True(t, disconnect.Exit)
+	assert.EqualError(t, disconnect.ExitErr, "exit code 3")
+}
+
+func TestPlan_ContinuesOnShutdown(t *testing.T) {
+	cfg := fakegameserver.Config{
+		Agones: agones.NewClient(nil),
+		States: []fakegameserver.StateAfter{
+			{State: agones.StateShutdown, After: time.Minute},
+		},
+		ExitOnShutdown: ptr.To(false),
+	}
+
+	got, err := fakegameserver.Plan(cfg, time.Now())
+
+	require.NoError(t, err)
+	var shutdowns int
+	for _, step := range got {
+		if step.Description == "Continue until Agones sends SIGTERM" {
+			shutdowns++
+			assert.False(t, step.Exit)
+			assert.NoError(t, step.ExitErr)
+		}
+	}
+	assert.Equal(t, 2, shutdowns)
+}
+
+func TestPlan_WithoutAgones(t *testing.T) {
+	cfg := fakegameserver.Config{ExitSchedule: "0 4 * * *"}
+	now := time.Date(2025, 1, 1, 3, 0, 0, 0, time.UTC)
+
+	got, err := fakegameserver.Plan(cfg, now)
+
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, time.Hour, got[0].At)
+	assert.True(t, got[0].Exit)
+	assert.Equal(t, "SIGINT or SIGTERM", got[1].On)
+}
@@ -0,0 +1,323 @@
+package fakegameserver
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+const (
+	// MessageTypeAgonesPopulation is the message type for population updates.
+	MessageTypeAgonesPopulation MessageType = "agonesPopulation"
+)
+
+// EventAgonesPopulation is the event type for population updates, with the population as payload.
+var EventAgonesPopulation = NewEventType[int64](MessageTypeAgonesPopulation)
+
+// Curve is a population curve over time.
+type Curve interface {
+	// Value returns the population after the given duration.
+	Value(time.Duration) float64
+}
+
+// SineCurve is a population curve that follows a sine wave, e.g. a daily cycle.
+//
+// The curve starts at its minimum and peaks after half the period.
+type SineCurve struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+// Value returns the population after the given duration.
+func (c SineCurve) Value(d time.Duration) float64 {
+	if c.Period <= 0 {
+		return c.Min
+	}
+	phase := 2 * math.Pi * float64(d%c.Period) / float64(c.Period)
+	return c.Min + (c.Max-c.Min)*(1-math.Cos(phase))/2
+}
+
+// Step is a population step.
+type Step struct {
+	After time.Duration
+	Value float64
+}
+
+// StepCurve is a population curve that changes in steps.
+//
+// The value of a step holds until the next step is reached. Before the first step, the population is zero.
+type StepCurve struct {
+	Steps []Step
+}
+
+// Value returns the population after the given duration.
+func (c StepCurve) Value(d time.Duration) float64 {
+	var val float64
+	for _, step := range c.Steps {
+		if step.After > d {
+			break
+		}
+		val = step.Value
+	}
+	return val
+}
+
+// SpikeCurve is a population curve with a base population and recurring spikes.
+type SpikeCurve struct {
+	Base   float64
+	Peak   float64
+	Every  time.Duration
+	Length time.Duration
+}
+
+// Value returns the population after the given duration.
+func (c SpikeCurve) Value(d time.Duration) float64 {
+	if c.Every <= 0 || d%c.Every >= c.Length {
+		return c.Base
+	}
+	return c.Peak
+}
+
+// Sample is a population sample.
+type Sample struct {
+	At    time.Duration
+	Value float64
+}
+
+// SampleCurve is a population curve that interpolates linearly between samples, e.g. of real traffic.
+//
+// The curve repeats after the last sample.
+type SampleCurve struct {
+	Samples []Sample
+}
+
+// Value returns the population after the given duration.
+func (c SampleCurve) Value(d time.Duration) float64 {
+	switch len(c.Samples) {
+	case 0:
+		return 0
+	case 1:
+		return c.Samples[0].Value
+	}
+
+	if last := c.Samples[len(c.Samples)-1].At; last > 0 {
+		d %= last
+	}
+	idx, _ := slices.BinarySearchFunc(c.Samples, d, func(s Sample, d time.Duration) int {
+		return cmp.Compare(s.At, d)
+	})
+	switch {
+	case idx == 0:
+		return c.Samples[0].Value
+	case idx == len(c.Samples):
+		return c.Samples[idx-1].Value
+	}
+
+	prev, next := c.Samples[idx-1], c.Samples[idx]
+	return prev.Value + (next.Value-prev.Value)*float64(d-prev.At)/float64(next.At-prev.At)
+}
+
+// ReadCSVCurve reads a sample curve from CSV with the columns offset and value.
+//
+// The offset is either a duration, e.g. `1h30m`, or a number of seconds. A header row is skipped.
+func ReadCSVCurve(r io.Reader) (SampleCurve, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return SampleCurve{}, fmt.Errorf("reading csv: %w", err)
+	}
+
+	var curve SampleCurve
+	for i, row := range rows {
+		if len(row) != 2 {
+			return SampleCurve{}, fmt.Errorf("row %d: expected 2 columns, got %d", i+1, len(row))
+		}
+		at, err1 := parseOffset(row[0])
+		val, err2 := strconv.ParseFloat(strings.TrimSpace(row[1]), 64)
+		switch {
+		case (err1 != nil || err2 != nil) && i == 0:
+			continue // Header.
+		case err1 != nil:
+			return SampleCurve{}, fmt.Errorf("row %d: %w", i+1, err1)
+		case err2 != nil:
+			return SampleCurve{}, fmt.Errorf("row %d: %w", i+1, err2)
+		}
+		curve.Samples = append(curve.Samples, Sample{At: at, Value: val})
+	}
+
+	slices.SortStableFunc(curve.Samples, func(a, b Sample) int {
+		return cmp.Compare(a.At, b.At)
+	})
+	return curve, nil
+}
+
+func parseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+// ParseCurve parses a population curve.
+//
+// Supported curves are `sine:<min>:<max>:<period>`, `step:<after>=<value>,...`, `spike:<base>:<peak>:<every>:<length>`
+// and `csv:<path>`.
+func ParseCurve(s string) (Curve, error) {
+	kind, spec, _ := strings.Cut(s, ":")
+	switch kind {
+	case "sine":
+		var c SineCurve
+		err := parseFields(spec, &c.Min, &c.Max, &c.Period)
+		return c, err
+	case "spike":
+		var c SpikeCurve
+		err := parseFields(spec, &c.Base, &c.Peak, &c.Every, &c.Length)
+		return c, err
+	case "step":
+		var c StepCurve
+		for part := range strings.SplitSeq(spec, ",") {
+			after, val, ok := strings.Cut(part, "=")
+			if !ok {
+				return nil, errors.New("invalid step " + strconv.Quote(part) + ", expected <after>=<value>")
+			}
+			var step Step
+			if err := parseFields(after+":"+val, &step.After, &step.Value); err != nil {
+				return nil, err
+			}
+			c.Steps = append(c.Steps, step)
+		}
+		slices.SortStableFunc(c.Steps, func(a, b Step) int {
+			return cmp.Compare(a.After, b.After)
+		})
+		return c, nil
+	case "csv":
+		f, err := os.Open(spec) //nolint:gosec // The path is provided by the user on purpose.
+		if err != nil {
+			return nil, fmt.Errorf("opening curve: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		return ReadCSVCurve(f)
+	default:
+		return nil, errors.New("unknown curve " + strconv.Quote(s) + ", expected sine, step, spike or csv")
+	}
+}
+
+// parseFields parses colon-separated fields into floats and durations.
+func parseFields(spec string, fields ...any) error {
+	parts := strings.Split(spec, ":")
+	if len(parts) != len(fields) {
+		return fmt.Errorf("invalid curve %q, expected %d fields", spec, len(fields))
+	}
+
+	for i, field := range fields {
+		var err error
+		switch v := field.(type) {
+		case *float64:
+			*v, err = strconv.ParseFloat(parts[i], 64)
+		case *time.Duration:
+			*v, err = time.ParseDuration(parts[i])
+		}
+		if err != nil {
+			return fmt.Errorf("invalid curve %q: %w", spec, err)
+		}
+	}
+	return nil
+}
+
+var _ Publisher = (*AgonesPopulation)(nil)
+
+// AgonesPopulation applies the population of a curve to the Agones player count or to an Agones counter.
+type AgonesPopulation struct {
+	client  *agones.Client
+	counter string
+	curve   Curve
+	scale   float64
+	intvl   time.Duration
+}
+
+// NewAgonesPopulation returns a new Agones population handler.
+//
+// The population is applied to the given counter, or to the player count if no counter is given. The time scale
+// compresses the curve, e.g. a time scale of 1440 runs a daily curve within a minute. The population is applied in
+// the given interval. Both the time scale and the interval must be positive.
+func NewAgonesPopulation(
+	client *agones.Client, counter string, curve Curve, scale float64, intvl time.Duration,
+) (*AgonesPopulation, error) {
+	if scale <= 0 {
+		return nil, fmt.Errorf("invalid population time scale %v, expected a positive factor", scale)
+	}
+	if intvl <= 0 {
+		return nil, fmt.Errorf("invalid population interval %v, expected a positive duration", intvl)
+	}
+
+	return &AgonesPopulation{
+		client:  client,
+		counter: counter,
+		curve:   curve,
+		scale:   scale,
+		intvl:   intvl,
+	}, nil
+}
+
+// Publications returns the message types the Agones population handler produces.
+func (p *AgonesPopulation) Publications() []MessageType {
+	return []MessageType{MessageTypeAgonesPopulation}
+}
+
+// Run runs the Agones population handler.
+func (p *AgonesPopulation) Run(ctx context.Context, queue Queue) {
+	target := "player count"
+	if p.counter != "" {
+		target = "counter " + p.counter
+	}
+
+	clk := clock.FromContext(ctx)
+	start := clk.Now()
+
+	t := clk.NewTicker(p.intvl)
+	defer t.Stop()
+
+	last := int64(-1)
+	for {
+		elapsed := time.Duration(float64(clk.Since(start)) * p.scale)
+		val := max(int64(math.Round(p.curve.Value(elapsed))), 0)
+
+		if val != last {
+			var err error
+			if p.counter != "" {
+				_, err = p.client.SetCounter(ctx, p.counter, val)
+			} else {
+				err = p.client.SetPlayers(ctx, val)
+			}
+
+			switch {
+			case err != nil:
+				queue.Add(EventAgonesPopulation.Message("Population update of "+target+" failed", err, val))
+			default:
+				queue.Add(EventAgonesPopulation.Message("Population of "+target+" set to "+strconv.FormatInt(val, 10), nil, val))
+				last = val
+			}
+		}
+
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package fakegameserver_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCurve(t *testing.T) {
+	tests := []struct {
+		name    string
+		curve   string
+		at      map[time.Duration]float64
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:  "handles sine",
+			curve: "sine:10:110:24h",
+			at: map[time.Duration]float64{
+				0:              10,
+				6 * time.Hour:  60,
+				12 * time.Hour: 110,
+				24 * time.Hour: 10,
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name:  "handles steps",
+			curve: "step:1m=50,0s=10",
+			at: map[time.Duration]float64{
+				0:           10,
+				time.Minute: 50,
+				time.Hour:   50,
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name:  "handles spikes",
+			curve: "spike:5:100:1h:5m",
+			at: map[time.Duration]float64{
+				0:                          100,
+				5 * time.Minute:            5,
+				time.Hour + 4*time.Minute:  100,
+				time.Hour + 30*time.Minute: 5,
+			},
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles invalid sine",
+			curve:   "sine:10:110",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid step",
+			curve:   "step:1m",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles unknown curve",
+			curve:   "foo:1",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := fakegameserver.ParseCurve(test.curve)
+
+			test.wantErr(t, err)
+			for at, want := range test.at {
+				assert.InDelta(t, want, got.Value(at), 0.001, "at %s", at)
+			}
+		})
+	}
+}
+
+func TestReadCSVCurve(t *testing.T) {
+	in := "offset,players\n0,0\n1m,60\n120,0\n"
+
+	got, err := fakegameserver.ReadCSVCurve(strings.NewReader(in))
+
+	require.NoError(t, err)
+	assert.InDelta(t, 30, got.Value(30*time.Second), 0.001)
+	assert.InDelta(t, 60, got.Value(time.Minute), 0.001)
+	assert.InDelta(t, 30, got.Value(90*time.Second), 0.001)
+	assert.InDelta(t, 30, got.Value(2*time.Minute+30*time.Second), 0.001)
+}
+
+func TestAgonesPopulation_Run(t *testing.T) {
+	s := &pollSDK{}
+	s.setCounter(0, 10)
+	s.failUpdates(nil, errors.New("unavailable"))
+	client := agones.NewClient(s, agones.WithoutWatch(), agones.WithPollInterval(time.Millisecond), agones.WithBetaSDK(s))
+
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	go client.Run(t.Context())
+
+	curve := fakegameserver.StepCurve{Steps: []fakegameserver.Step{{After: 0, Value: 2}, {After: time.Minute, Value: 5}}}
+	population, err := fakegameserver.NewAgonesPopulation(client, "rooms", curve, 6000, 5*time.Millisecond)
+	require.NoError(t, err)
+	go population.Run(t.Context(), q)
+
+	want := []string{
+		"Population of counter rooms set to 2",
+		"Population update of counter rooms failed", // After 1m of the curve, at a time scale of 6000.
+		"Population of counter rooms set to 5",      // Retried in the next interval.
+	}
+	var got []string
+	for range want {
+		msg, shutdown := q.Get()
+		require.False(t, shutdown)
+		got = append(got, msg.Description)
+	}
+	assert.Equal(t, want, got)
+	assert.Equal(t, int64(5), s.counter())
+}
+
+func TestNewAgonesPopulation(t *testing.T) {
+	tests := []struct {
+		name    string
+		scale   float64
+		intvl   time.Duration
+		wantErr string
+	}{
+		{
+			name:    "handles zero time scale",
+			intvl:   time.Second,
+			wantErr: "invalid population time scale 0, expected a positive factor",
+		},
+		{
+			name:    "handles negative time scale",
+			scale:   -1,
+			intvl:   time.Second,
+			wantErr: "invalid population time scale -1, expected a positive factor",
+		},
+		{
+			name:    "handles zero interval",
+			scale:   1,
+			wantErr: "invalid population interval 0s, expected a positive duration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := fakegameserver.NewAgonesPopulation(nil, "", fakegameserver.StepCurve{}, test.scale, test.intvl)
+
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package fakegameserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/exiterror"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// MessageTypeProbe is the message type for decided probe expectations.
+	MessageTypeProbe MessageType = "probe"
+)
+
+// EventProbe is the event type for decided probe expectations, with the result as payload.
+var EventProbe = NewEventType[ProbeResult](MessageTypeProbe)
+
+// The exit codes of the probe, by the kind of the first failed expectation.
+const (
+	ProbeCodeState  = 10
+	ProbeCodeSignal = 11
+	ProbeCodeLabel  = 12
+)
+
+// probeStart is the reference of an expectation to the start of the probe.
+const probeStart = "start"
+
+// ProbeExpectation is an expectation of the probe about what the orchestrator does to the game server.
+type ProbeExpectation struct {
+	spec string
+
+	state  agones.State
+	signal syscall.Signal
+	within time.Duration
+	of     agones.State
+
+	label string
+	value *string
+	by    agones.State
+}
+
+// ParseProbeExpectation parses a probe expectation, either
+//   - `<state> within <duration> of <state|start>`, e.g. `Allocated within 5m of Ready`,
+//   - `<signal> within <duration> of <state|start>`, e.g. `SIGTERM within 30s of Shutdown`, or
+//   - `label <key>[=<value>] by <state>`, e.g. `label team by Allocated`.
+func ParseProbeExpectation(s string) (ProbeExpectation, error) {
+	exp := ProbeExpectation{spec: s}
+
+	var err error
+	fields := strings.Fields(s)
+	switch {
+	case len(fields) == 5 && fields[1] == "within" && fields[3] == "of":
+		if sig, ok := signals[fields[0]]; ok {
+			exp.signal = sig
+		} else if exp.state, err = agones.ParseState(fields[0]); err != nil {
+			return ProbeExpectation{}, errors.New("invalid probe expectation " + strconv.Quote(s) + ": unknown state or signal " + fields[0])
+		}
+
+		if exp.within, err = time.ParseDuration(fields[2]); err != nil {
+			return ProbeExpectation{}, errors.New("invalid probe expectation " + strconv.Quote(s) + ": " + err.Error())
+		}
+
+		if fields[4] != probeStart {
+			if exp.of, err = agones.ParseState(fields[4]); err != nil {
+				return ProbeExpectation{}, errors.New("invalid probe expectation " + strconv.Quote(s) + ": " + err.Error())
+			}
+		}
+		return exp, nil
+
+	case len(fields) == 4 && fields[0] == "label" && fields[2] == "by":
+		key, val, hasVal := strings.Cut(fields[1], "=")
+		exp.label = key
+		if hasVal {
+			exp.value = &val
+		}
+		if exp.by, err = agones.ParseState(fields[3]); err != nil {
+			return ProbeExpectation{}, errors.New("invalid probe expectation " + strconv.Quote(s) + ": " + err.Error())
+		}
+		return exp, nil
+	}
+
+	return ProbeExpectation{}, errors.New("invalid probe expectation " + strconv.Quote(s) +
+		", expected `<state|signal> within <duration> of <state|start>` or `label <key>[=<value>] by <state>`")
+}
+
+// String returns the expectation as parsed.
+func (e ProbeExpectation) String() string {
+	return e.spec
+}
+
+// code returns the exit code when the expectation fails.
+func (e ProbeExpectation) code() int {
+	switch {
+	case e.label != "":
+		return ProbeCodeLabel
+	case e.signal != 0:
+		return ProbeCodeSignal
+	}
+	return ProbeCodeState
+}
+
+// ProbeStatus is the status of a probe expectation.
+type ProbeStatus string
+
+// The statuses of a probe expectation.
+const (
+	ProbeStatusPending ProbeStatus = "pending"
+	ProbeStatusPassed  ProbeStatus = "passed"
+	ProbeStatusFailed  ProbeStatus = "failed"
+)
+
+// ProbeResult is the result of a probe expectation.
+type ProbeResult struct {
+	Expectation string      `json:"expectation"`
+	Status      ProbeStatus `json:"status"`
+	Reason      string      `json:"reason,omitempty"`
+	// Code is the exit code of a failed expectation.
+	Code int `json:"code,omitempty"`
+}
+
+// ProbeReport is the report of the probe, written when the game server exits.
+type ProbeReport struct {
+	Passed       bool          `json:"passed"`
+	Exit         string        `json:"exit"`
+	Expectations []ProbeResult `json:"expectations"`
+}
+
+var (
+	_ Publisher  = (*Probe)(nil)
+	_ Subscriber = (*Probe)(nil)
+	_ ExitHook   = (*Probe)(nil)
+)
+
+// Probe checks expectations about what the orchestrator does to the game server, e.g. for synthetic monitoring of an
+// allocation pipeline.
+//
+// The game server exits with the code of the first failed expectation, or once a signal is received, failing all
+// pending expectations. The report is written when the game server exits.
+type Probe struct {
+	exps []ProbeExpectation
+	w    io.Writer
+
+	mu      sync.Mutex
+	start   time.Time
+	reached map[agones.State]time.Time
+	labels  map[agones.State]map[string]string
+	sigs    map[syscall.Signal]time.Time
+	sig     syscall.Signal
+	results []ProbeResult
+	notify  *mailbox[struct{}]
+}
+
+// NewProbe returns a new probe of the expectations, that writes its report as JSON to w.
+func NewProbe(w io.Writer, exps ...ProbeExpectation) *Probe {
+	results := make([]ProbeResult, 0, len(exps))
+	for _, exp := range exps {
+		results = append(results, ProbeResult{Expectation: exp.String(), Status: ProbeStatusPending})
+	}
+
+	return &Probe{
+		exps:    exps,
+		w:       w,
+		reached: map[agones.State]time.Time{},
+		labels:  map[agones.State]map[string]string{},
+		sigs:    map[syscall.Signal]time.Time{},
+		results: results,
+		notify:  newMailbox[struct{}](),
+	}
+}
+
+// Publications returns the message types the probe produces.
+func (p *Probe) Publications() []MessageType {
+	return []MessageType{MessageTypeProbe, MessageTypeExit}
+}
+
+// Run runs the probe, deciding the expectations as messages are consumed and deadlines elapse.
+func (p *Probe) Run(ctx context.Context, queue Queue) {
+	clk := clock.FromContext(ctx)
+	defer p.notify.close()
+
+	p.mu.Lock()
+	p.start = clk.Now()
+	p.mu.Unlock()
+
+	var timer clock.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for {
+		msgs, next, done := p.evaluate(clk.Now())
+		for _, msg := range msgs {
+			queue.Add(msg)
+		}
+		if done {
+			clock.Release(clk)
+			return
+		}
+
+		var timerCh <-chan time.Time
+		if timer != nil {
+			timer.Stop()
+			timer = nil
+		}
+		if !next.IsZero() {
+			timer = clk.NewTimer(next.Sub(clk.Now()))
+			timerCh = timer.C()
+		}
+
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.notify.ch:
+		case <-timerCh:
+		}
+	}
+}
+
+// evaluate decides the pending expectations at the given time. It returns the messages of the decided expectations,
+// the next deadline of a pending expectation, and whether the probe is done.
+func (p *Probe) evaluate(now time.Time) ([]Message, time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var (
+		msgs []Message
+		next time.Time
+		exit *Message
+	)
+	for i, exp := range p.exps {
+		res := &p.results[i]
+		if res.Status != ProbeStatusPending {
+			continue
+		}
+
+		status, reason, deadline := p.check(exp, now)
+		if status == ProbeStatusPending && p.sig != 0 {
+			status, reason = ProbeStatusFailed, "not met before "+signalName(p.sig)
+		}
+
+		switch status {
+		case ProbeStatusPending:
+			if !deadline.IsZero() && (next.IsZero() || deadline.Before(next)) {
+				next = deadline
+			}
+			continue
+		case ProbeStatusPassed:
+			res.Status, res.Reason = status, reason
+			msgs = append(msgs, EventProbe.Message("Probe expectation met: "+exp.String()+", "+reason, nil, *res))
+		case ProbeStatusFailed:
+			res.Status, res.Reason, res.Code = status, reason, exp.code()
+			msgs = append(msgs, EventProbe.Message("Probe expectation failed: "+exp.String(), errors.New(reason), *res))
+
+			if exit == nil {
+				exit = &Message{
+					Type:        MessageTypeExit,
+					Description: "Probe expectation failed: " + exp.String() + ", exiting",
+					Error:       exiterror.New(ptr.To(res.Code), nil),
+				}
+			}
+		}
+	}
+
+	switch {
+	case exit != nil:
+		msgs = append(msgs, *exit)
+	case p.sig != 0:
+		msgs = append(msgs, Message{
+			Type:        MessageTypeExit,
+			Description: "Received signal " + signalName(p.sig) + ", exiting",
+		})
+	default:
+		return msgs, next, false
+	}
+	return msgs, time.Time{}, true
+}
+
+// check checks an expectation at the given time. It returns the deadline of a pending expectation, if any.
+func (p *Probe) check(exp ProbeExpectation, now time.Time) (ProbeStatus, string, time.Time) {
+	if exp.label != "" {
+		labels, ok := p.labels[exp.by]
+		if !ok {
+			return ProbeStatusPending, "", time.Time{}
+		}
+
+		val, found := labels[exp.label]
+		switch {
+		case !found:
+			return ProbeStatusFailed, "label " + exp.label + " not set by " + string(exp.by), time.Time{}
+		case exp.value != nil && val != *exp.value:
+			return ProbeStatusFailed, "label " + exp.label + " is " + strconv.Quote(val) + " by " + string(exp.by) + ", expected " +
+				strconv.Quote(*exp.value), time.Time{}
+		}
+		return ProbeStatusPassed, "label " + exp.label + " is " + strconv.Quote(val), time.Time{}
+	}
+
+	from, of := p.start, probeStart
+	if exp.of != "" {
+		var ok bool
+		if from, ok = p.reached[exp.of]; !ok {
+			return ProbeStatusPending, "", time.Time{}
+		}
+		of = string(exp.of)
+	}
+
+	subject := string(exp.state)
+	at, ok := p.reached[exp.state]
+	if exp.signal != 0 {
+		subject = signalName(exp.signal)
+		at, ok = p.sigs[exp.signal]
+	}
+
+	deadline := from.Add(exp.within)
+	switch {
+	case ok:
+		took := max(at.Sub(from), 0)
+		if took > exp.within {
+			return ProbeStatusFailed, subject + " after " + took.String() + " of " + of, time.Time{}
+		}
+		return ProbeStatusPassed, subject + " after " + took.String() + " of " + of, time.Time{}
+	case !now.Before(deadline):
+		return ProbeStatusFailed, subject + " not within " + exp.within.String() + " of " + of, time.Time{}
+	}
+	return ProbeStatusPending, "", deadline
+}
+
+// Subscriptions returns the message types the probe consumes.
+func (p *Probe) Subscriptions() []MessageType {
+	return []MessageType{MessageTypeAgonesUpdate, MessageTypeAgonesGameServer, MessageTypeSignal}
+}
+
+// Consume consumes Agones state updates, Agones game server changes and signals.
+func (p *Probe) Consume(msg Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if state, ok := EventAgonesUpdate.Payload(msg); ok && msg.Error == nil {
+		p.reach(state, msg.Created, nil)
+	}
+	if gs, ok := EventAgonesGameServer.Payload(msg); ok {
+		p.reach(gs.State, msg.Created, gs.Labels)
+	}
+	if sig, ok := EventSignal.Payload(msg); ok {
+		if _, seen := p.sigs[sig]; !seen {
+			p.sigs[sig] = msg.Created
+		}
+		if p.sig == 0 {
+			p.sig = sig
+		}
+	}
+
+	p.notify.put(struct{}{})
+}
+
+// reach records the first time a state is reached, and the labels the state is reached with.
+func (p *Probe) reach(state agones.State, at time.Time, labels map[string]string) {
+	if state == "" {
+		return
+	}
+	if _, ok := p.reached[state]; !ok {
+		p.reached[state] = at
+	}
+	if _, ok := p.labels[state]; !ok && labels != nil {
+		p.labels[state] = labels
+	}
+}
+
+func (p *Probe) setClock(c clock.Clock) {
+	p.notify.setClock(c)
+}
+
+// Report returns the report of the probe.
+func (p *Probe) Report() ProbeReport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return ProbeReport{
+		Passed: !slices.ContainsFunc(p.results, func(res ProbeResult) bool {
+			return res.Status != ProbeStatusPassed
+		}),
+		Expectations: slices.Clone(p.results),
+	}
+}
+
+// OnExit writes the report of the probe.
+func (p *Probe) OnExit(_ context.Context, reason string, err error) {
+	report := p.Report()
+	report.Exit = reason
+	if err != nil {
+		report.Exit = err.Error()
+	}
+
+	_ = json.NewEncoder(p.w).Encode(report) // The game server exits anyway.
+}
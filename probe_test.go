@@ -0,0 +1,230 @@
+package fakegameserver_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProbeExpectation(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr require.ErrorAssertionFunc
+	}{
+		{
+			name:    "handles state",
+			spec:    "Allocated within 5m of Ready",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles signal from start",
+			spec:    "SIGTERM within 30s of start",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles label with value",
+			spec:    "label team=blue by Allocated",
+			wantErr: require.NoError,
+		},
+		{
+			name:    "handles unknown state",
+			spec:    "Allocating within 5m of Ready",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid duration",
+			spec:    "Allocated within soon of Ready",
+			wantErr: require.Error,
+		},
+		{
+			name:    "handles invalid syntax",
+			spec:    "Allocated after Ready",
+			wantErr: require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := fakegameserver.ParseProbeExpectation(test.spec)
+
+			test.wantErr(t, err)
+			if err == nil {
+				assert.Equal(t, test.spec, got.String())
+			}
+		})
+	}
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		msgs     func(at func(time.Duration) time.Time) []fakegameserver.Message
+		advance  time.Duration
+		wantCode string
+		wantErr  string
+	}{
+		{
+			name: "handles state not within duration",
+			spec: "Allocated within 5m of Ready",
+			msgs: func(at func(time.Duration) time.Time) []fakegameserver.Message {
+				return []fakegameserver.Message{gameServerAt(at(0), agones.StateReady, nil)}
+			},
+			advance:  5 * time.Minute,
+			wantCode: "exit code 10",
+			wantErr:  "Allocated not within 5m0s of Ready",
+		},
+		{
+			name: "handles state too late",
+			spec: "Allocated within 5m of Ready",
+			msgs: func(at func(time.Duration) time.Time) []fakegameserver.Message {
+				return []fakegameserver.Message{
+					gameServerAt(at(0), agones.StateReady, nil),
+					gameServerAt(at(6*time.Minute), agones.StateAllocated, nil),
+				}
+			},
+			wantCode: "exit code 10",
+			wantErr:  "Allocated after 6m0s of Ready",
+		},
+		{
+			name: "handles missing signal",
+			spec: "SIGTERM within 30s of Shutdown",
+			msgs: func(at func(time.Duration) time.Time) []fakegameserver.Message {
+				return []fakegameserver.Message{gameServerAt(at(0), agones.StateShutdown, nil)}
+			},
+			advance:  30 * time.Second,
+			wantCode: "exit code 11",
+			wantErr:  "SIGTERM not within 30s of Shutdown",
+		},
+		{
+			name: "handles missing label",
+			spec: "label team by Allocated",
+			msgs: func(at func(time.Duration) time.Time) []fakegameserver.Message {
+				return []fakegameserver.Message{gameServerAt(at(0), agones.StateAllocated, map[string]string{"foo": "bar"})}
+			},
+			wantCode: "exit code 12",
+			wantErr:  "label team not set by Allocated",
+		},
+		{
+			name: "handles signal before state",
+			spec: "Allocated within 5m of Ready",
+			msgs: func(at func(time.Duration) time.Time) []fakegameserver.Message {
+				return []fakegameserver.Message{fakegameserver.EventSignal.Message("Received signal SIGTERM", nil, syscall.SIGTERM)}
+			},
+			wantCode: "exit code 10",
+			wantErr:  "not met before SIGTERM",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			clk := clock.NewVirtual(time.Now())
+			ctx := clock.NewContext(t.Context(), clk)
+
+			q := queue.NewFifo[fakegameserver.Message]()
+			t.Cleanup(q.Shutdown)
+
+			exp, err := fakegameserver.ParseProbeExpectation(test.spec)
+			require.NoError(t, err)
+			var report bytes.Buffer
+
+			probe := fakegameserver.NewProbe(&report, exp)
+			go probe.Run(ctx, q)
+
+			start := clk.Now()
+			for _, msg := range test.msgs(start.Add) {
+				probe.Consume(msg)
+			}
+			if test.advance > 0 {
+				require.NoError(t, clk.WaitTimers(ctx, 1))
+				clk.Advance(test.advance)
+			}
+
+			msg, shutdown := q.Get()
+
+			require.False(t, shutdown)
+			assert.Equal(t, fakegameserver.MessageTypeProbe, msg.Type)
+			assert.EqualError(t, msg.Error, test.wantErr)
+
+			msg, shutdown = q.Get()
+
+			require.False(t, shutdown)
+			assert.Equal(t, fakegameserver.MessageTypeExit, msg.Type)
+			assert.EqualError(t, msg.Error, test.wantCode)
+
+			probe.OnExit(t.Context(), "", msg.Error)
+
+			var got fakegameserver.ProbeReport
+			require.NoError(t, json.Unmarshal(report.Bytes(), &got))
+			assert.False(t, got.Passed)
+			assert.Equal(t, test.wantCode, got.Exit)
+			assert.Equal(t, fakegameserver.ProbeStatusFailed, got.Expectations[0].Status)
+		})
+	}
+}
+
+func TestProbe_Passed(t *testing.T) {
+	clk := clock.NewVirtual(time.Now())
+	ctx := clock.NewContext(t.Context(), clk)
+
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	var exps []fakegameserver.ProbeExpectation
+	for _, spec := range []string{"Allocated within 5m of Ready", "label team=blue by Allocated", "SIGTERM within 30s of Shutdown"} {
+		exp, err := fakegameserver.ParseProbeExpectation(spec)
+		require.NoError(t, err)
+		exps = append(exps, exp)
+	}
+
+	probe := fakegameserver.NewProbe(&bytes.Buffer{}, exps...)
+	go probe.Run(ctx, q)
+
+	start := clk.Now()
+	probe.Consume(gameServerAt(start, agones.StateReady, nil))
+	probe.Consume(gameServerAt(start.Add(time.Minute), agones.StateAllocated, map[string]string{"team": "blue"}))
+	probe.Consume(gameServerAt(start.Add(2*time.Minute), agones.StateShutdown, nil))
+	sig := fakegameserver.EventSignal.Message("Received signal SIGTERM", nil, syscall.SIGTERM)
+	sig.Created = start.Add(2*time.Minute + 10*time.Second)
+	probe.Consume(sig)
+
+	var types []fakegameserver.MessageType
+	for {
+		msg, shutdown := q.Get()
+		require.False(t, shutdown)
+		require.NoError(t, msg.Error)
+
+		types = append(types, msg.Type)
+		if msg.Type == fakegameserver.MessageTypeExit {
+			break
+		}
+	}
+
+	assert.Equal(t, []fakegameserver.MessageType{
+		fakegameserver.MessageTypeProbe,
+		fakegameserver.MessageTypeProbe,
+		fakegameserver.MessageTypeProbe,
+		fakegameserver.MessageTypeExit,
+	}, types)
+	assert.True(t, probe.Report().Passed)
+}
+
+func gameServerAt(at time.Time, state agones.State, labels map[string]string) fakegameserver.Message {
+	msg := fakegameserver.EventAgonesGameServer.Message("Agones game server change received", nil, agones.GameServer{State: state, Labels: labels})
+	msg.Created = at
+	return msg
+}
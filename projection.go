@@ -0,0 +1,160 @@
+package fakegameserver
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+// Snapshot is the state of the game server, as projected from the message stream.
+type Snapshot struct {
+	// State is the current Agones state, as watched.
+	State agones.State
+
+	// Requested is the last Agones state the game server updated to successfully. State follows once Agones applied
+	// it.
+	Requested agones.State
+
+	// Connected is set while the Agones connection is established.
+	Connected bool
+
+	// Healthy is the last reported health status.
+	Healthy bool
+
+	// Errors is the error of the last message of each type, nil if it succeeded.
+	Errors map[MessageType]error
+
+	// Labels, Annotations, Counters and Players are the last watched values of the Agones game server.
+	Labels      map[string]string
+	Annotations map[string]string
+	Counters    map[string]agones.Counter
+	Players     int64
+
+	// Durations is the time spent in each Agones state, including the time spent in the current state.
+	Durations map[agones.State]time.Duration
+
+	// Messages is the number of projected messages.
+	Messages int64
+}
+
+// String returns a summary of the snapshot.
+func (s Snapshot) String() string {
+	states := slices.Sorted(maps.Keys(s.Durations))
+	durs := make([]string, 0, len(states))
+	for _, state := range states {
+		durs = append(durs, string(state)+"="+s.Durations[state].Round(time.Millisecond).String())
+	}
+	return strings.Join(durs, ", ")
+}
+
+// Projection is the read-only state of the game server, built from the message stream.
+//
+// The game server applies each message to its projection before the message is dispatched, so a consumer may read a
+// projection that is already ahead of the message it consumes.
+type Projection struct {
+	mu    sync.RWMutex
+	snap  Snapshot
+	since time.Time
+	clock clock.Clock
+}
+
+// NewProjection returns a new projection.
+func NewProjection() *Projection {
+	return newProjection(clock.Real())
+}
+
+func newProjection(clk clock.Clock) *Projection {
+	return &Projection{
+		snap: Snapshot{
+			Durations: map[agones.State]time.Duration{},
+			Errors:    map[MessageType]error{},
+		},
+		clock: clk,
+	}
+}
+
+// Snapshot returns the current state of the game server.
+func (p *Projection) Snapshot() Snapshot {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	snap := p.snap
+	snap.Labels = maps.Clone(p.snap.Labels)
+	snap.Annotations = maps.Clone(p.snap.Annotations)
+	snap.Counters = maps.Clone(p.snap.Counters)
+	snap.Durations = maps.Clone(p.snap.Durations)
+	snap.Errors = maps.Clone(p.snap.Errors)
+	if snap.State != "" {
+		snap.Durations[snap.State] += p.clock.Since(p.since)
+	}
+	return snap
+}
+
+// State returns the current Agones state.
+func (p *Projection) State() agones.State {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.snap.State
+}
+
+// Errors returns the error of the last message of each type, nil if it succeeded.
+func (p *Projection) Errors() map[MessageType]error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return maps.Clone(p.snap.Errors)
+}
+
+// Apply applies a message to the projection.
+func (p *Projection) Apply(msg Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.snap.Messages++
+	p.snap.Errors[msg.Type] = msg.Error
+
+	if state, ok := EventAgonesUpdate.Payload(msg); ok {
+		switch {
+		case msg.CausationID != "" && msg.Error == nil:
+			// Updates in reply to a request carry the requested state, which Agones has yet to apply.
+			p.snap.Requested = state
+		case msg.CausationID == "":
+			// Watched states are taken even if unknown, as they are received from Agones nonetheless.
+			p.setState(state, msg.Created)
+		}
+	}
+	if gs, ok := EventAgonesGameServer.Payload(msg); ok {
+		p.setState(gs.State, msg.Created)
+		p.snap.Labels = maps.Clone(gs.Labels)
+		p.snap.Annotations = maps.Clone(gs.Annotations)
+		p.snap.Counters = maps.Clone(gs.Counters)
+		p.snap.Players = gs.Players
+	}
+	if connected, ok := EventAgonesConnection.Payload(msg); ok {
+		p.snap.Connected = connected
+	}
+	if healthy, ok := EventHealthStatus.Payload(msg); ok {
+		p.snap.Healthy = healthy
+	}
+}
+
+func (p *Projection) setState(state agones.State, at time.Time) {
+	if state == p.snap.State || state == "" {
+		return
+	}
+	if at.IsZero() {
+		at = p.clock.Now()
+	}
+
+	if p.snap.State != "" {
+		p.snap.Durations[p.snap.State] += at.Sub(p.since)
+	}
+	p.snap.State = state
+	p.since = at
+}
@@ -0,0 +1,58 @@
+package fakegameserver_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjection_Apply(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+
+	req := fakegameserver.EventAgonesRequestUpdate.Message("", nil, agones.StateAllocated)
+	req.ID = "req"
+
+	proj := fakegameserver.NewProjection()
+	msgs := []fakegameserver.Message{
+		fakegameserver.EventAgonesConnection.Message("", nil, true),
+		fakegameserver.EventAgonesUpdate.Message("", nil, agones.StateScheduled),
+		fakegameserver.EventAgonesUpdate.Message("", nil, agones.StateReady),
+		req.Reply(fakegameserver.EventAgonesUpdate.Message("", nil, agones.StateAllocated)),
+		req.Reply(fakegameserver.EventAgonesUpdate.Message("", errors.New("test"), agones.StateShutdown)),
+		fakegameserver.EventAgonesGameServer.Message("", nil, agones.GameServer{
+			State:    agones.StateReady,
+			Labels:   map[string]string{"foo": "bar"},
+			Counters: map[string]agones.Counter{"rooms": {Count: 1, Capacity: 2}},
+			Players:  3,
+		}),
+		fakegameserver.EventHealthStatus.Message("", nil, true),
+	}
+	msgs[1].Created = start
+	msgs[2].Created = start.Add(10 * time.Second)
+	for _, msg := range msgs {
+		proj.Apply(msg)
+	}
+
+	snap := proj.Snapshot()
+
+	assert.Equal(t, agones.StateReady, snap.State)
+	assert.Equal(t, agones.StateAllocated, snap.Requested)
+	assert.True(t, snap.Connected)
+	assert.True(t, snap.Healthy)
+	assert.Equal(t, map[string]string{"foo": "bar"}, snap.Labels)
+	assert.Equal(t, map[string]agones.Counter{"rooms": {Count: 1, Capacity: 2}}, snap.Counters)
+	assert.Equal(t, int64(3), snap.Players)
+	assert.Equal(t, 10*time.Second, snap.Durations[agones.StateScheduled])
+	assert.InDelta(t, 50*time.Second, snap.Durations[agones.StateReady], float64(time.Second))
+	assert.Equal(t, map[fakegameserver.MessageType]error{
+		fakegameserver.MessageTypeAgonesConnection: nil,
+		fakegameserver.MessageTypeAgonesUpdate:     errors.New("test"),
+		fakegameserver.MessageTypeAgonesGameServer: nil,
+		fakegameserver.MessageTypeHealthStatus:     nil,
+	}, snap.Errors)
+	assert.Equal(t, int64(7), snap.Messages)
+}
@@ -0,0 +1,303 @@
+package fakegameserver
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/cenkalti/backoff/v4"
+)
+
+const (
+	// MessageTypeAgonesRooms is the message type for game sessions (rooms) tracked by an Agones counter.
+	MessageTypeAgonesRooms MessageType = "agonesRooms"
+)
+
+var _ Publisher = (*AgonesRooms)(nil)
+
+// AgonesRooms simulates multiple concurrent game sessions (rooms), tracked by an Agones counter.
+//
+// Each increment of the counter, usually by an allocation, opens a room with a random duration. When a room ends,
+// the counter is decremented, a failed decrement is retried with backoff for up to 15m, before giving up while the
+// counter still counts the room. A decrement from outside drops rooms, as the rooms follow the count. The game server transitions back to Ready
+// while it has capacity left, and to Shutdown once it has been idle for the idle timeout since the last room ended. A
+// game server that never hosted a room does not shut down, to keep the buffer of Ready game servers.
+type AgonesRooms struct {
+	client      *agones.Client
+	counter     string
+	minDur      time.Duration
+	maxDur      time.Duration
+	idleTimeout time.Duration
+
+	gss   *mailbox[agones.GameServer]
+	endCh chan *room
+}
+
+// NewAgonesRooms returns a new Agones rooms handler.
+//
+// Room durations are distributed uniformly between the minimum and maximum duration. An idle timeout of zero
+// disables the shutdown when idle.
+func NewAgonesRooms(client *agones.Client, counter string, minDur, maxDur, idleTimeout time.Duration) *AgonesRooms {
+	return &AgonesRooms{
+		client:      client,
+		counter:     counter,
+		minDur:      minDur,
+		maxDur:      max(minDur, maxDur),
+		idleTimeout: idleTimeout,
+		gss:         newMailbox[agones.GameServer](),
+		endCh:       make(chan *room),
+	}
+}
+
+// Publications returns the message types the Agones rooms handler produces.
+func (r *AgonesRooms) Publications() []MessageType {
+	return []MessageType{MessageTypeAgonesRooms, MessageTypeAgonesRequestUpdate}
+}
+
+// Run runs the Agones rooms handler.
+func (r *AgonesRooms) Run(ctx context.Context, queue Queue) {
+	clk := clock.FromContext(ctx)
+	r.gss.setClock(clk)
+	defer r.gss.close()
+
+	clock.Hold(clk)
+	go r.client.WatchGameServer(ctx, r.gss.put) // Only keeps the latest game server.
+
+	run := &roomsRun{AgonesRooms: r, queue: queue, clock: clk}
+	defer run.stopIdle()
+
+	for {
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case gs := <-r.gss.ch:
+			run.update(ctx, gs)
+		case rm := <-r.endCh:
+			run.close(ctx, rm)
+		case <-run.idleCh:
+			run.idleTimer, run.idleCh = nil, nil
+			queue.Add(r.requestState(agones.StateShutdown, "Rooms idle for "+r.idleTimeout.String()))
+		}
+	}
+}
+
+// maxRoomCloseRetry is the maximum duration to retry a failed counter decrement for, when a room ends.
+const maxRoomCloseRetry = 15 * time.Minute
+
+// room is a room counted by the counter.
+type room struct {
+	dur     time.Duration
+	timer   clock.Timer
+	ended   bool                        // Set once the room ended, while the counter still counts it.
+	bo      *backoff.ExponentialBackOff // Set once closing the room failed.
+	dropped bool                        // Set once the counter no longer counts the room.
+}
+
+// roomsRun is the state of a running Agones rooms handler.
+type roomsRun struct {
+	*AgonesRooms
+
+	queue Queue
+	clock clock.Clock
+
+	state     agones.State
+	seen      int64
+	rooms     []*room // In the order they opened.
+	requested bool
+	idleTimer clock.Timer
+	idleCh    <-chan time.Time
+}
+
+func (r *roomsRun) update(ctx context.Context, gs agones.GameServer) {
+	if gs.State != r.state {
+		r.state = gs.State
+		r.requested = false
+	}
+
+	counter, ok := gs.Counters[r.counter]
+	if !ok || counter.Count == r.seen {
+		return
+	}
+	r.seen = counter.Count
+
+	// The watched counter may lag behind, the SDK server knows about pending updates.
+	counter, err := r.client.Counter(ctx, r.counter)
+	if err != nil {
+		r.queue.Add(Message{
+			Type:        MessageTypeAgonesRooms,
+			Description: "Room counter lookup failed",
+			Error:       err,
+		})
+		return
+	}
+
+	r.sync(ctx, counter)
+	r.requestReady(counter)
+}
+
+// sync derives the rooms from the count of the counter: increments open rooms, and decrements from outside, e.g. by a
+// manual update of the counter, drop rooms.
+func (r *roomsRun) sync(ctx context.Context, counter agones.Counter) {
+	r.seen = counter.Count
+
+	for int64(len(r.rooms)) < counter.Count {
+		r.open(ctx, counter)
+	}
+	if int64(len(r.rooms)) > counter.Count {
+		r.drop(counter)
+	}
+}
+
+func (r *roomsRun) open(ctx context.Context, counter agones.Counter) {
+	rm := &room{dur: r.duration()}
+	r.endAfter(ctx, rm, rm.dur)
+	r.rooms = append(r.rooms, rm)
+
+	r.queue.Add(Message{
+		Type:        MessageTypeAgonesRooms,
+		Description: "Room opened for " + rm.dur.String() + " (" + r.usage(counter.Capacity) + ")",
+	})
+
+	r.stopIdle()
+}
+
+// drop drops the rooms the counter no longer counts, first the rooms that ended already, then the most recently opened
+// rooms.
+func (r *roomsRun) drop(counter agones.Counter) {
+	n := int64(len(r.rooms)) - counter.Count
+
+	rooms := make([]*room, 0, len(r.rooms))
+	for _, rm := range r.rooms {
+		if rm.ended && n > 0 {
+			r.dropRoom(rm, counter)
+			n--
+			continue
+		}
+		rooms = append(rooms, rm)
+	}
+	for ; n > 0; n-- {
+		rm := rooms[len(rooms)-1]
+		rooms = rooms[:len(rooms)-1]
+		r.dropRoom(rm, counter)
+	}
+	r.rooms = rooms
+
+	if len(r.rooms) == 0 {
+		r.startIdle()
+	}
+}
+
+func (r *roomsRun) dropRoom(rm *room, counter agones.Counter) {
+	if rm.timer != nil {
+		rm.timer.Stop()
+	}
+	rm.dropped = true
+
+	r.queue.Add(Message{
+		Type:        MessageTypeAgonesRooms,
+		Description: "Room dropped, as the counter was lowered to " + strconv.FormatInt(counter.Count, 10),
+	})
+}
+
+// endAfter ends the room after the duration.
+func (r *roomsRun) endAfter(ctx context.Context, rm *room, d time.Duration) {
+	rm.timer = r.clock.AfterFunc(d, func() {
+		clock.Hold(r.clock) // Until the room is closed.
+		select {
+		case <-ctx.Done():
+			clock.Release(r.clock)
+		case r.endCh <- rm:
+		}
+	})
+}
+
+func (r *roomsRun) close(ctx context.Context, rm *room) {
+	if rm.dropped {
+		return
+	}
+	rm.ended = true
+
+	counter, err := r.client.UpdateCounter(ctx, r.counter, -1)
+	if err != nil {
+		if rm.bo == nil {
+			rm.bo = backoff.NewExponentialBackOff()
+			rm.bo.MaxElapsedTime = maxRoomCloseRetry
+			rm.bo.Clock = r.clock
+		}
+		next := rm.bo.NextBackOff()
+		if next == backoff.Stop {
+			rm.timer = nil
+			r.queue.Add(Message{
+				Type:        MessageTypeAgonesRooms,
+				Description: "Room closed after " + rm.dur.String() + ", but the counter update failed, giving up",
+				Error:       err,
+			})
+			return
+		}
+		r.endAfter(ctx, rm, next)
+
+		r.queue.Add(Message{
+			Type:        MessageTypeAgonesRooms,
+			Description: "Room closed after " + rm.dur.String() + ", but the counter update failed, retrying",
+			Error:       err,
+		})
+		return
+	}
+	r.rooms = slices.DeleteFunc(r.rooms, func(other *room) bool { return other == rm })
+
+	r.queue.Add(Message{
+		Type:        MessageTypeAgonesRooms,
+		Description: "Room closed after " + rm.dur.String() + " (" + r.usage(counter.Capacity) + ")",
+	})
+
+	if len(r.rooms) == 0 {
+		r.startIdle()
+	}
+	r.sync(ctx, counter) // The counter may have been updated from outside meanwhile.
+	r.requestReady(counter)
+}
+
+func (r *roomsRun) requestReady(counter agones.Counter) {
+	if r.state != agones.StateAllocated || counter.Count >= counter.Capacity || r.requested {
+		return
+	}
+	r.requested = true
+
+	r.queue.Add(r.requestState(agones.StateReady, "Room capacity left"))
+}
+
+func (r *roomsRun) startIdle() {
+	if r.idleTimeout <= 0 {
+		return
+	}
+	r.idleTimer = r.clock.NewTimer(r.idleTimeout)
+	r.idleCh = r.idleTimer.C()
+}
+
+func (r *roomsRun) stopIdle() {
+	if r.idleTimer == nil {
+		return
+	}
+	r.idleTimer.Stop()
+	r.idleTimer, r.idleCh = nil, nil
+}
+
+func (r *AgonesRooms) duration() time.Duration {
+	if r.maxDur == r.minDur {
+		return r.minDur
+	}
+	return r.minDur + rand.N(r.maxDur-r.minDur) //nolint:gosec // No need for secure randomness.
+}
+
+func (r *roomsRun) usage(capacity int64) string {
+	return strconv.Itoa(len(r.rooms)) + "/" + strconv.FormatInt(capacity, 10) + " rooms"
+}
+
+func (r *AgonesRooms) requestState(state agones.State, reason string) Message {
+	return EventAgonesRequestUpdate.Message(reason+", requesting Agones state update to "+string(state), nil, state)
+}
@@ -0,0 +1,189 @@
+package fakegameserver_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/utils/ptr"
+)
+
+func TestAgonesRooms(t *testing.T) {
+	type step struct {
+		wait  time.Duration // Waits before setting the counter.
+		count *int64        // Sets the counter from outside, as an allocation does.
+		want  []string
+	}
+
+	tests := []struct {
+		name        string
+		capacity    int64
+		dur         time.Duration
+		idleTimeout time.Duration
+		updateErrs  []error
+		steps       []step
+		wantCount   int64
+	}{
+		{
+			name:     "handles hosting",
+			capacity: 2,
+			dur:      time.Hour,
+			steps: []step{
+				{count: ptr.To[int64](1), want: []string{
+					"Room opened for 1h0m0s (1/2 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+				}},
+			},
+			wantCount: 1,
+		},
+		{
+			name:     "handles closing",
+			capacity: 2,
+			dur:      10 * time.Millisecond,
+			steps: []step{
+				{count: ptr.To[int64](1), want: []string{
+					"Room opened for 10ms (1/2 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+					"Room closed after 10ms (0/2 rooms)",
+				}},
+			},
+			wantCount: 0,
+		},
+		{
+			name:     "handles capacity",
+			capacity: 2,
+			dur:      10 * time.Millisecond,
+			steps: []step{
+				{count: ptr.To[int64](2), want: []string{
+					"Room opened for 10ms (1/2 rooms)",
+					"Room opened for 10ms (2/2 rooms)",
+					"Room closed after 10ms (1/2 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+					"Room closed after 10ms (0/2 rooms)",
+				}},
+			},
+			wantCount: 0,
+		},
+		{
+			name:        "handles idle shutdown",
+			capacity:    1,
+			dur:         10 * time.Millisecond,
+			idleTimeout: 10 * time.Millisecond,
+			steps: []step{
+				{count: ptr.To[int64](1), want: []string{
+					"Room opened for 10ms (1/1 rooms)",
+					"Room closed after 10ms (0/1 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+					"Rooms idle for 10ms, requesting Agones state update to Shutdown",
+				}},
+			},
+			wantCount: 0,
+		},
+		{
+			name:        "handles no idle shutdown without rooms",
+			capacity:    1,
+			dur:         time.Hour,
+			idleTimeout: 10 * time.Millisecond,
+			steps: []step{
+				{wait: 50 * time.Millisecond, count: ptr.To[int64](1), want: []string{"Room opened for 1h0m0s (1/1 rooms)"}},
+			},
+			wantCount: 1,
+		},
+		{
+			name:       "handles failed close",
+			capacity:   2,
+			dur:        10 * time.Millisecond,
+			updateErrs: []error{errors.New("unavailable")},
+			steps: []step{
+				{count: ptr.To[int64](1), want: []string{
+					"Room opened for 10ms (1/2 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+					"Room closed after 10ms, but the counter update failed, retrying",
+					"Room closed after 10ms (0/2 rooms)",
+				}},
+			},
+			wantCount: 0,
+		},
+		{
+			name:        "handles counter lowered from outside",
+			capacity:    2,
+			dur:         time.Hour,
+			idleTimeout: 10 * time.Millisecond,
+			steps: []step{
+				{count: ptr.To[int64](2), want: []string{
+					"Room opened for 1h0m0s (1/2 rooms)",
+					"Room opened for 1h0m0s (2/2 rooms)",
+				}},
+				{count: ptr.To[int64](1), want: []string{
+					"Room dropped, as the counter was lowered to 1",
+					"Room capacity left, requesting Agones state update to Ready",
+				}},
+				{count: ptr.To[int64](0), want: []string{
+					"Room dropped, as the counter was lowered to 0",
+					"Rooms idle for 10ms, requesting Agones state update to Shutdown",
+				}},
+			},
+			wantCount: 0,
+		},
+		{
+			name:       "handles failed close with counter lowered from outside",
+			capacity:   2,
+			dur:        10 * time.Millisecond,
+			updateErrs: []error{errors.New("unavailable")},
+			steps: []step{
+				{count: ptr.To[int64](1), want: []string{
+					"Room opened for 10ms (1/2 rooms)",
+					"Room capacity left, requesting Agones state update to Ready",
+					"Room closed after 10ms, but the counter update failed, retrying",
+				}},
+				{count: ptr.To[int64](0), want: []string{"Room dropped, as the counter was lowered to 0"}},
+				// The dropped room is not closed again, the next message is about the next room.
+				{wait: time.Second, count: ptr.To[int64](1), want: []string{
+					"Room opened for 10ms (1/2 rooms)",
+					"Room closed after 10ms (0/2 rooms)",
+				}},
+			},
+			wantCount: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &pollSDK{}
+			s.setCounter(0, test.capacity)
+			s.failUpdates(test.updateErrs...)
+			client := agones.NewClient(s, agones.WithoutWatch(), agones.WithPollInterval(time.Millisecond), agones.WithBetaSDK(s))
+
+			q := queue.NewFifo[fakegameserver.Message]()
+			t.Cleanup(q.Shutdown)
+
+			go client.Run(t.Context())
+
+			rooms := fakegameserver.NewAgonesRooms(client, "rooms", test.dur, test.dur, test.idleTimeout)
+			go rooms.Run(t.Context(), q)
+
+			for _, step := range test.steps {
+				time.Sleep(step.wait)
+				if step.count != nil {
+					s.setCounter(*step.count, test.capacity)
+				}
+
+				got := make([]string, 0, len(step.want))
+				for range step.want {
+					msg, shutdown := q.Get()
+					require.False(t, shutdown)
+					got = append(got, msg.Description)
+				}
+				assert.Equal(t, step.want, got)
+			}
+			assert.Equal(t, test.wantCount, s.counter())
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package fakegameserver
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/cron"
+)
+
+// Schedule is a recurring schedule.
+type Schedule = cron.Schedule
+
+// ParseSchedule parses a schedule, either a cron expression with five fields, e.g. `0 4 * * *` for a daily restart at
+// 04:00, a macro like `@daily`, or a fixed interval like `@every 10m`.
+func ParseSchedule(s string) (Schedule, error) {
+	return cron.Parse(s)
+}
+
+// Handle is a handle of a scheduled message delivery.
+type Handle interface {
+	// Cancel cancels the delivery, or all future deliveries of a recurring schedule. It returns false if the delivery
+	// already happened or was cancelled before.
+	Cancel() bool
+}
+
+// Scheduler is a queue that delivers messages in the future.
+type Scheduler interface {
+	Queue
+
+	// AddAfter adds the message after the given duration.
+	AddAfter(msg Message, d time.Duration) Handle
+
+	// AddAt adds the message at the given time.
+	AddAt(msg Message, t time.Time) Handle
+
+	// AddSchedule adds the message at each time of the schedule.
+	AddSchedule(msg Message, sched Schedule) Handle
+}
+
+// AddAfter adds the message to the queue after the given duration.
+//
+// If the queue is not a Scheduler, the message is delivered by a timer on the real clock.
+func AddAfter(q Queue, msg Message, d time.Duration) Handle {
+	if s, ok := q.(Scheduler); ok {
+		return s.AddAfter(msg, d)
+	}
+	return schedule(context.Background(), q, msg, func(now time.Time) time.Time { return now.Add(d) }, false)
+}
+
+// AddAt adds the message to the queue at the given time.
+//
+// If the queue is not a Scheduler, the message is delivered by a timer on the real clock.
+func AddAt(q Queue, msg Message, t time.Time) Handle {
+	if s, ok := q.(Scheduler); ok {
+		return s.AddAt(msg, t)
+	}
+	return schedule(context.Background(), q, msg, func(time.Time) time.Time { return t }, false)
+}
+
+// AddSchedule adds the message to the queue at each time of the schedule.
+//
+// If the queue is not a Scheduler, the messages are delivered by a timer on the real clock.
+func AddSchedule(q Queue, msg Message, sched Schedule) Handle {
+	if s, ok := q.(Scheduler); ok {
+		return s.AddSchedule(msg, sched)
+	}
+	return schedule(context.Background(), q, msg, sched.Next, true)
+}
+
+// schedQueue is the queue of a producer, that schedules messages on the clock of the context until the game server
+// stops.
+type schedQueue struct {
+	Queue
+
+	ctx context.Context //nolint:containedctx // Scoped to the run of the game server.
+}
+
+// scheduler returns the queue as a Scheduler, scheduling on the clock of the context until it is done if it is none.
+func scheduler(ctx context.Context, q Queue) Scheduler {
+	if s, ok := q.(Scheduler); ok {
+		return s
+	}
+	return schedQueue{Queue: q, ctx: ctx}
+}
+
+func (q schedQueue) AddAfter(msg Message, d time.Duration) Handle {
+	return schedule(q.ctx, q.Queue, msg, func(now time.Time) time.Time { return now.Add(d) }, false)
+}
+
+func (q schedQueue) AddAt(msg Message, t time.Time) Handle {
+	return schedule(q.ctx, q.Queue, msg, func(time.Time) time.Time { return t }, false)
+}
+
+func (q schedQueue) AddSchedule(msg Message, sched Schedule) Handle {
+	return schedule(q.ctx, q.Queue, msg, sched.Next, true)
+}
+
+// timerHandle delivers a message by a timer, re-arming it for recurring schedules.
+type timerHandle struct {
+	queue     Queue
+	msg       Message
+	next      func(time.Time) time.Time
+	recurring bool
+
+	clock     clock.Clock
+	mu        sync.Mutex
+	timer     clock.Timer
+	done      bool
+	stopCtxFn func() bool
+}
+
+func schedule(ctx context.Context, q Queue, msg Message, next func(time.Time) time.Time, recurring bool) *timerHandle {
+	h := &timerHandle{
+		queue:     q,
+		msg:       msg,
+		next:      next,
+		recurring: recurring,
+		clock:     clock.FromContext(ctx),
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.stopCtxFn = context.AfterFunc(ctx, func() { h.Cancel() })
+	h.arm(h.clock.Now())
+	return h
+}
+
+// arm arms the timer for the next delivery. It must be called with the lock held.
+func (h *timerHandle) arm(now time.Time) {
+	at := h.next(now)
+	if at.IsZero() { // The schedule has no next time.
+		h.done = true
+		h.stopCtxFn()
+		return
+	}
+	h.timer = h.clock.AfterFunc(at.Sub(now), h.fire)
+}
+
+func (h *timerHandle) fire() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.done {
+		return
+	}
+	h.queue.Add(h.msg)
+
+	if !h.recurring {
+		h.done = true
+		h.stopCtxFn()
+		return
+	}
+	h.arm(h.clock.Now())
+}
+
+// Cancel cancels the delivery.
+func (h *timerHandle) Cancel() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.done {
+		return false
+	}
+	h.done = true
+	h.timer.Stop()
+	h.stopCtxFn()
+	return true
+}
+
+// wakeQueue wakes a handler at the times it scheduled, rather than adding the scheduled messages to the message queue.
+// The handler closes it once it stops waiting for wake-ups.
+type wakeQueue struct {
+	*mailbox[struct{}]
+}
+
+func newWakeQueue(clk clock.Clock) wakeQueue {
+	m := newMailbox[struct{}]()
+	m.setClock(clk)
+	return wakeQueue{mailbox: m}
+}
+
+// Add wakes the handler, unless a wake-up is pending already.
+func (q wakeQueue) Add(Message) {
+	q.put(struct{}{})
+}
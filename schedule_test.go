@@ -0,0 +1,129 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddAfter(t *testing.T) {
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	start := time.Now()
+	fakegameserver.AddAfter(q, fakegameserver.Message{Description: "delivered"}, 20*time.Millisecond)
+	canceled := fakegameserver.AddAfter(q, fakegameserver.Message{Description: "canceled"}, 10*time.Millisecond)
+
+	assert.True(t, canceled.Cancel())
+	assert.False(t, canceled.Cancel())
+
+	msg, shutdown := q.Get()
+
+	require.False(t, shutdown)
+	assert.Equal(t, "delivered", msg.Description)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
+
+func TestAddSchedule(t *testing.T) {
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	sched, err := fakegameserver.ParseSchedule("@every 10ms")
+	require.NoError(t, err)
+
+	h := fakegameserver.AddSchedule(q, fakegameserver.Message{Description: "recurring"}, sched)
+
+	for range 3 {
+		msg, shutdown := q.Get()
+
+		require.False(t, shutdown)
+		assert.Equal(t, "recurring", msg.Description)
+	}
+	assert.True(t, h.Cancel())
+}
+
+func TestGameServer_RunSchedulesOnItsClock(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	hCh := make(chan fakegameserver.Handle, 1)
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithClock(clk),
+		fakegameserver.WithConsumerTimeout(0),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		hCh <- fakegameserver.AddAfter(q, fakegameserver.Message{Type: fakegameserver.MessageTypeInfo}, time.Hour)
+		fakegameserver.AddAfter(q, fakegameserver.Message{Type: fakegameserver.MessageTypeExit, Description: "scheduled"}, time.Minute)
+	}))
+
+	type result struct {
+		reason string
+		err    error
+	}
+	resCh := make(chan result, 1)
+	go func() {
+		reason, err := gs.Run(t.Context())
+		resCh <- result{reason: reason, err: err}
+	}()
+
+	require.NoError(t, clk.WaitTimers(t.Context(), 2))
+	clk.Advance(time.Minute)
+
+	var res result
+	select {
+	case res = <-resCh:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for the game server to exit")
+	}
+
+	require.NoError(t, res.err)
+	assert.Equal(t, "scheduled", res.reason)
+	assert.Eventually(t, func() bool { return clk.Timers() == 0 }, time.Second, time.Millisecond)
+	assert.False(t, (<-hCh).Cancel(), "The pending delivery must be canceled with the run.")
+}
+
+func TestGameServer_RunCancelsSchedule(t *testing.T) {
+	clk := clock.NewVirtual(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	hCh := make(chan fakegameserver.Handle, 1)
+	recCh := make(chan fakegameserver.Message, 10)
+
+	sched, err := fakegameserver.ParseSchedule("@every 1m")
+	require.NoError(t, err)
+
+	gs := fakegameserver.New(
+		logger.New(io.Discard, logger.LogfmtFormat(), logger.Error),
+		fakegameserver.WithClock(clk),
+		fakegameserver.WithConsumerTimeout(0),
+	)
+	gs.AddProducer(producerFunc(func(_ context.Context, q fakegameserver.Queue) {
+		hCh <- fakegameserver.AddSchedule(q, fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "recurring"}, sched)
+	}))
+	gs.AddConsumer(fakegameserver.ConsumerFunc(func(msg fakegameserver.Message) {
+		if msg.Description == "recurring" {
+			recCh <- msg
+		}
+	}))
+	go func() { _, _ = gs.Run(t.Context()) }()
+
+	h := <-hCh
+	for range 3 {
+		require.NoError(t, clk.WaitTimers(t.Context(), 1))
+		clk.Advance(time.Minute)
+		waitFor(t, recCh, fakegameserver.MessageTypeInfo)
+	}
+
+	assert.True(t, h.Cancel())
+	assert.False(t, h.Cancel())
+	assert.Zero(t, clk.Timers())
+
+	clk.Advance(time.Hour)
+	assert.Empty(t, recCh)
+}
@@ -0,0 +1,199 @@
+package fakegameserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/clock"
+	"github.com/antiphp/fakegameserver/internal/exiterror"
+)
+
+const (
+	// MessageTypeAgonesSession is the message type for game sessions started from the allocation.
+	MessageTypeAgonesSession MessageType = "agonesSession"
+)
+
+// EventAgonesSession is the event type for game sessions started from the allocation, with the session as payload.
+var EventAgonesSession = NewEventType[Session](MessageTypeAgonesSession)
+
+const (
+	// AnnotationPrefix is the prefix of game server annotations that control the behavior of a game session.
+	AnnotationPrefix = "fakegs.antiphp.io/"
+
+	// AnnotationSessionDuration is the annotation for the duration of a game session, e.g. `3m`.
+	AnnotationSessionDuration = AnnotationPrefix + "session-duration"
+
+	// AnnotationExit is the annotation for how a game session ends, either `shutdown`, `code:<code>` or `signal:<signal>`.
+	AnnotationExit = AnnotationPrefix + "exit"
+)
+
+// Session is the behavior of a game session.
+type Session struct {
+	// Duration is the duration of the game session.
+	Duration time.Duration
+
+	// Exit is the exit error to exit the game server with when the game session ends. If nil, the game session ends
+	// with a transition to the Agones state Shutdown.
+	Exit error
+}
+
+// ParseSession parses the session behavior from game server annotations.
+//
+// It returns false, if none of the session annotations is set. Otherwise, the session duration must be set and
+// positive. The annotations are checked in the order of their keys.
+func ParseSession(annotations map[string]string) (Session, bool, error) {
+	var (
+		sess  Session
+		found bool
+	)
+	for _, key := range slices.Sorted(maps.Keys(annotations)) {
+		val := annotations[key]
+		if !strings.HasPrefix(key, AnnotationPrefix) {
+			continue
+		}
+		found = true
+
+		switch key {
+		case AnnotationSessionDuration:
+			dur, err := time.ParseDuration(val)
+			if err != nil {
+				return Session{}, true, fmt.Errorf("parsing %s: %w", key, err)
+			}
+			if dur <= 0 {
+				return Session{}, true, errors.New("parsing " + key + ": duration " + val + " is not positive")
+			}
+			sess.Duration = dur
+		case AnnotationExit:
+			if val == "shutdown" {
+				continue
+			}
+			exitErr, err := exiterror.Parse(val)
+			if err != nil {
+				return Session{}, true, fmt.Errorf("parsing %s: %w", key, err)
+			}
+			sess.Exit = exitErr
+		default:
+			return Session{}, true, errors.New("unknown annotation " + key)
+		}
+	}
+	if found && sess.Duration == 0 {
+		return Session{}, true, errors.New("missing annotation " + AnnotationSessionDuration)
+	}
+	return sess, found, nil
+}
+
+func (s Session) String() string {
+	how := "shutdown"
+	if s.Exit != nil {
+		how = s.Exit.Error()
+	}
+	return "duration " + s.Duration.String() + ", exit with " + how
+}
+
+var _ Publisher = (*AgonesSession)(nil)
+
+// AgonesSession ends game sessions as described by the annotations of the allocated game server.
+//
+// A game session starts when the game server transitions to Allocated, and restarts when it is re-allocated with
+// changed session annotations. The session takes precedence over the Agones state timers, which stop once a session started.
+type AgonesSession struct {
+	client *agones.Client
+
+	mu          sync.Mutex
+	state       agones.State
+	annotations map[string]string
+	end         Handle
+}
+
+// NewAgonesSession returns a new Agones session handler.
+func NewAgonesSession(client *agones.Client) *AgonesSession {
+	return &AgonesSession{
+		client: client,
+	}
+}
+
+// Publications returns the message types the Agones session handler produces.
+func (s *AgonesSession) Publications() []MessageType {
+	return []MessageType{MessageTypeInfo, MessageTypeExit, MessageTypeAgonesRequestUpdate}
+}
+
+// Run runs the Agones session handler.
+func (s *AgonesSession) Run(ctx context.Context, queue Queue) {
+	clk := clock.FromContext(ctx)
+	clock.Hold(clk)
+	go s.client.WatchGameServer(ctx, func(gs agones.GameServer) {
+		s.handle(queue, gs)
+	})
+
+	clock.Release(clk)
+	<-ctx.Done()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stop()
+}
+
+func (s *AgonesSession) handle(queue Queue, gs agones.GameServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	annotations := maps.Clone(gs.Annotations)
+	maps.DeleteFunc(annotations, func(key, _ string) bool { return !strings.HasPrefix(key, AnnotationPrefix) })
+
+	prev, prevAnnotations := s.state, s.annotations
+	s.state, s.annotations = gs.State, annotations
+	switch {
+	case gs.State != agones.StateAllocated:
+		s.stop()
+		return
+	case prev == gs.State && maps.Equal(prevAnnotations, annotations): // Re-allocations restart changed sessions only.
+		return
+	}
+
+	sess, ok, err := ParseSession(gs.Annotations)
+	switch {
+	case err != nil:
+		queue.Add(Message{
+			Type:        MessageTypeInfo,
+			Description: "Ignoring invalid session annotations",
+			Error:       err,
+		})
+		return
+	case !ok:
+		return
+	}
+
+	queue.Add(EventAgonesSession.Message("Session started from allocation with "+sess.String(), nil, sess))
+
+	msg := EventAgonesRequestUpdate.Message(
+		"Session ended after "+sess.Duration.String()+", requesting Agones state update to "+string(agones.StateShutdown),
+		nil,
+		agones.StateShutdown,
+	)
+	if sess.Exit != nil {
+		msg = Message{
+			Type:        MessageTypeExit,
+			Description: "Session ended after " + sess.Duration.String(),
+			Error:       sess.Exit,
+		}
+	}
+
+	s.stop()
+	s.end = AddAfter(queue, msg, sess.Duration)
+}
+
+func (s *AgonesSession) stop() {
+	if s.end == nil {
+		return
+	}
+	s.end.Cancel()
+	s.end = nil
+}
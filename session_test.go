@@ -0,0 +1,298 @@
+package fakegameserver_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"agones.dev/agones/pkg/sdk"
+	"agones.dev/agones/pkg/sdk/beta"
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/antiphp/fakegameserver/internal/queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+func TestParseSession(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantDur     time.Duration
+		wantExit    string
+		wantOK      bool
+		wantErr     require.ErrorAssertionFunc
+	}{
+		{
+			name:        "handles no annotations",
+			annotations: map[string]string{"foo": "bar"},
+			wantErr:     require.NoError,
+		},
+		{
+			name: "handles duration with shutdown",
+			annotations: map[string]string{
+				fakegameserver.AnnotationSessionDuration: "3m",
+				fakegameserver.AnnotationExit:            "shutdown",
+			},
+			wantDur: 3 * time.Minute,
+			wantOK:  true,
+			wantErr: require.NoError,
+		},
+		{
+			name: "handles exit signal",
+			annotations: map[string]string{
+				fakegameserver.AnnotationSessionDuration: "10s",
+				fakegameserver.AnnotationExit:            "signal:11",
+			},
+			wantDur:  10 * time.Second,
+			wantExit: "signal 11",
+			wantOK:   true,
+			wantErr:  require.NoError,
+		},
+		{
+			name:        "handles exit code without duration",
+			annotations: map[string]string{fakegameserver.AnnotationExit: "code:3"},
+			wantOK:      true,
+			wantErr:     errorContains("missing annotation fakegs.antiphp.io/session-duration"),
+		},
+		{
+			name:        "handles invalid duration",
+			annotations: map[string]string{fakegameserver.AnnotationSessionDuration: "foo"},
+			wantOK:      true,
+			wantErr:     require.Error,
+		},
+		{
+			name:        "handles negative duration",
+			annotations: map[string]string{fakegameserver.AnnotationSessionDuration: "-3m"},
+			wantOK:      true,
+			wantErr:     errorContains("duration -3m is not positive"),
+		},
+		{
+			name:        "handles zero duration",
+			annotations: map[string]string{fakegameserver.AnnotationSessionDuration: "0s"},
+			wantOK:      true,
+			wantErr:     errorContains("duration 0s is not positive"),
+		},
+		{
+			name: "handles invalid annotations in order",
+			annotations: map[string]string{
+				fakegameserver.AnnotationSessionDuration: "foo",
+				fakegameserver.AnnotationExit:            "crash",
+				fakegameserver.AnnotationPrefix + "foo":  "bar",
+			},
+			wantOK:  true,
+			wantErr: errorContains("parsing fakegs.antiphp.io/exit"),
+		},
+		{
+			name:        "handles invalid exit",
+			annotations: map[string]string{fakegameserver.AnnotationExit: "crash"},
+			wantOK:      true,
+			wantErr:     require.Error,
+		},
+		{
+			name:        "handles unknown annotation",
+			annotations: map[string]string{fakegameserver.AnnotationPrefix + "foo": "bar"},
+			wantOK:      true,
+			wantErr:     require.Error,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok, err := fakegameserver.ParseSession(test.annotations)
+
+			test.wantErr(t, err)
+			assert.Equal(t, test.wantOK, ok)
+			assert.Equal(t, test.wantDur, got.Duration)
+			if test.wantExit == "" {
+				assert.NoError(t, got.Exit)
+				return
+			}
+			assert.EqualError(t, got.Exit, test.wantExit)
+		})
+	}
+}
+
+func TestAgonesSession_Run(t *testing.T) {
+	tests := []struct {
+		name     string
+		exit     string
+		wantType fakegameserver.MessageType
+		wantErr  string
+	}{
+		{
+			name:     "requests shutdown",
+			exit:     "shutdown",
+			wantType: fakegameserver.MessageTypeAgonesRequestUpdate,
+		},
+		{
+			name:     "exits",
+			exit:     "code:3",
+			wantType: fakegameserver.MessageTypeExit,
+			wantErr:  "exit code 3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &pollSDK{}
+			s.setAnnotations(map[string]string{
+				fakegameserver.AnnotationSessionDuration: "1ms",
+				fakegameserver.AnnotationExit:            test.exit,
+			})
+			client := agones.NewClient(s, agones.WithoutWatch(), agones.WithPollInterval(time.Millisecond))
+
+			q := queue.NewFifo[fakegameserver.Message]()
+			t.Cleanup(q.Shutdown)
+
+			go client.Run(t.Context())
+			go fakegameserver.NewAgonesSession(client).Run(t.Context(), q)
+
+			msg, _ := q.Get()
+			assert.Equal(t, fakegameserver.MessageTypeAgonesSession, msg.Type)
+
+			msg, _ = q.Get()
+			require.Equal(t, test.wantType, msg.Type)
+			if test.wantErr != "" {
+				assert.EqualError(t, msg.Error, test.wantErr)
+				return
+			}
+			assert.Equal(t, agones.StateShutdown, msg.Payload)
+		})
+	}
+}
+
+func TestAgonesSession_RunRestartsOnReallocation(t *testing.T) {
+	s := &pollSDK{}
+	s.setAnnotations(map[string]string{fakegameserver.AnnotationSessionDuration: "1h"})
+	client := agones.NewClient(s, agones.WithoutWatch(), agones.WithPollInterval(time.Millisecond))
+
+	q := queue.NewFifo[fakegameserver.Message]()
+	t.Cleanup(q.Shutdown)
+
+	go client.Run(t.Context())
+	go fakegameserver.NewAgonesSession(client).Run(t.Context(), q)
+
+	msg, _ := q.Get()
+	require.Equal(t, fakegameserver.MessageTypeAgonesSession, msg.Type)
+	assert.Equal(t, time.Hour, msg.Payload.(fakegameserver.Session).Duration)
+
+	s.setAnnotations(map[string]string{
+		fakegameserver.AnnotationSessionDuration: "1ms",
+		fakegameserver.AnnotationExit:            "code:4",
+	})
+
+	msg, _ = q.Get()
+	require.Equal(t, fakegameserver.MessageTypeAgonesSession, msg.Type)
+	assert.Equal(t, time.Millisecond, msg.Payload.(fakegameserver.Session).Duration)
+
+	msg, _ = q.Get()
+	require.Equal(t, fakegameserver.MessageTypeExit, msg.Type)
+	assert.EqualError(t, msg.Error, "exit code 4")
+}
+
+func errorContains(contains string) require.ErrorAssertionFunc {
+	return func(t require.TestingT, err error, _ ...any) {
+		require.ErrorContains(t, err, contains)
+	}
+}
+
+// pollSDK is an Agones SDK that only serves polls of an allocated game server, and the counter `rooms`. Each poll
+// changes a label, so the watchers of the game server are notified of each poll.
+type pollSDK struct {
+	sdk.SDKClient
+	betaSDKClient
+
+	mu          sync.Mutex
+	annotations map[string]string
+	count       int64
+	capacity    int64
+	updateErrs  []error
+	polls       atomic.Int64
+}
+
+// betaSDKClient is the beta Agones SDK, named to be embedded next to the Agones SDK.
+type betaSDKClient = beta.SDKClient
+
+func (s *pollSDK) setAnnotations(annotations map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.annotations = annotations
+}
+
+func (s *pollSDK) setCounter(count, capacity int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count, s.capacity = count, capacity
+}
+
+// failUpdates fails the next counter updates with the given errors, unless nil.
+func (s *pollSDK) failUpdates(errs ...error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.updateErrs = append(s.updateErrs, errs...)
+}
+
+func (s *pollSDK) counter() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.count
+}
+
+func (s *pollSDK) GetGameServer(context.Context, *sdk.Empty, ...grpc.CallOption) (*sdk.GameServer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &sdk.GameServer{
+		ObjectMeta: &sdk.GameServer_ObjectMeta{
+			Labels:      map[string]string{"poll": strconv.FormatInt(s.polls.Add(1), 10)},
+			Annotations: s.annotations,
+		},
+		Status: &sdk.GameServer_Status{
+			State:    "Allocated",
+			Counters: map[string]*sdk.GameServer_Status_CounterStatus{"rooms": {Count: s.count, Capacity: s.capacity}},
+		},
+	}, nil
+}
+
+func (s *pollSDK) GetCounter(context.Context, *beta.GetCounterRequest, ...grpc.CallOption) (*beta.Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return &beta.Counter{Name: "rooms", Count: s.count, Capacity: s.capacity}, nil
+}
+
+func (s *pollSDK) UpdateCounter(_ context.Context, in *beta.UpdateCounterRequest, _ ...grpc.CallOption) (*beta.Counter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.updateErrs) > 0 {
+		err := s.updateErrs[0]
+		s.updateErrs = s.updateErrs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	count := s.count + in.GetCounterUpdateRequest().GetCountDiff()
+	if set := in.GetCounterUpdateRequest().GetCount(); set != nil {
+		count = set.GetValue()
+	}
+	if count < 0 || count > s.capacity {
+		return nil, errors.New("count out of range")
+	}
+	s.count = count
+	return &beta.Counter{Name: "rooms", Count: s.count, Capacity: s.capacity}, nil
+}
@@ -0,0 +1,78 @@
+package fakegameserver
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/antiphp/fakegameserver/clock"
+)
+
+const (
+	// MessageTypeSignal is the message type for received process signals.
+	MessageTypeSignal MessageType = "signal"
+)
+
+// EventSignal is the event type for received process signals, with the signal as payload.
+var EventSignal = NewEventType[syscall.Signal](MessageTypeSignal)
+
+var _ Publisher = (*Signals)(nil)
+
+// Signals produces messages for received process signals, e.g. the SIGTERM sent by Agones after the Agones state
+// changed to Shutdown.
+type Signals struct {
+	ch <-chan os.Signal
+}
+
+// NewSignals returns a new signal producer, that receives the signals from the channel, as notified by
+// `signal.Notify`.
+func NewSignals(ch <-chan os.Signal) *Signals {
+	return &Signals{ch: ch}
+}
+
+// Publications returns the message types the signal producer produces.
+func (s *Signals) Publications() []MessageType {
+	return []MessageType{MessageTypeSignal}
+}
+
+// Run runs the signal producer.
+func (s *Signals) Run(ctx context.Context, queue Queue) {
+	clk := clock.FromContext(ctx)
+
+	for {
+		clock.Release(clk)
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-s.ch:
+			clock.Hold(clk) // Until the signal is added.
+			ssig, ok := sig.(syscall.Signal)
+			if !ok {
+				continue
+			}
+			queue.Add(EventSignal.Message("Received signal "+signalName(ssig), nil, ssig))
+		}
+	}
+}
+
+// signals are the signals known by name.
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// signalName returns the name of a signal, e.g. `SIGTERM`, or its number if unknown.
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return "signal " + strconv.Itoa(int(sig))
+}
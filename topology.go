@@ -0,0 +1,230 @@
+package fakegameserver
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Subscriber is a consumer that subscribes to message types.
+//
+// Consumers that do not implement Subscriber receive all messages.
+type Subscriber interface {
+	Consumer
+
+	// Subscriptions returns the message types the consumer subscribes to. A message type may contain the wildcard `*`,
+	// e.g. `agones*`, matching any sequence of characters.
+	Subscriptions() []MessageType
+}
+
+// Publisher is a producer that declares the message types it produces.
+//
+// It describes the topology of the game server, and orders the start of the handlers: a handler starts after the
+// publishers of the message types it consumes.
+type Publisher interface {
+	Producer
+
+	// Publications returns the message types the producer produces.
+	Publications() []MessageType
+}
+
+// Handlers is a consumer that dispatches messages to a handler per message type, and subscribes to exactly these
+// message types.
+//
+// Consumers declare the messages they consume once as handlers, so that their subscriptions can not diverge from what
+// they consume.
+type Handlers map[MessageType]func(Message)
+
+// HandleEvent adds a handler for messages of the event type.
+func HandleEvent[T any](h Handlers, e EventType[T], fn func(Event[T])) {
+	h[e.Type()] = func(msg Message) {
+		if ev, ok := e.Event(msg); ok {
+			fn(ev)
+		}
+	}
+}
+
+// Subscriptions returns the message types of the handlers.
+func (h Handlers) Subscriptions() []MessageType {
+	subs := make([]MessageType, 0, len(h))
+	for typ := range h {
+		subs = append(subs, typ)
+	}
+	slices.Sort(subs)
+	return subs
+}
+
+// Consume dispatches a message to the handler of its message type.
+func (h Handlers) Consume(msg Message) {
+	if fn, ok := h[msg.Type]; ok {
+		fn(msg)
+	}
+}
+
+// MatchMessageType determines if a message type matches a subscribed message type, which may contain wildcards.
+func MatchMessageType(sub, typ MessageType) bool {
+	ok, _ := path.Match(string(sub), string(typ))
+	return ok
+}
+
+// subscriptions returns the validated subscriptions of a consumer, or nil if it subscribes to all messages.
+func subscriptions(c Consumer) []MessageType {
+	s, ok := c.(Subscriber)
+	if !ok {
+		return nil
+	}
+
+	subs := s.Subscriptions()
+	if subs == nil {
+		subs = []MessageType{} // A subscriber without subscriptions receives no messages.
+	}
+	for _, sub := range subs {
+		if _, err := path.Match(string(sub), ""); err != nil {
+			panic("Invalid subscription " + string(sub) + " of " + handlerName(c)) // Developer error.
+		}
+	}
+	return subs
+}
+
+// subscribed determines if any of the subscriptions matches the message type. Nil subscriptions match all messages.
+func subscribed(subs []MessageType, typ MessageType) bool {
+	if subs == nil {
+		return true
+	}
+	return slices.ContainsFunc(subs, func(sub MessageType) bool {
+		return MatchMessageType(sub, typ)
+	})
+}
+
+// Node is a producer or consumer in the topology of a game server.
+type Node struct {
+	Name string
+
+	// Types are the published or subscribed message types. Nil means unknown for producers, and all messages for
+	// consumers.
+	Types []MessageType
+}
+
+// Route is the route of a published message type from its producers to its consumers.
+type Route struct {
+	Type      MessageType
+	Producers []string
+	Consumers []string
+}
+
+// Topology is the wiring of producers and consumers of a game server.
+type Topology struct {
+	Producers []Node
+	Consumers []Node
+	Routes    []Route
+}
+
+// Topology returns the wiring of producers and consumers of the game server.
+func (g *GameServer) Topology() Topology {
+	var topo Topology
+
+	routes := map[MessageType]*Route{}
+	addProducer := func(name string, types []MessageType) {
+		var unique []MessageType
+		for _, typ := range types {
+			if !slices.Contains(unique, typ) {
+				unique = append(unique, typ)
+			}
+		}
+		topo.Producers = append(topo.Producers, Node{Name: name, Types: unique})
+		for _, typ := range unique {
+			r, ok := routes[typ]
+			if !ok {
+				r = &Route{Type: typ}
+				routes[typ] = r
+			}
+			r.Producers = append(r.Producers, name)
+		}
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var hasConsumers bool
+	for _, h := range g.handlers {
+		_, ok := h.(Consumer)
+		hasConsumers = hasConsumers || ok
+
+		p, ok := h.(Producer)
+		if !ok {
+			continue
+		}
+
+		var types []MessageType
+		if pub, ok := p.(Publisher); ok {
+			types = pub.Publications()
+		}
+		addProducer(handlerName(p), types)
+	}
+	if g.consumerTimeout > 0 {
+		addProducer("watchdog", []MessageType{MessageTypeConsumerStuck})
+	}
+	if hasConsumers {
+		addProducer("dispatcher", []MessageType{MessageTypeError})
+	}
+
+	for _, h := range g.handlers {
+		c, ok := h.(Consumer)
+		if !ok {
+			continue
+		}
+
+		name, subs := handlerName(c), subscriptions(c)
+		topo.Consumers = append(topo.Consumers, Node{Name: name, Types: subs})
+
+		for _, r := range routes {
+			if subscribed(subs, r.Type) {
+				r.Consumers = append(r.Consumers, name)
+			}
+		}
+	}
+
+	for _, r := range routes {
+		topo.Routes = append(topo.Routes, *r)
+	}
+	slices.SortFunc(topo.Routes, func(a, b Route) int {
+		return strings.Compare(string(a.Type), string(b.Type))
+	})
+	return topo
+}
+
+func (t Topology) String() string {
+	var sb strings.Builder
+
+	sb.WriteString("Producers:\n")
+	for _, n := range t.Producers {
+		sb.WriteString("  " + n.Name + " → " + joinTypes(n.Types, "(unknown)") + "\n")
+	}
+
+	sb.WriteString("Consumers:\n")
+	for _, n := range t.Consumers {
+		sb.WriteString("  " + n.Name + " ← " + joinTypes(n.Types, "*") + "\n")
+	}
+
+	sb.WriteString("Routes:\n")
+	for _, r := range t.Routes {
+		consumers := "(none)"
+		if len(r.Consumers) > 0 {
+			consumers = strings.Join(r.Consumers, ", ")
+		}
+		sb.WriteString("  " + string(r.Type) + ": " + strings.Join(r.Producers, ", ") + " → " + consumers + "\n")
+	}
+	return sb.String()
+}
+
+func joinTypes(types []MessageType, fallback string) string {
+	if types == nil {
+		return fallback
+	}
+
+	strs := make([]string, 0, len(types))
+	for _, typ := range types {
+		strs = append(strs, string(typ))
+	}
+	return strings.Join(strs, ", ")
+}
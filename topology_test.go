@@ -0,0 +1,55 @@
+package fakegameserver_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/antiphp/fakegameserver"
+	"github.com/antiphp/fakegameserver/agones"
+	"github.com/hamba/logger/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlers(t *testing.T) {
+	var got []string
+	h := fakegameserver.Handlers{
+		fakegameserver.MessageTypeInfo: func(msg fakegameserver.Message) {
+			got = append(got, msg.Description)
+		},
+	}
+	fakegameserver.HandleEvent(h, fakegameserver.EventAgonesUpdate, func(ev fakegameserver.Event[agones.State]) {
+		got = append(got, string(ev.Payload))
+	})
+
+	h.Consume(fakegameserver.Message{Type: fakegameserver.MessageTypeInfo, Description: "test"})
+	h.Consume(fakegameserver.EventAgonesUpdate.Message("test", nil, agones.StateReady))
+	h.Consume(fakegameserver.EventAgonesRequestUpdate.Message("test", nil, agones.StateShutdown))
+
+	assert.Equal(t, []string{"test", "Ready"}, got)
+	want := []fakegameserver.MessageType{fakegameserver.MessageTypeAgonesUpdate, fakegameserver.MessageTypeInfo}
+	assert.Equal(t, want, h.Subscriptions())
+}
+
+func TestMatchMessageType(t *testing.T) {
+	assert.True(t, fakegameserver.MatchMessageType("agonesUpdate", "agonesUpdate"))
+	assert.True(t, fakegameserver.MatchMessageType("agones*", "agonesUpdate"))
+	assert.True(t, fakegameserver.MatchMessageType("*", "info"))
+	assert.False(t, fakegameserver.MatchMessageType("agones*", "healthStatus"))
+}
+
+func TestGameServer_Topology(t *testing.T) {
+	gs := fakegameserver.New(logger.New(io.Discard, logger.LogfmtFormat(), logger.Error))
+	gs.AddHandler(fakegameserver.NewAgonesStateTimer(gs.Projection()))
+	gs.AddHandler(fakegameserver.NewProjectedHealthStatus(gs.Projection()))
+
+	topo := gs.Topology()
+
+	var route fakegameserver.Route
+	for _, r := range topo.Routes {
+		if r.Type == fakegameserver.MessageTypeAgonesRequestUpdate {
+			route = r
+		}
+	}
+	assert.Equal(t, []string{"fakegameserver.AgonesStateTimer"}, route.Producers)
+	assert.Equal(t, []string{"fakegameserver.HealthStatus"}, route.Consumers)
+}
@@ -0,0 +1,152 @@
+package fakegameserver
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/antiphp/fakegameserver/agones"
+)
+
+// StateAfter is an Agones state to request after a duration.
+type StateAfter struct {
+	State agones.State
+	After time.Duration
+}
+
+// Config configures the handlers of a fake game server, as the flags of the fakegs do.
+type Config struct {
+	// ExitAfter is the duration after which to exit. Zero disables it.
+	ExitAfter time.Duration
+	// ExitSchedule is the schedule at which to exit. Empty disables it.
+	ExitSchedule string
+
+	// Agones is the Agones client. Nil disables the Agones integration.
+	Agones *agones.Client
+	// DisconnectTimeout is the duration the Agones connection may be lost before the disconnect action is taken. Zero
+	// disables it.
+	DisconnectTimeout time.Duration
+	DisconnectAction  Action
+	// UpdateAttempts, UpdateDeadline and UpdateGiveUp configure the retries of Agones state updates.
+	UpdateAttempts int
+	UpdateDeadline time.Duration
+	UpdateGiveUp   Action
+	// HealthReportDelay and HealthReportInterval configure the Agones health reports.
+	HealthReportDelay    time.Duration
+	HealthReportInterval time.Duration
+	// States are the Agones states to request after durations.
+	States []StateAfter
+	// ShutdownOnExit transitions to the Agones state Shutdown when the game server exits.
+	ShutdownOnExit bool
+	// ExitOnShutdown exits the game server when the Agones state changes to a final state. Nil exits only in local
+	// development mode.
+	ExitOnShutdown *bool
+
+	// Population is the population curve to apply. Nil disables it.
+	Population Curve
+	// PopulationCounter is the counter to apply the population to. Empty applies it to the player count.
+	PopulationCounter   string
+	PopulationTimeScale float64
+	PopulationInterval  time.Duration
+
+	// RoomsCounter is the counter that tracks rooms. Empty disables rooms.
+	RoomsCounter     string
+	RoomDurationMin  time.Duration
+	RoomDurationMax  time.Duration
+	RoomsIdleTimeout time.Duration
+
+	// Signals are the received process signals to produce messages for. Nil disables them.
+	Signals <-chan os.Signal
+	// Probe are the expectations of the probe. Empty disables the probe.
+	Probe []ProbeExpectation
+	// ProbeReport is where the probe writes its report to.
+	ProbeReport io.Writer
+}
+
+// Wire adds the handlers of the configuration to the game server.
+func Wire(gs *GameServer, cfg Config) error {
+	healthStatus := NewProjectedHealthStatus(gs.Projection())
+	healthStatus.Exclude(MessageTypeInfo, MessageTypeExit)
+
+	if cfg.ExitAfter > 0 {
+		gs.AddHandler(NewExitTimer(cfg.ExitAfter))
+	}
+	if cfg.ExitSchedule != "" {
+		exitSchedule, err := NewExitSchedule(cfg.ExitSchedule)
+		if err != nil {
+			return err
+		}
+		gs.AddHandler(exitSchedule)
+	}
+	if cfg.Agones != nil {
+		if err := wireAgones(gs, cfg, healthStatus); err != nil {
+			return err
+		}
+	}
+	if cfg.Signals != nil {
+		gs.AddHandler(NewSignals(cfg.Signals))
+		healthStatus.Exclude(MessageTypeSignal)
+	}
+	if len(cfg.Probe) > 0 {
+		report := cfg.ProbeReport
+		if report == nil {
+			report = io.Discard
+		}
+		gs.AddHandler(NewProbe(report, cfg.Probe...))
+		healthStatus.Exclude(MessageTypeProbe)
+	}
+
+	gs.AddHandler(healthStatus)
+	return nil
+}
+
+func wireAgones(gs *GameServer, cfg Config, healthStatus *HealthStatus) error {
+	client := cfg.Agones
+
+	gs.AddHandler(NewAgonesWatcher(client))
+	healthStatus.WaitFor(MessageTypeAgonesConnection)
+	healthStatus.Exclude(MessageTypeAgonesSync, MessageTypeAgonesTransition)
+
+	gs.AddHandler(NewAgonesHealthReporter(client, cfg.HealthReportDelay, cfg.HealthReportInterval))
+	healthStatus.Exclude(MessageTypeAgonesReportHealth)
+
+	gs.AddHandler(NewAgonesStateUpdater(client, cfg.UpdateAttempts, cfg.UpdateDeadline, cfg.UpdateGiveUp))
+	healthStatus.Exclude(MessageTypeAgonesUpdateAttempt)
+
+	if cfg.DisconnectTimeout > 0 {
+		gs.AddHandler(NewAgonesDisconnect(client, cfg.DisconnectTimeout, cfg.DisconnectAction))
+	}
+
+	stateTimer := NewAgonesStateTimer(gs.Projection())
+	for _, s := range cfg.States {
+		stateTimer.AddState(s.State, s.After)
+	}
+	gs.AddHandler(stateTimer)
+
+	gs.AddHandler(NewAgonesSession(client))
+
+	if cfg.Population != nil {
+		population, err := NewAgonesPopulation(client, cfg.PopulationCounter, cfg.Population, cfg.PopulationTimeScale, cfg.PopulationInterval)
+		if err != nil {
+			return err
+		}
+		gs.AddHandler(population)
+		healthStatus.Exclude(MessageTypeAgonesPopulation)
+	}
+
+	if cfg.RoomsCounter != "" {
+		gs.AddHandler(NewAgonesRooms(client, cfg.RoomsCounter, cfg.RoomDurationMin, cfg.RoomDurationMax, cfg.RoomsIdleTimeout))
+	}
+
+	if cfg.ShutdownOnExit {
+		gs.AddHandler(NewAgonesExitShutdown(client, gs.Projection()))
+	}
+
+	gs.AddHandler(NewAgonesShutdown(gs.Projection(), func() bool {
+		if cfg.ExitOnShutdown == nil {
+			return client.IsLocal()
+		}
+		return *cfg.ExitOnShutdown
+	}))
+	return nil
+}